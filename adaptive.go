@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+var adaptive = flag.Bool("adaptive", false,
+	"probe up to 4 times as often while the RTT jumps or probes are lost, and back off to 4 times less often while stable")
+
+const (
+	adaptiveRange = 4 // how much faster or slower than the scheduled interval
+	// adaptiveStep is how much the interval grows after every stable probe.
+	adaptiveStep = 1.25
+	// adaptiveWarmup is how many replies to see before telling a jump.
+	adaptiveWarmup = 5
+	adaptiveMin    = 100 * time.Millisecond
+)
+
+// adaptiveRate tracks the RTT of a target like TCP does for its
+// retransmission timeout, to tell whether the network is disturbed.
+type adaptiveRate struct {
+	srtt, rttvar time.Duration
+	replies      int
+	factor       float64 // of the scheduled interval
+}
+
+// next returns the interval until the next probe after one that took rtt, or
+// failed, when base is the scheduled interval. Disturbances switch to the
+// fastest rate at once, while stable probes slow it down gradually.
+func (a *adaptiveRate) next(base, rtt time.Duration, err error) time.Duration {
+	if a.factor == 0 {
+		a.factor = 1
+	}
+	disturbed := err != nil
+	if err == nil {
+		if a.replies >= adaptiveWarmup {
+			dev := (rtt - a.srtt).Abs()
+			disturbed = dev > 4*a.rttvar || a.rttvar > a.srtt/2
+			a.rttvar += (dev - a.rttvar) / 4
+			a.srtt += (rtt - a.srtt) / 8
+		} else {
+			a.srtt += (rtt - a.srtt) / time.Duration(a.replies+1)
+			a.rttvar = a.srtt / 4
+		}
+		a.replies++
+	}
+
+	if disturbed {
+		a.factor = 1.0 / adaptiveRange
+	} else {
+		a.factor = min(a.factor*adaptiveStep, adaptiveRange)
+	}
+	return max(time.Duration(float64(base)*a.factor), adaptiveMin)
+}
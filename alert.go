@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var downAfter = flag.Duration("down-after", 30*time.Second,
+	"alert when a target gets no replies for this long")
+var alertFor = flag.Duration("alert-for", 0,
+	"hold alerts pending until their condition lasts this long, so brief blips do not notify")
+var alertCooldown = flag.Duration("alert-cooldown", 0,
+	"hold an alert pending for this long after it resolved, so flapping conditions do not notify every time")
+
+// alert is a condition on a target worth notifying someone about. Alerts are
+// raised when the condition starts and raised again, resolved, when it ends.
+type alert struct {
+	time     time.Time
+	target   target
+	name     string // what is wrong, e.g. "rtt" or "down"
+	text     string
+	resolved bool
+}
+
+// alertSink is a sink that also receives alerts.
+type alertSink interface {
+	writeAlert(a alert)
+}
+
+// alertRule overrides -alert-for and -alert-cooldown for an alert in the
+// config, by its name, e.g.
+//
+//	"alerts": {"rtt": {"for": "30s", "cooldown": "5m"}}
+type alertRule struct {
+	For      string `json:"for"`
+	Cooldown string `json:"cooldown"`
+
+	forDuration, cooldown time.Duration
+}
+
+func (r *alertRule) parse() error {
+	var err error
+	if r.For != "" {
+		if r.forDuration, err = time.ParseDuration(r.For); err != nil {
+			return fmt.Errorf("bad for %q", r.For)
+		}
+	} else {
+		r.forDuration = -1
+	}
+	if r.Cooldown != "" {
+		if r.cooldown, err = time.ParseDuration(r.Cooldown); err != nil {
+			return fmt.Errorf("bad cooldown %q", r.Cooldown)
+		}
+	} else {
+		r.cooldown = -1
+	}
+	return nil
+}
+
+// alertDelays returns how long an alert named name is held pending, and for
+// how long after it resolved.
+func alertDelays(name string) (forDuration, cooldown time.Duration) {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	forDuration, cooldown = settings.alertFor, settings.alertCooldown
+	if r, ok := settings.Alerts[name]; ok {
+		if r.forDuration >= 0 {
+			forDuration = r.forDuration
+		}
+		if r.cooldown >= 0 {
+			cooldown = r.cooldown
+		}
+	}
+	return forDuration, cooldown
+}
+
+// alertState is the state of an alert of a target: pending until it lasted
+// long enough to fire, and firing until it resolves.
+type alertState struct {
+	pending  *alert
+	due      time.Time // when the pending alert fires
+	firing   bool
+	resolved time.Time // when it last resolved after firing
+}
+
+type alertKey struct {
+	target, name string
+}
+
+var alertStates = struct {
+	sync.Mutex
+	m map[alertKey]*alertState
+}{m: map[alertKey]*alertState{}}
+
+// raiseAlert logs an alert as an event and hands it to the alert sinks. With
+// -alert-for or -alert-cooldown, an alert is held pending first, and only
+// logged when it resolves before firing.
+func raiseAlert(a alert) {
+	alertStates.Lock()
+	defer alertStates.Unlock()
+	key := alertKey{a.target.String(), a.name}
+	st, ok := alertStates.m[key]
+	if !ok {
+		st = &alertState{}
+		alertStates.m[key] = st
+	}
+
+	if a.resolved {
+		switch {
+		case st.pending != nil:
+			st.pending = nil
+			events.add(a.time, "resolved before firing: "+a.text)
+		case st.firing:
+			st.firing, st.resolved = false, a.time
+			notifyAlert(a)
+		}
+		return
+	}
+	if st.pending != nil {
+		return
+	}
+	if st.firing {
+		// raised again, as alerts like mac-flap that never resolve are
+		notifyAlert(a)
+		return
+	}
+	forDuration, cooldown := alertDelays(a.name)
+	st.due = a.time.Add(forDuration)
+	if !st.resolved.IsZero() && st.resolved.Add(cooldown).After(st.due) {
+		st.due = st.resolved.Add(cooldown)
+	}
+	if !st.due.After(a.time) {
+		st.firing = true
+		notifyAlert(a)
+		return
+	}
+	st.pending = &a
+	events.add(a.time, "pending: "+a.text)
+}
+
+// checkAlerts fires the pending alerts that are due.
+func checkAlerts(now time.Time) {
+	alertStates.Lock()
+	defer alertStates.Unlock()
+	for _, st := range alertStates.m {
+		if st.pending != nil && !now.Before(st.due) {
+			a := *st.pending
+			a.time = now
+			st.pending, st.firing = nil, true
+			notifyAlert(a)
+		}
+	}
+}
+
+// notifyAlert logs an alert that fired or resolved, and hands it to the
+// alert sinks unless it is muted.
+func notifyAlert(a alert) {
+	state := "firing: "
+	if a.resolved {
+		state = "resolved: "
+	}
+	if muted(a.target, a.time) {
+		events.add(a.time, "muted, "+state+a.text)
+		return
+	}
+	events.add(a.time, state+a.text)
+	for _, k := range sinks {
+		if k, ok := k.(alertSink); ok {
+			k.writeAlert(a)
+		}
+	}
+	if a.name == "down" && !a.resolved {
+		startCapture(a.target, "down")
+	}
+}
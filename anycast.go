@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var dnsNodeEvery = flag.Duration("dns-node-every", time.Minute,
+	"how often to ask dns:// targets which anycast node answers, 0 to never ask")
+
+// DNS types, classes and options used to identify the node of an anycast
+// server.
+const (
+	dnsTypeTXT   = 16
+	dnsTypeOPT   = 41
+	dnsClassCH   = 3
+	dnsOptNSID   = 3
+	dnsMaxUDPLen = 1232
+)
+
+// dnsNodeNames are the CHAOS TXT names servers answer with their identity,
+// e.g. the airport code of the Cloudflare data center for 1.1.1.1.
+var dnsNodeNames = []string{"id.server", "hostname.bind"}
+
+// dnsNode returns the identity of the node of the DNS server that answers,
+// asking with CHAOS TXT queries, then with the NSID option of EDNS.
+func dnsNode(t target) (string, error) {
+	d := t.dialer("udp", probeTimeout)
+	var lastErr error
+	for _, name := range dnsNodeNames {
+		_, resp, err := dnsQuery(d, t.address, name, dnsTypeTXT, dnsClassCH, probeTimeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		records, err := dnsAnswers(resp)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, r := range records {
+			if r.qtype == dnsTypeTXT {
+				if txt := txtData(r.data); txt != "" {
+					return txt, nil
+				}
+			}
+		}
+	}
+
+	query, err := dnsMessage(".", dnsTypeA, dnsClassIN)
+	if err != nil {
+		return "", err
+	}
+	binary.BigEndian.PutUint16(query[10:], 1) // ARCOUNT
+	query = append(query, 0)                  // the root
+	query = binary.BigEndian.AppendUint16(query, dnsTypeOPT)
+	query = binary.BigEndian.AppendUint16(query, dnsMaxUDPLen)
+	query = binary.BigEndian.AppendUint32(query, 0) // extended rcode and flags
+	query = binary.BigEndian.AppendUint16(query, 4) // RDLEN
+	query = binary.BigEndian.AppendUint16(query, dnsOptNSID)
+	query = binary.BigEndian.AppendUint16(query, 0)
+	_, resp, err := dnsExchange(d, t.address, query, probeTimeout)
+	if err != nil {
+		return "", err
+	}
+	records, err := dnsRecords(resp)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range records {
+		if r.qtype == dnsTypeOPT && r.additional {
+			if nsid := nsidData(r.data); nsid != "" {
+				return nsid, nil
+			}
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("%s does not identify its nodes", t.address)
+	}
+	return "", lastErr
+}
+
+// txtData joins the character strings of the data of a TXT record.
+func txtData(data []byte) string {
+	var parts []string
+	for len(data) > 0 && int(data[0]) < len(data) {
+		parts = append(parts, string(data[1:1+data[0]]))
+		data = data[1+data[0]:]
+	}
+	return strings.Join(parts, " ")
+}
+
+// nsidData returns the NSID option of the data of an OPT record, as text if
+// it is printable, as hex otherwise.
+func nsidData(data []byte) string {
+	for len(data) >= 4 {
+		code, n := binary.BigEndian.Uint16(data), int(binary.BigEndian.Uint16(data[2:]))
+		if 4+n > len(data) {
+			return ""
+		}
+		if code == dnsOptNSID {
+			nsid := string(data[4 : 4+n])
+			if strings.IndexFunc(nsid, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+				return hex.EncodeToString(data[4 : 4+n])
+			}
+			return nsid
+		}
+		data = data[4+n:]
+	}
+	return ""
+}
+
+// checkNode logs an event when another anycast node answers for s, which
+// often explains a latency shift.
+func (s *series) checkNode(smp sample) {
+	node, ok := smp.meta["node"]
+	if !ok {
+		return
+	}
+	if s.node != "" && node != s.node {
+		events.add(smp.time, fmt.Sprintf("%s is now answered by node %s, was %s", s.target, node, s.node))
+	}
+	s.node = node
+}
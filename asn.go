@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+var asnLookup = flag.Bool("asn", true,
+	"show the AS number, name and country of targets and hops, looked up in the DNS of Team Cymru")
+
+// asnTTL is how long AS lookups are cached.
+const asnTTL = time.Hour
+
+var asns = newLookupCache(asnTTL, lookupASN)
+
+// asnOf describes the AS host is in, e.g. "AS13335 CLOUDFLARENET, US", if
+// known.
+func asnOf(host string) (string, bool) {
+	if !*asnLookup || host == "" {
+		return "", false
+	}
+	return asns.get(host)
+}
+
+// lookupASN finds the AS announcing the address of host with the DNS
+// interface of the IP to ASN mapping of Team Cymru.
+func lookupASN(ctx context.Context, host string) (string, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addrs, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return "", err
+		}
+		ip = addrs[0]
+	}
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return "", errors.New("private address")
+	}
+
+	// e.g. "13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11"
+	origin, err := cymruTXT(ctx, cymruOriginName(ip))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(origin[0])
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no AS in the TXT record %q", strings.Join(origin, "|"))
+	}
+	asn := fields[0]
+	country := strings.TrimSpace(origin[2])
+	// e.g. "13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US"
+	info, err := cymruTXT(ctx, "AS"+asn+".asn.cymru.com")
+	if err != nil || len(info) < 5 {
+		return fmt.Sprintf("AS%s, %s", asn, country), nil
+	}
+	name, _, _ := strings.Cut(strings.TrimSpace(info[4]), ",")
+	name, _, _ = strings.Cut(name, " ")
+	return fmt.Sprintf("AS%s %s, %s", asn, name, country), nil
+}
+
+// cymruOriginName is the name to query for the origin AS of ip.
+func cymruOriginName(ip net.IP) string {
+	var labels []string
+	if ip4 := ip.To4(); ip4 != nil {
+		for i := 3; i >= 0; i-- {
+			labels = append(labels, fmt.Sprint(ip4[i]))
+		}
+		return strings.Join(labels, ".") + ".origin.asn.cymru.com"
+	}
+	for i := 15; i >= 0; i-- {
+		labels = append(labels, fmt.Sprintf("%x.%x", ip[i]&0xf, ip[i]>>4))
+	}
+	return strings.Join(labels, ".") + ".origin6.asn.cymru.com"
+}
+
+// cymruTXT returns the fields of the TXT record of name.
+func cymruTXT(ctx context.Context, name string) ([]string, error) {
+	txts, err := net.DefaultResolver.LookupTXT(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(txts) == 0 {
+		return nil, fmt.Errorf("no TXT record for %s", name)
+	}
+	fields := strings.Split(txts[0], "|")
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("bad TXT record for %s: %q", name, txts[0])
+	}
+	return fields, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Echo probe payloads are authenticated with HMAC-SHA256, so reflectors shared
+// by an organization only answer its own agents and agents discard replies
+// they did not ask for. Layout:
+//
+//	magic "NCK1" | seq uint32 | send time int64 (unix ns) | HMAC-SHA256
+const (
+	authMagic      = "NCK1"
+	authHeaderLen  = len(authMagic) + 4 + 8
+	authPayloadLen = authHeaderLen + sha256.Size
+)
+
+var errBadSignature = errors.New("bad payload signature")
+
+// signPayload returns a probe payload carrying seq and sent, signed with key.
+// An empty key still detects corrupted payloads but authenticates nothing.
+func signPayload(key []byte, seq uint32, sent time.Time) []byte {
+	b := make([]byte, authHeaderLen, authPayloadLen)
+	copy(b, authMagic)
+	binary.BigEndian.PutUint32(b[4:], seq)
+	binary.BigEndian.PutUint64(b[8:], uint64(sent.UnixNano()))
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return mac.Sum(b)
+}
+
+// verifyPayload checks the signature of a payload built by signPayload and
+// returns the sequence number and send time it carries.
+func verifyPayload(key []byte, b []byte) (uint32, time.Time, error) {
+	if len(b) != authPayloadLen || string(b[:len(authMagic)]) != authMagic {
+		return 0, time.Time{}, errBadSignature
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b[:authHeaderLen])
+	if !hmac.Equal(mac.Sum(nil), b[authHeaderLen:]) {
+		return 0, time.Time{}, errBadSignature
+	}
+
+	seq := binary.BigEndian.Uint32(b[4:])
+	sent := time.Unix(0, int64(binary.BigEndian.Uint64(b[8:])))
+	return seq, sent, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+var baselinePath = flag.String("baseline", "",
+	"draw the median and 95th percentile RTTs of every target in this recording, made with -record, across its graph")
+
+// baseline are the median and 95th percentile RTTs in ms of a target in a
+// recording, drawn as reference lines.
+type baseline struct {
+	p50, p95 float64
+}
+
+// baselines are those of -baseline, by target.
+var baselines = map[string]baseline{}
+
+// loadBaseline reads the baselines of the targets of a recording.
+func loadBaseline(path string) error {
+	r, err := readRecording(path)
+	if err != nil {
+		return err
+	}
+	for _, t := range r.targets {
+		if rtts := r.rtts(t); len(rtts) > 0 {
+			baselines[t] = baseline{percentile(rtts, 50), percentile(rtts, 95)}
+		}
+	}
+	if len(baselines) == 0 {
+		return fmt.Errorf("%s: no replies recorded", path)
+	}
+	return nil
+}
+
+// lastStats are the stats of a target in the last session it was probed in,
+// to compare the current ones to what is usual on start.
+type lastStats struct {
+	Time time.Time `json:"time"`
+	Avg  float64   `json:"avg_ms"`
+	P95  float64   `json:"p95_ms"`
+}
+
+func (l lastStats) String() string {
+	return fmt.Sprintf("baseline: avg %s, p95 %s last time", formatMs(l.Avg), formatMs(l.P95))
+}
+
+// lastStatsPath is where the stats of the last sessions are kept, e.g.
+// ~/.cache/netcheck/last.json.
+func lastStatsPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "netcheck", "last.json")
+}
+
+// readLastStats returns the stats of the last sessions by target, none if
+// they cannot be read.
+func readLastStats() map[string]lastStats {
+	last := map[string]lastStats{}
+	if b, err := os.ReadFile(lastStatsPath()); err == nil {
+		json.Unmarshal(b, &last)
+	}
+	return last
+}
+
+// lastSessions are the stats read on start.
+var lastSessions = readLastStats()
+
+// saveLastStats records the stats of the targets that got replies in the
+// session, keeping those of the other targets. The p95 is that of the
+// history, where older replies are merged into their highest RTT, so it errs
+// on the high side in long sessions.
+func saveLastStats(all []*series) error {
+	path := lastStatsPath()
+	if path == "" {
+		return nil
+	}
+	last := readLastStats()
+	now := time.Now()
+	for _, s := range all {
+		if s.target.scheme == "delta" || s.target.unit() != "" || s.stats.received() == 0 {
+			continue
+		}
+		rtts := make([]float64, len(s.history))
+		for i, p := range s.history {
+			rtts[i] = p.rtt
+		}
+		last[s.target.String()] = lastStats{Time: now, Avg: ms(s.stats.avg()), P95: percentile(rtts, 95)}
+	}
+	b, err := json.MarshalIndent(last, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// percentile returns the p-th percentile of data, which must not be empty.
+func percentile(data []float64, p float64) float64 {
+	sorted := slices.Clone(data)
+	slices.Sort(sorted)
+	return sorted[int(math.Ceil(p/100*float64(len(sorted))))-1]
+}
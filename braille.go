@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// brailleDots are the bits of the braille pattern characters for the dots in
+// their two columns and four rows.
+var brailleDots = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// plotBraille draws data like asciigraph.Plot but with braille characters,
+// each holding two points and four vertical steps, for higher resolution.
+func plotBraille(data []float64, height int, maxValue float64, caption string) string {
+	if maxValue <= 0 {
+		maxValue = 1
+	}
+	width := (len(data) + 1) / 2
+	cells := make([][]rune, height)
+	for i := range cells {
+		cells[i] = make([]rune, width)
+	}
+
+	dots := height * 4
+	level := func(v float64) int {
+		return int(math.Round(math.Min(v, maxValue) / maxValue * float64(dots-1)))
+	}
+	for x, v := range data {
+		// join with the previous point with a vertical run of dots
+		from, to := level(v), level(v)
+		if x > 0 {
+			from = level(data[x-1])
+			if from < to {
+				from++
+			} else if from > to {
+				from--
+			}
+		}
+		if from > to {
+			from, to = to, from
+		}
+		for y := from; y <= to; y++ {
+			row := dots - 1 - y
+			cells[row/4][x/2] |= brailleDots[x%2][row%4]
+		}
+	}
+
+	// keep sub-millisecond LAN latencies readable
+	precision := 0
+	if maxValue < 10 {
+		precision = 1
+	}
+	labelWidth := len(fmt.Sprintf("%.*f", precision, maxValue))
+	var b strings.Builder
+	for i, row := range cells {
+		top := maxValue * float64(height-i) / float64(height)
+		fmt.Fprintf(&b, " %*.*f ┤", labelWidth, precision, top)
+		for _, c := range row {
+			b.WriteRune(0x2800 + c)
+		}
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(&b, "%s%s", strings.Repeat(" ", labelWidth+3), caption)
+	return b.String()
+}
@@ -0,0 +1,7 @@
+//go:build minimal
+
+package main
+
+// minimalBuild makes the plain line output the default. Build with
+// -tags minimal for routers and other embedded targets.
+const minimalBuild = true
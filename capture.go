@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var captureDir = flag.String("capture", "",
+	"capture packets with tcpdump into this directory when a target goes down or spikes, for deep debugging")
+var captureFor = flag.Duration("capture-for", 30*time.Second, "how long captures last")
+var captureKeep = flag.Int("capture-keep", 10, "how many capture files to keep, deleting the oldest")
+
+// captureSnapLen keeps the headers of the packets captured, enough to debug
+// and small enough to capture busy links.
+const captureSnapLen = "256"
+
+// captures are the interfaces being captured, at most one capture at a time
+// each.
+var captures = struct {
+	sync.Mutex
+	running map[string]bool
+}{running: map[string]bool{}}
+
+// startCapture captures the packets of the interface t goes out from for
+// -capture-for into a file of -capture, unless it is being captured
+// already. It runs tcpdump, as libpcap needs cgo.
+func startCapture(t target, reason string) {
+	if *captureDir == "" {
+		return
+	}
+	iface := t.iface
+	if iface == "" {
+		var ok bool
+		if iface, ok = routeInterface(hostOf(t)); !ok {
+			return
+		}
+	}
+	captures.Lock()
+	if captures.running[iface] {
+		captures.Unlock()
+		return
+	}
+	captures.running[iface] = true
+	captures.Unlock()
+
+	now := time.Now()
+	name := fmt.Sprintf("netcheck-%s-%s-%s.pcap", now.Format("20060102-150405"),
+		strings.Trim(unsafeFileChars.ReplaceAllString(t.String(), "_"), "_"), reason)
+	path := filepath.Join(*captureDir, name)
+	events.add(now, fmt.Sprintf("capturing packets on %s for %s to %s", iface, *captureFor, path))
+	go func() {
+		defer resetOnPanic()
+		defer func() {
+			captures.Lock()
+			delete(captures.running, iface)
+			captures.Unlock()
+		}()
+		if err := capture(iface, path); err != nil {
+			events.add(time.Now(), "cannot capture packets: "+err.Error())
+		}
+		pruneCaptures()
+	}()
+}
+
+// capture runs tcpdump on iface for -capture-for, stopping it like Control-C
+// does so that it writes everything it captured.
+func capture(iface, path string) error {
+	if err := os.MkdirAll(*captureDir, 0o755); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *captureFor)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "tcpdump", "-i", iface, "-s", captureSnapLen, "-w", path)
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = 5 * time.Second
+	out, err := cmd.CombinedOutput()
+	if err != nil && ctx.Err() == nil {
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if last := lines[len(lines)-1]; last != "" {
+			return fmt.Errorf("%v: %s", err, last)
+		}
+		return err
+	}
+	return nil
+}
+
+// pruneCaptures deletes the oldest captures beyond -capture-keep.
+func pruneCaptures() {
+	paths, _ := filepath.Glob(filepath.Join(*captureDir, "netcheck-*.pcap"))
+	slices.Sort(paths) // by time, as named
+	for len(paths) > max(*captureKeep, 1) {
+		os.Remove(paths[0])
+		paths = paths[1:]
+	}
+}
+
+// routeInterface returns the name of the interface of the route to host.
+func routeInterface(host string) (string, bool) {
+	local, err := localAddrTo(host)
+	if err != nil {
+		return "", false
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", false
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if n, ok := addr.(*net.IPNet); ok && n.IP.Equal(local) {
+				return iface.Name, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is a subcommand of netcheck, given after its flags.
+type command struct {
+	name, args, help string
+	// probes is set for the commands that probe and display targets, which
+	// take the flags of netcheck after their name too, e.g.
+	// "netcheck hub -listen :9900".
+	probes bool
+}
+
+// commands are the subcommands of netcheck. Without one, netcheck runs, i.e.
+// probes the targets given as arguments.
+var commands = []command{
+	{name: "run", args: "[flags] [target...]", help: "probe the targets and graph them, the default", probes: true},
+	{name: "status", args: "[-format f] [target...]", help: "print a line with the RTT and loss of the targets, for status bars"},
+	{name: "sweep", args: "[-count n] [-parallel n] [-rate n] targets.txt...", help: "probe many targets a few times and list them from the best"},
+	{name: "setup", help: "pick the targets, probe interval and thresholds, and write the config"},
+	{name: "doctor", help: "check the privileges, network and terminal netcheck needs"},
+	{name: "compare", args: "[-html compare.html] a.nck b.nck [more.nck...]", help: "compare sessions recorded with -record"},
+	{name: "hdr-merge", args: "out.hgrm in.hgrm...", help: "add up the RTT histograms written by -hdr"},
+	{name: "nat", args: "[-server host:port]", help: "classify the NAT with STUN, and tell whether hole punching works"},
+	{name: "mtu", args: "<target>", help: "find the path MTU to a target"},
+	{name: "dns-bench", args: "[flags] [resolver...]", help: "compare the resolution time of DNS resolvers", probes: true},
+	{name: "discover", args: "[flags]", help: "find the hosts of the local network and pick some to probe", probes: true},
+	{name: "reflect", args: "[-listen address]", help: "answer the requests of reflect:// targets"},
+	{name: "agent", args: "-hub url [flags] [target...]", help: "probe the targets for a hub to show, without display", probes: true},
+	{name: "hub", args: "[-listen address] [flags] [target...]", help: "show the targets of the agents that connect, and its own", probes: true},
+	{name: "completion", args: "bash|zsh|fish", help: "print the shell completion script"},
+	{name: "help", help: "show this help"},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// parseCommand returns the command given after the flags, run by default,
+// and its arguments, after parsing the flags that follow the commands that
+// probe.
+func parseCommand() (string, []string) {
+	c, ok := findCommand(flag.Arg(0))
+	if !ok {
+		return "run", flag.Args()
+	}
+	if c.probes {
+		flag.CommandLine.Parse(flag.Args()[1:])
+		return c.name, flag.Args()
+	}
+	return c.name, flag.Args()[1:]
+}
+
+// usage prints the commands and the flags of netcheck.
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: netcheck [flags] [command] [target...]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  netcheck %s\n", strings.TrimSpace(c.name+" "+c.args))
+		fmt.Fprintf(w, "    \t%s\n", c.help)
+	}
+	fmt.Fprintf(w, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// runCompletion implements "netcheck completion bash|zsh|fish": it prints a
+// script completing the commands and flags of netcheck, and file names, e.g.
+// for recordings.
+func runCompletion(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: netcheck completion bash|zsh|fish")
+		return 2
+	}
+	switch args[0] {
+	case "bash":
+		bashCompletion(os.Stdout)
+	case "zsh":
+		zshCompletion(os.Stdout)
+	case "fish":
+		fishCompletion(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "no completion for %q, only bash, zsh and fish\n", args[0])
+		return 2
+	}
+	return 0
+}
+
+// firstLine returns the first sentence of the usage of a flag, short enough
+// for completion menus.
+func firstLine(usage string) string {
+	usage, _, _ = strings.Cut(usage, ", ")
+	return usage
+}
+
+// isBoolFlag reports whether f takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func bashCompletion(w io.Writer) {
+	var names, flags []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	flag.VisitAll(func(f *flag.Flag) { flags = append(flags, "-"+f.Name) })
+	fmt.Fprintf(w, `# netcheck completion for bash: source <(netcheck completion bash)
+_netcheck() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	if [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	elif [[ $COMP_CWORD -eq 1 ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -f -- "$cur"))
+	fi
+}
+complete -o default -F _netcheck netcheck
+`, strings.Join(flags, " "), strings.Join(names, " "))
+}
+
+// zshEscaper escapes the descriptions of zsh completion specs.
+var zshEscaper = strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+
+func zshCompletion(w io.Writer) {
+	fmt.Fprintln(w, "#compdef netcheck\n# netcheck completion for zsh: netcheck completion zsh > \"${fpath[1]}/_netcheck\"")
+	fmt.Fprintln(w, "_netcheck() {\n\tlocal -a commands\n\tcommands=(")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t\t'%s:%s'\n", c.name, zshEscaper.Replace(c.help))
+	}
+	fmt.Fprintln(w, "\t)\n\t_arguments \\")
+	flag.VisitAll(func(f *flag.Flag) {
+		value := ":value:"
+		if isBoolFlag(f) {
+			value = ""
+		}
+		fmt.Fprintf(w, "\t\t'-%s[%s]%s' \\\n", f.Name, zshEscaper.Replace(firstLine(f.Usage)), value)
+	})
+	fmt.Fprintln(w, "\t\t'1: :{_describe command commands; _files}' \\\n\t\t'*:target or file:_files'\n}\n\n_netcheck \"$@\"")
+}
+
+// fishEscaper escapes the descriptions of fish completions.
+var fishEscaper = strings.NewReplacer(`\`, `\\`, "'", `\'`)
+
+func fishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# netcheck completion for fish: netcheck completion fish > ~/.config/fish/completions/netcheck.fish")
+	for _, c := range commands {
+		fmt.Fprintf(w, "complete -c netcheck -n __fish_use_subcommand -a %s -d '%s'\n", c.name, fishEscaper.Replace(c.help))
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		value := " -r"
+		if isBoolFlag(f) {
+			value = ""
+		}
+		fmt.Fprintf(w, "complete -c netcheck -o %s%s -d '%s'\n", f.Name, value, fishEscaper.Replace(firstLine(f.Usage)))
+	})
+}
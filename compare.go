@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// compareAlpha is the significance level below which a difference between
+// two recordings is reported as a change.
+const compareAlpha = 0.05
+
+// runCompare implements "netcheck compare a.nck b.nck": it compares the RTTs
+// and losses of every target in two recordings made with -record, e.g. from
+// before and after a router firmware update. With -html it also writes a
+// dashboard overlaying them, and any further recordings, by time of day.
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	html := fs.String("html", "", "also write an HTML dashboard overlaying the recordings by time of day to this file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 2 || (fs.NArg() > 2 && *html == "") {
+		fmt.Fprintln(os.Stderr, "usage: netcheck compare [-html compare.html] a.nck b.nck [more.nck...]")
+		return 2
+	}
+	var recordings []*recording
+	for _, path := range fs.Args() {
+		r, err := readRecording(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		recordings = append(recordings, r)
+	}
+	printComparison(os.Stdout, recordings[0], recordings[1])
+	if *html != "" {
+		if err := writeDashboard(*html, fs.Args(), recordings); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// printComparison writes a table comparing every target of a and b: the
+// shift of their median and 95th percentile RTTs, with the p-value of a
+// Mann-Whitney U test, and the change of their loss, with the p-value of a
+// two-proportion z-test.
+func printComparison(w io.Writer, a, b *recording) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "target\tsamples\tp50\tp95\trtt p\tloss\tloss p\tchange\t")
+	targets := a.targets
+	for _, t := range b.targets {
+		if _, ok := a.samples[t]; !ok {
+			targets = append(targets, t)
+		}
+	}
+	for _, t := range targets {
+		sa, sb := a.samples[t], b.samples[t]
+		if len(sa) == 0 || len(sb) == 0 {
+			fmt.Fprintf(tw, "%s\t%d → %d\tonly in one recording\n", t, len(sa), len(sb))
+			continue
+		}
+		ra, rb := a.rtts(t), b.rtts(t)
+		p50, p95, rttP, rttVerdict := "-", "-", "-", ""
+		if len(ra) > 0 && len(rb) > 0 {
+			p50 = shiftText(percentile(ra, 50), percentile(rb, 50))
+			p95 = shiftText(percentile(ra, 95), percentile(rb, 95))
+			p := mannWhitney(ra, rb)
+			rttP = fmt.Sprintf("%.3f", p)
+			if p < compareAlpha {
+				rttVerdict = "slower"
+				if percentile(rb, 50) < percentile(ra, 50) {
+					rttVerdict = "faster"
+				}
+			}
+		}
+		lostA, lostB := len(sa)-len(ra), len(sb)-len(rb)
+		lossA, lossB := 100*float64(lostA)/float64(len(sa)), 100*float64(lostB)/float64(len(sb))
+		lossP := twoProportions(lostA, len(sa), lostB, len(sb))
+		lossVerdict := ""
+		if lossP < compareAlpha {
+			lossVerdict = "more loss"
+			if lossB < lossA {
+				lossVerdict = "less loss"
+			}
+		}
+		verdict := rttVerdict
+		if verdict == "" {
+			verdict = lossVerdict
+		} else if lossVerdict != "" {
+			verdict += ", " + lossVerdict
+		}
+		if verdict == "" {
+			verdict = "no significant change"
+		}
+		fmt.Fprintf(tw, "%s\t%d → %d\t%s\t%s\t%s\t%.1f%% → %.1f%%\t%.3f\t%s\n", t, len(sa), len(sb), p50, p95, rttP,
+			lossA, lossB, lossP, verdict)
+	}
+	tw.Flush()
+}
+
+// shiftText describes how an RTT in ms changed from a to b.
+func shiftText(a, b float64) string {
+	return fmt.Sprintf("%s → %s (%+.1f ms)", formatMs(a), formatMs(b), b-a)
+}
+
+// mannWhitney returns the two-sided p-value of the Mann-Whitney U test of a
+// and b coming from the same distribution, with the normal approximation,
+// which holds for the tens of samples and more of a recording.
+func mannWhitney(a, b []float64) float64 {
+	type value struct {
+		v     float64
+		fromA bool
+	}
+	all := make([]value, 0, len(a)+len(b))
+	for _, v := range a {
+		all = append(all, value{v, true})
+	}
+	for _, v := range b {
+		all = append(all, value{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// ties get the average of their ranks, and shrink the variance
+	var rankA, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for _, x := range all[i:j] {
+			if x.fromA {
+				rankA += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+	n1, n2 := float64(len(a)), float64(len(b))
+	n := n1 + n2
+	u := rankA - n1*(n1+1)/2
+	sigma := math.Sqrt(n1 * n2 / 12 * (n + 1 - ties/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := (u - n1*n2/2) / sigma
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// twoProportions returns the two-sided p-value of the z-test of x1 of n1 and
+// x2 of n2 being the same proportion.
+func twoProportions(x1, n1, x2, n2 int) float64 {
+	p := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(p * (1 - p) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return 1
+	}
+	z := (float64(x1)/float64(n1) - float64(x2)/float64(n2)) / se
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
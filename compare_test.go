@@ -0,0 +1,39 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMannWhitney(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		want float64
+	}{
+		{"separated", []float64{1, 2, 3}, []float64{4, 5, 6}, 0.0495},
+		{"separated, swapped", []float64{4, 5, 6}, []float64{1, 2, 3}, 0.0495},
+		{"interleaved", []float64{1, 3, 5}, []float64{2, 4, 6}, 0.5127},
+		// ranks 1.5, 1.5, 3.5 against 3.5, 5.5, 5.5, with a smaller variance
+		{"ties", []float64{1, 1, 2}, []float64{2, 3, 3}, 0.0679},
+		{"all equal", []float64{7, 7, 7}, []float64{7, 7, 7}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mannWhitney(tt.a, tt.b); math.Abs(got-tt.want) > 0.0001 {
+				t.Errorf("mannWhitney() = %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMannWhitneyLargeShift(t *testing.T) {
+	var a, b []float64
+	for i := 0; i < 20; i++ {
+		a = append(a, float64(i))
+		b = append(b, float64(100+i))
+	}
+	if got := mannWhitney(a, b); got > 1e-6 {
+		t.Errorf("mannWhitney() = %g for disjoint samples of 20", got)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var configPath = flag.String("config", "",
+	"read settings from this JSON file, netcheck/config.json in the user config directory by default")
+
+// config is the file of settings read on start. Flags given on the command
+// line take precedence over the ones in the file.
+type config struct {
+	Flags    map[string]string     `json:"flags,omitempty"`   // flag values by name, e.g. "rtt-threshold": "100ms"
+	Targets  []string              `json:"targets,omitempty"` // probed when none are given as arguments
+	Schedule []scheduleRule        `json:"schedule,omitempty"`
+	Theme    *themeConfig          `json:"theme,omitempty"` // for -theme custom
+	SLOs     []sloRule             `json:"slos,omitempty"`
+	Alerts   map[string]*alertRule `json:"alerts,omitempty"` // by alert name
+	Mute     []muteRule            `json:"mute,omitempty"`
+
+	// the flags that goroutines besides the main one read, as the config
+	// left them, since only the main one may read the flags themselves
+	// once the config can be reloaded, except for the startFlags
+	adaptive, followDNS                   bool
+	warmup                                int
+	alertFor, alertCooldown, rttThreshold time.Duration
+	lossThreshold, conntrackThreshold     float64
+}
+
+// startFlags are flags that goroutines besides the main one read as they
+// start or for every probe, which reloading the config cannot change.
+var startFlags = map[string]bool{
+	"public-ip": true, "stun": true, "ip-echo": true, "router-ip": true,
+	"size": true, "ttl": true, "dns-query": true, "dns-node-every": true,
+	"grpc-service": true, "snmp-community": true, "snmp-if": true,
+	"capture": true, "capture-for": true, "capture-keep": true,
+	"preset": true, // sets the probe interval
+}
+
+// settings is the config read on start, and whenever it changes.
+var settings config
+
+// settingsMu guards settings for the goroutines that read it besides the
+// main one, which replaces it.
+var settingsMu sync.RWMutex
+
+// currentSettings returns the settings, for goroutines besides the main
+// one.
+func currentSettings() config {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return settings
+}
+
+// defaultConfigPath is where the config is read from without -config, e.g.
+// ~/.config/netcheck/config.json.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "netcheck", "config.json")
+}
+
+// cmdlineFlags are the flags given on the command line, which the config
+// does not override, also when reloaded.
+var cmdlineFlags map[string]bool
+
+// loadConfig reads the config and sets the flags it has that were not given
+// on the command line, resetting the ones a previous config set that it does
+// not anymore. A missing default config is not an error. Every flag is
+// checked before the config is applied, so a bad value keeps the current
+// config whole, and when reloading the startFlags must not change.
+func loadConfig(reload bool) error {
+	path := *configPath
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	c := settings
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && *configPath == "":
+		// keep the current one
+	case err != nil:
+		return err
+	default:
+		c = config{}
+		if err := json.Unmarshal(b, &c); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err := c.parse(); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	if cmdlineFlags == nil {
+		cmdlineFlags = map[string]bool{}
+		flag.Visit(func(f *flag.Flag) { cmdlineFlags[f.Name] = true })
+	}
+	for name, value := range c.Flags {
+		if cmdlineFlags[name] {
+			continue
+		}
+		if err := checkFlag(name, value); err != nil {
+			return fmt.Errorf("%s: flag %s: %v", path, name, err)
+		}
+	}
+	for name := range startFlags {
+		value, ok := c.Flags[name]
+		old, wasSet := settings.Flags[name]
+		if reload && !cmdlineFlags[name] && (ok != wasSet || value != old) {
+			return fmt.Errorf("%s: flag %s: only read on start, restart netcheck to change it", path, name)
+		}
+	}
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	// the values to go back to if the flags are not valid together
+	previous := map[string]string{}
+	set := func(name, value string) {
+		if _, ok := previous[name]; !ok {
+			previous[name] = flag.Lookup(name).Value.String()
+		}
+		flag.Set(name, value) // checked above
+	}
+	for name, value := range c.Flags {
+		if !cmdlineFlags[name] {
+			set(name, value)
+		}
+	}
+	for name := range settings.Flags {
+		if _, ok := c.Flags[name]; !ok && !cmdlineFlags[name] {
+			set(name, flag.Lookup(name).DefValue)
+		}
+	}
+	if err := checkFlags(); err != nil {
+		for name, value := range previous {
+			flag.Set(name, value)
+		}
+		return err
+	}
+	c.adaptive, c.followDNS, c.warmup = *adaptive, *followDNS, *warmup
+	c.alertFor, c.alertCooldown = *alertFor, *alertCooldown
+	c.rttThreshold, c.lossThreshold = *rttThreshold, *lossThreshold
+	c.conntrackThreshold = *conntrackThreshold
+	settings = c
+	return nil
+}
+
+// checkFlag returns why value cannot be set to the flag named name, if it
+// cannot, without setting it.
+func checkFlag(name, value string) error {
+	f := flag.Lookup(name)
+	if f == nil {
+		return errors.New("no such flag")
+	}
+	if name == "allow-exec" {
+		return errors.New("only allowed on the command line")
+	}
+	var err error
+	switch f.Value.(flag.Getter).Get().(type) {
+	case bool:
+		_, err = strconv.ParseBool(value)
+	case int:
+		_, err = strconv.ParseInt(value, 0, strconv.IntSize)
+	case float64:
+		_, err = strconv.ParseFloat(value, 64)
+	case time.Duration:
+		_, err = time.ParseDuration(value)
+	}
+	return err
+}
+
+// parse validates the rules of the config.
+func (c *config) parse() error {
+	for i := range c.Schedule {
+		if err := c.Schedule[i].parse(); err != nil {
+			return fmt.Errorf("schedule: %v", err)
+		}
+	}
+	for i := range c.SLOs {
+		if err := c.SLOs[i].parse(); err != nil {
+			return fmt.Errorf("slo: %v", err)
+		}
+	}
+	for i := range c.Mute {
+		if err := c.Mute[i].parse(); err != nil {
+			return fmt.Errorf("mute: %v", err)
+		}
+	}
+	for name, r := range c.Alerts {
+		if err := r.parse(); err != nil {
+			return fmt.Errorf("alert %s: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckFlag(t *testing.T) {
+	tests := []struct {
+		name, value string
+		wantErr     bool
+	}{
+		{"rtt-threshold", "100ms", false},
+		{"rtt-threshold", "100", true},
+		{"loss-threshold", "2.5", false},
+		{"loss-threshold", "lots", true},
+		{"count", "10", false},
+		{"count", "1.5", true},
+		{"adaptive", "true", false},
+		{"adaptive", "maybe", true},
+		{"renderer", "anything", false}, // checked by checkFlags once set
+		{"no-such-flag", "1", true},
+		{"allow-exec", "true", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			if err := checkFlag(tt.name, tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("checkFlag(%q, %q) = %v, want error %v", tt.name, tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	names := []string{"rtt-threshold", "loss-threshold", "ema", "size", "max-inflight"}
+	saved := map[string]string{}
+	for _, name := range names {
+		saved[name] = flag.Lookup(name).Value.String()
+	}
+	savedPath, savedSettings, savedCmdline := *configPath, settings, cmdlineFlags
+	t.Cleanup(func() {
+		for name, value := range saved {
+			flag.Set(name, value)
+		}
+		*configPath, settings, cmdlineFlags = savedPath, savedSettings, savedCmdline
+	})
+	*configPath, settings = path, config{}
+	cmdlineFlags = map[string]bool{"ema": true} // as if given on the command line
+
+	// every step loads the config after the previous one, and checks the
+	// flags it leaves
+	steps := []struct {
+		name    string
+		config  string
+		reload  bool
+		wantErr bool
+		want    map[string]string
+	}{
+		{"on start", `{"flags": {"rtt-threshold": "100ms", "loss-threshold": "5", "size": "100"}}`, false, false,
+			map[string]string{"rtt-threshold": "100ms", "loss-threshold": "5", "size": "100"}},
+		{"flag removed", `{"flags": {"rtt-threshold": "50ms", "size": "100"}}`, true, false,
+			map[string]string{"rtt-threshold": "50ms", "loss-threshold": "0", "size": "100"}},
+		{"bad value", `{"flags": {"rtt-threshold": "x", "loss-threshold": "5", "size": "100"}}`, true, true,
+			map[string]string{"rtt-threshold": "50ms", "loss-threshold": "0"}},
+		{"invalid together", `{"flags": {"rtt-threshold": "10ms", "max-inflight": "0", "size": "100"}}`, true, true,
+			map[string]string{"rtt-threshold": "50ms", "max-inflight": "32"}},
+		{"start flag changed", `{"flags": {"rtt-threshold": "10ms", "size": "200"}}`, true, true,
+			map[string]string{"rtt-threshold": "50ms", "size": "100"}},
+		{"start flag removed", `{"flags": {"rtt-threshold": "10ms"}}`, true, true,
+			map[string]string{"rtt-threshold": "50ms", "size": "100"}},
+		{"command line kept", `{"flags": {"ema": "0.5", "size": "100"}}`, true, false,
+			map[string]string{"rtt-threshold": "0s", "ema": "0"}},
+		{"not json", `{"flags": `, true, true,
+			map[string]string{"rtt-threshold": "0s"}},
+	}
+	for _, step := range steps {
+		if err := os.WriteFile(path, []byte(step.config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		err := loadConfig(step.reload)
+		if (err != nil) != step.wantErr {
+			t.Errorf("%s: loadConfig() = %v, want error %v", step.name, err, step.wantErr)
+		}
+		for name, want := range step.want {
+			if got := flag.Lookup(name).Value.String(); got != want {
+				t.Errorf("%s: -%s = %s, want %s", step.name, name, got, want)
+			}
+		}
+	}
+	if settings.rttThreshold != 0 || settings.Flags["size"] != "100" {
+		t.Errorf("settings left with -rtt-threshold %s and -size %q", settings.rttThreshold, settings.Flags["size"])
+	}
+}
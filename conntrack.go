@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+var conntrackThreshold = flag.Float64("conntrack", 0,
+	"alert when the conntrack table of this Linux router is above this percentage of its limit, 0 to not watch it")
+
+const conntrackCheckInterval = 10 * time.Second
+
+// conntrackTable is the usage of the connection tracking table of netfilter,
+// which NAT needs an entry of for every connection.
+type conntrackTable struct {
+	count, max uint64
+	drops      uint64 // new connections dropped for want of an entry, ever
+}
+
+func (c conntrackTable) usage() float64 {
+	if c.max == 0 {
+		return 0
+	}
+	return 100 * float64(c.count) / float64(c.max)
+}
+
+// conntrackTarget is what conntrack alerts are about.
+var conntrackTarget = target{scheme: "conntrack", address: "nf_conntrack", label: "conntrack table", group: "nf_conntrack"}
+
+// watchConntrack raises an alert while the conntrack table is fuller than
+// -conntrack, and logs an event when it drops connections, a common hidden
+// cause of intermittent connection failures behind home routers.
+func watchConntrack(ctx context.Context) {
+	defer resetOnPanic()
+	if currentSettings().conntrackThreshold <= 0 {
+		return
+	}
+	last, err := readConntrack()
+	if err != nil {
+		events.add(time.Now(), "cannot watch the conntrack table: "+err.Error())
+		return
+	}
+	var breached bool
+
+	ticker := time.NewTicker(conntrackCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c, err := readConntrack()
+			if err != nil {
+				continue
+			}
+			if c.drops > last.drops {
+				events.add(now, fmt.Sprintf("conntrack table dropped %d new connections", c.drops-last.drops))
+			}
+			if (c.usage() >= currentSettings().conntrackThreshold) != breached {
+				breached = !breached
+				a := alert{time: now, target: conntrackTarget, name: "conntrack", resolved: !breached}
+				if breached {
+					a.text = fmt.Sprintf("conntrack table %.0f%% full, %d of %d entries", c.usage(), c.count, c.max)
+				} else {
+					a.text = fmt.Sprintf("conntrack table back to %.0f%% full", c.usage())
+				}
+				raiseAlert(a)
+			}
+			last = c
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readConntrack reads the conntrack table usage from procfs, and the drops
+// of every CPU from /proc/net/stat/nf_conntrack, in hex after a header line.
+func readConntrack() (conntrackTable, error) {
+	var c conntrackTable
+	for path, dst := range map[string]*uint64{
+		"/proc/sys/net/netfilter/nf_conntrack_count": &c.count,
+		"/proc/sys/net/netfilter/nf_conntrack_max":   &c.max,
+	} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+		if *dst, err = strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64); err != nil {
+			return c, err
+		}
+	}
+
+	f, err := os.Open("/proc/net/stat/nf_conntrack")
+	if err != nil {
+		return c, nil // older kernels, without the drops
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	var names []string
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if names == nil {
+			names = fields
+			continue
+		}
+		for i, name := range names {
+			if i < len(fields) && (name == "drop" || name == "early_drop" || name == "insert_failed") {
+				n, _ := strconv.ParseUint(fields[i], 16, 64)
+				c.drops += n
+			}
+		}
+	}
+	return c, scanner.Err()
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func readConntrack() (conntrackTable, error) {
+	return conntrackTable{}, errors.New("conntrack is only watched on Linux")
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type dashboardData struct {
+	Generated  time.Time
+	Targets    []string
+	Sessions   []dashboardSession
+	Comparison string
+}
+
+type dashboardSession struct {
+	Name   string                     `json:"name"`
+	Color  string                     `json:"color"`
+	Series map[string]dashboardSeries `json:"series"`
+}
+
+type dashboardSeries struct {
+	Points [][2]float64 `json:"points"` // ms since the midnight the session started on, RTT in ms
+	Lost   []float64    `json:"lost"`   // ms since the midnight the session started on
+}
+
+// timeOfDay returns the ms from the midnight of day to t, above a day for
+// sessions going past midnight, so that sessions of different days line up
+// by the time of day.
+func timeOfDay(day, t time.Time) float64 {
+	y, m, d := day.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, day.Location())
+	return float64(t.Sub(midnight).Milliseconds())
+}
+
+// writeDashboard writes a single file HTML dashboard overlaying the RTTs of
+// every target in several recordings by time of day, e.g. of the same
+// evening before and after an ISP or router change, with the comparison of
+// every recording against the first one.
+func writeDashboard(path string, paths []string, recordings []*recording) error {
+	data := dashboardData{Generated: time.Now()}
+	seen := map[string]bool{}
+	for i, r := range recordings {
+		s := dashboardSession{
+			Name:   filepath.Base(paths[i]),
+			Color:  reportColors[i%len(reportColors)],
+			Series: map[string]dashboardSeries{},
+		}
+		for _, t := range r.targets {
+			if !seen[t] {
+				seen[t] = true
+				data.Targets = append(data.Targets, t)
+			}
+			samples := r.samples[t]
+			day := samples[0].Time.Local()
+			var series dashboardSeries
+			for _, smp := range samples {
+				at := timeOfDay(day, smp.Time.Local())
+				if smp.Lost {
+					series.Lost = append(series.Lost, at)
+				} else {
+					series.Points = append(series.Points, [2]float64{at, smp.RTT})
+				}
+			}
+			s.Series[t] = series
+		}
+		data.Sessions = append(data.Sessions, s)
+	}
+	var comparison bytes.Buffer
+	for i, r := range recordings[1:] {
+		comparison.WriteString(filepath.Base(paths[0]) + " → " + filepath.Base(paths[i+1]) + "\n")
+		printComparison(&comparison, recordings[0], r)
+		comparison.WriteString("\n")
+	}
+	data.Comparison = comparison.String()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := dashboardTemplate.Execute(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>netcheck comparison {{.Generated.Format "2006-01-02 15:04"}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+canvas { width: 100%; height: 360px; border: 1px solid #ccc; }
+#legend span { margin-right: 1.5em; cursor: pointer; user-select: none; }
+#legend span.off { opacity: 0.3; }
+#tip { position: absolute; background: #fff; border: 1px solid #888; padding: 2px 6px; font-size: 12px; pointer-events: none; display: none; }
+pre { background: #f4f4f4; padding: 1em; }
+</style>
+</head>
+<body>
+<h1>netcheck comparison</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}. The recordings are aligned by the time of day they started on. Click a recording to hide it, drag to zoom in, double click to zoom out. Lost probes are marked at the bottom.</p>
+<p><label>Target <select id="target">{{range .Targets}}<option>{{.}}</option>{{end}}</select></label></p>
+<div id="legend"></div>
+<canvas id="chart"></canvas>
+<div id="tip"></div>
+<h2>Comparison</h2>
+<pre>{{.Comparison}}</pre>
+<script>
+const sessions = {{.Sessions}} || [];
+const canvas = document.getElementById("chart"), ctx = canvas.getContext("2d");
+const tip = document.getElementById("tip"), select = document.getElementById("target");
+let hidden = new Set(), view = null, drag = null;
+
+const day = 24 * 3600 * 1000;
+const clock = v => new Date(v % day).toISOString().substr(11, 5) + (v >= day ? " +" + Math.floor(v / day) + "d" : "");
+const series = () => sessions.map(s => s.series[select.value] || {points: [], lost: []});
+
+sessions.forEach((s, i) => {
+  const span = document.createElement("span");
+  span.textContent = "■ " + s.name;
+  span.style.color = s.color;
+  span.onclick = () => { hidden.has(i) ? hidden.delete(i) : hidden.add(i); span.classList.toggle("off"); draw(); };
+  document.getElementById("legend").appendChild(span);
+});
+
+function full() {
+  const all = series().flatMap((t, i) => hidden.has(i) ? [] : t.points.map(p => p[0]).concat(t.lost || []));
+  return all.length ? [Math.min(...all), Math.max(...all)] : null;
+}
+
+function scales() {
+  const [from, to] = view || full();
+  let max = 1;
+  series().forEach((t, i) => { if (!hidden.has(i)) t.points.forEach(p => { if (p[0] >= from && p[0] <= to) max = Math.max(max, p[1]); }); });
+  const w = canvas.width, h = canvas.height, pad = 40;
+  return {from, to, max,
+    x: v => pad + (v - from) / Math.max(to - from, 1) * (w - pad - 10),
+    y: v => h - 20 - v / max * (h - 40),
+    t: px => from + (px - pad) / (w - pad - 10) * (to - from)};
+}
+
+function draw() {
+  canvas.width = canvas.clientWidth * devicePixelRatio;
+  canvas.height = canvas.clientHeight * devicePixelRatio;
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  if (!full()) return;
+  const s = scales();
+  ctx.fillStyle = "#888";
+  ctx.font = 11 * devicePixelRatio + "px sans-serif";
+  for (let i = 0; i <= 4; i++) {
+    const v = s.max * i / 4;
+    ctx.fillText(v.toFixed(v < 10 ? 1 : 0), 2, s.y(v));
+  }
+  ctx.fillText(clock(s.from), s.x(s.from), canvas.height - 4);
+  ctx.fillText(clock(s.to), s.x(s.to) - 60, canvas.height - 4);
+  series().forEach((t, i) => {
+    if (hidden.has(i)) return;
+    ctx.strokeStyle = ctx.fillStyle = sessions[i].color;
+    ctx.beginPath();
+    t.points.forEach((p, j) => j ? ctx.lineTo(s.x(p[0]), s.y(p[1])) : ctx.moveTo(s.x(p[0]), s.y(p[1])));
+    ctx.stroke();
+    (t.lost || []).forEach(l => { if (l >= s.from && l <= s.to) ctx.fillRect(s.x(l), canvas.height - 18, 2, 6); });
+  });
+}
+
+function nearest(px) {
+  const s = scales(), at = s.t(px);
+  let best = null;
+  series().forEach((t, i) => {
+    if (hidden.has(i)) return;
+    t.points.forEach(p => { if (!best || Math.abs(p[0] - at) < Math.abs(best.p[0] - at)) best = {s: sessions[i], p}; });
+  });
+  return best;
+}
+
+canvas.onmousemove = e => {
+  const px = e.offsetX * devicePixelRatio, b = nearest(px);
+  if (!b) return;
+  tip.style.display = "block";
+  tip.style.left = e.pageX + 12 + "px";
+  tip.style.top = e.pageY + 12 + "px";
+  tip.textContent = b.s.name + " " + clock(b.p[0]) + " " + b.p[1].toFixed(1) + " ms";
+};
+canvas.onmouseleave = () => tip.style.display = "none";
+canvas.onmousedown = e => drag = scales().t(e.offsetX * devicePixelRatio);
+canvas.onmouseup = e => {
+  const to = scales().t(e.offsetX * devicePixelRatio);
+  if (drag !== null && Math.abs(to - drag) > 1000) view = [Math.min(drag, to), Math.max(drag, to)];
+  drag = null;
+  draw();
+};
+canvas.ondblclick = () => { view = null; draw(); };
+select.onchange = () => { view = null; draw(); };
+window.onresize = draw;
+draw();
+</script>
+</body>
+</html>
+`))
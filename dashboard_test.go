@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeOfDay(t *testing.T) {
+	day := time.Date(2026, 10, 16, 20, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		time time.Time
+		want time.Duration
+	}{
+		{"start", day, 20*time.Hour + 30*time.Minute},
+		{"same day", day.Add(time.Hour), 21*time.Hour + 30*time.Minute},
+		{"past midnight", day.Add(4 * time.Hour), 24*time.Hour + 30*time.Minute},
+		{"other day", time.Date(2026, 10, 20, 20, 30, 0, 0, time.UTC), 4*24*time.Hour + 20*time.Hour + 30*time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeOfDay(day, tt.time); got != float64(tt.want.Milliseconds()) {
+				t.Errorf("timeOfDay() = %v, want %v", got, tt.want.Milliseconds())
+			}
+		})
+	}
+}
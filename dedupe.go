@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// dedupeTargets merges the targets that probe the same host the same way,
+// e.g. the gateway and its address given as an argument, or a host name and
+// the address it resolves to, into the first of them, with the labels of
+// all, so the host is not probed twice.
+func dedupeTargets(targets []target) []target {
+	var merged []target
+	first := map[target]int{} // by the resolved target, index in merged
+	for _, t := range targets {
+		key, ok := resolvedTarget(t)
+		if !ok {
+			merged = append(merged, t)
+			continue
+		}
+		i, dup := first[key]
+		if !dup {
+			first[key] = len(merged)
+			merged = append(merged, t)
+			continue
+		}
+		m := &merged[i]
+		for _, label := range []string{t.label, t.group} {
+			if label != "" && label != m.group && !m.hasLabel(label) {
+				m.label = strings.TrimPrefix(m.label+", "+label, ", ")
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s and %s are the same host, probing it once\n", m, t)
+	}
+	return merged
+}
+
+// resolvedTarget returns t with the address of its host resolved and
+// without its descriptions, for targets probing the same host to be equal.
+func resolvedTarget(t target) (target, bool) {
+	if pt, ok := probeTypes[t.scheme]; !ok || pt.opaque {
+		return target{}, false
+	}
+	host, port, err := net.SplitHostPort(t.address)
+	if err != nil {
+		host, port = t.address, ""
+	}
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return target{}, false
+	}
+	t.address = ip.String()
+	if port != "" {
+		t.address = net.JoinHostPort(ip.String(), port)
+	}
+	t.label, t.group = "", ""
+	return t, true
+}
+
+// hasLabel reports whether label is one of the labels of t, which has
+// several when it stands for merged targets.
+func (t target) hasLabel(label string) bool {
+	for _, l := range strings.Split(t.label, ", ") {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package main
+
+import "flag"
+
+var deltaLine = flag.Bool("delta", false,
+	"also plot the RTT of the second target minus the first, by default CloudFlare minus the gateway, i.e. the latency beyond the router")
+
+// deltaSeries derives the RTT of the path between two targets, e.g. the
+// segment beyond the router from the replies of the gateway and CloudFlare,
+// to tell whether a spike happens inside the LAN or upstream.
+type deltaSeries struct {
+	near, far *series
+	series    *series
+	nearLast  sample // latest reply of near
+}
+
+func newDeltaSeries(near, far *series) *deltaSeries {
+	t := target{scheme: "delta", address: far.target.address + " − " + near.target.address}
+	return &deltaSeries{near: near, far: far, series: newSeries(t)}
+}
+
+// add updates the delta with a sample of any series. A reply of far is
+// paired with the latest reply of near if it is recent enough, and the
+// difference is floored at zero, as jitter on the near path can exceed it.
+func (d *deltaSeries) add(s *series, smp sample) {
+	if smp.lost() {
+		return
+	}
+	switch s {
+	case d.near:
+		d.nearLast = smp
+	case d.far:
+		window := 2 * scheduledInterval(smp.time)
+		if *adaptive {
+			window *= adaptiveRange
+		}
+		if d.nearLast.time.IsZero() || smp.time.Sub(d.nearLast.time).Abs() > window {
+			return
+		}
+		rtt := smp.rtt - d.nearLast.rtt
+		if rtt < 0 {
+			rtt = 0
+		}
+		d.series.last = sample{target: d.series.target, seq: smp.seq, time: smp.time, rtt: rtt}
+		d.series.recent.push(ms(rtt))
+		d.series.history = append(d.series.history, point{time: smp.time, rtt: ms(rtt), n: 1})
+		d.series.downsample(smp.time)
+	}
+}
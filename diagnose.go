@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// diagnoseWindow is the period of the latest samples the diagnosis
+	// looks at, and diagnoseMinSent how many probes it needs in it.
+	diagnoseWindow  = 30 * time.Second
+	diagnoseMinSent = 5
+	// diagnoseLoss is the loss percentage, and diagnoseSlower the RTT
+	// increase over the average of the session, of a degraded target.
+	diagnoseLoss   = 5
+	diagnoseSlower = 20 // ms
+)
+
+// health is how a target did during the diagnosis window.
+type health int
+
+const (
+	unknown health = iota // too few probes to tell
+	healthy
+	degraded // slow or losing probes
+	down     // no replies
+)
+
+// healthOf classifies the latest samples of s: degraded when losing probes,
+// twice as slow as usual, or above -rtt-threshold.
+func healthOf(s *series, now time.Time) health {
+	avg, sent, lost := s.period(now.Add(-diagnoseWindow), now)
+	switch {
+	case sent < diagnoseMinSent || s.target.unit() != "":
+		return unknown
+	case lost == sent:
+		return down
+	case 100*float64(lost)/float64(sent) >= diagnoseLoss:
+		return degraded
+	case *rttThreshold > 0 && avg > ms(*rttThreshold):
+		return degraded
+	}
+	if usual := ms(s.stats.avg()); avg > 2*usual && avg-usual >= diagnoseSlower {
+		return degraded
+	}
+	return healthy
+}
+
+// local reports whether t is in the local network, e.g. the gateway.
+func local(t target) bool {
+	if t.hasLabel("gateway") {
+		return true
+	}
+	ip := net.ParseIP(t.address)
+	return ip != nil && (ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast())
+}
+
+// wireless reports whether the interface named name is Wi-Fi, as far as
+// Linux tells, or its name suggests elsewhere.
+func wireless(name string) bool {
+	if _, err := os.Stat("/sys/class/net/" + name + "/wireless"); err == nil {
+		return true
+	}
+	return strings.HasPrefix(name, "wl")
+}
+
+// diagnose looks at the health of all the targets together and returns
+// hints of where the problem appears to be, if any.
+func diagnose(all []*series, now time.Time) []string {
+	var localOK, localBad, upOK, upBad, dnsBad, otherOK []*series
+	ifaceOK, ifaceBad := map[string]bool{}, map[string]bool{}
+	downs, known := 0, 0
+	for _, s := range all {
+		h := healthOf(s, now)
+		if h == unknown {
+			continue
+		}
+		known++
+		if h == down {
+			downs++
+		}
+		bad := h != healthy
+		if i := s.target.iface; i != "" {
+			ifaceOK[i] = ifaceOK[i] || !bad
+			ifaceBad[i] = ifaceBad[i] || bad
+		}
+		switch {
+		case s.target.scheme == "dns":
+			if bad {
+				dnsBad = append(dnsBad, s)
+			}
+			continue
+		case local(s.target) && bad:
+			localBad = append(localBad, s)
+		case local(s.target):
+			localOK = append(localOK, s)
+		case bad:
+			upBad = append(upBad, s)
+		default:
+			upOK = append(upOK, s)
+		}
+		if !bad {
+			otherOK = append(otherOK, s)
+		}
+	}
+	if known == 0 {
+		return nil
+	}
+	if downs == known {
+		return []string{"no target replies: this machine appears to be offline, check its cable or Wi-Fi connection"}
+	}
+
+	var hints []string
+	if len(ifaceOK) > 1 {
+		for i, bad := range ifaceBad {
+			if bad && !ifaceOK[i] {
+				link := "the " + i + " link"
+				if wireless(i) {
+					link = "Wi-Fi (" + i + ")"
+				}
+				hints = append(hints, fmt.Sprintf("only the targets via %s are affected: the problem is on that link, not the network", link))
+			}
+		}
+	}
+	switch {
+	case len(localBad) > 0:
+		hints = append(hints, fmt.Sprintf("%s slow or losing probes: the problem is between this machine and the router, e.g. weak Wi-Fi or a bad cable", names(localBad)))
+	case len(localOK) > 0 && len(upBad) > 0 && len(upOK) == 0:
+		hints = append(hints, fmt.Sprintf("%s fine but every target beyond is not: the problem appears to be beyond your router, at your ISP", names(localOK)))
+	case len(upBad) > 0 && len(upOK) > 0:
+		hints = append(hints, fmt.Sprintf("only %s affected: the problem appears to be on its side or the path to it, not your connection", names(upBad)))
+	}
+	if len(dnsBad) > 0 && len(otherOK) > 0 && len(localBad)+len(upBad) == 0 {
+		hints = append(hints, "DNS is slow but the network is fine: the resolver appears to be the problem, compare others with netcheck dns-bench")
+	}
+	return hints
+}
+
+// names lists the targets of the given series.
+func names(all []*series) string {
+	var names []string
+	for _, s := range all {
+		names = append(names, s.target.String())
+	}
+	if len(names) == 1 {
+		return names[0] + " is"
+	}
+	return strings.Join(names, ", ") + " are"
+}
+
+// diagnosis logs the hints of diagnose as events when they change.
+type diagnosis struct {
+	checked time.Time
+	hints   []string
+}
+
+func (d *diagnosis) check(all []*series, now time.Time) {
+	if now.Sub(d.checked) < time.Second {
+		return
+	}
+	d.checked = now
+	hints := diagnose(all, now)
+	for _, h := range hints {
+		if !slices.Contains(d.hints, h) {
+			events.add(now, "hint: "+h)
+		}
+	}
+	d.hints = hints
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackpal/gateway"
+)
+
+// discoverParallel is how many hosts "netcheck discover" pings at a time.
+const discoverParallel = 64
+
+// ouiVendors are the makers of common home and office devices, by the first
+// three bytes of their MAC addresses.
+var ouiVendors = map[string]string{
+	"00:0c:29": "VMware",
+	"00:50:56": "VMware",
+	"08:00:27": "VirtualBox",
+	"52:54:00": "QEMU",
+	"00:11:32": "Synology",
+	"b8:27:eb": "Raspberry Pi",
+	"dc:a6:32": "Raspberry Pi",
+	"e4:5f:01": "Raspberry Pi",
+	"00:17:88": "Philips Hue",
+	"00:0e:58": "Sonos",
+	"24:a4:3c": "Ubiquiti",
+	"18:b4:30": "Nest",
+}
+
+// discovered is a host found on the local subnet.
+type discovered struct {
+	ip     net.IP
+	rtt    time.Duration // zero when it is only in the neighbor table
+	name   string
+	mac    string
+	vendor string
+}
+
+// discoverTargets implements "netcheck discover": it pings every address of
+// the subnet of the gateway, lists the hosts that replied or that the
+// system resolved a MAC address for, and returns the ones picked to
+// monitor.
+func discoverTargets(args []string) ([]target, error) {
+	if len(args) > 0 {
+		return nil, fmt.Errorf("usage: netcheck discover")
+	}
+	gatewayIP, err := gateway.DiscoverGateway()
+	if err != nil {
+		return nil, err
+	}
+	iface, subnet, err := localSubnet(gatewayIP)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Scanning %s on %s...\n", subnet, iface)
+	hosts := sweep(subnet)
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts found on %s", subnet)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, h := range hosts {
+		rtt := "no ping reply"
+		if h.rtt > 0 {
+			rtt = formatRTT(h.rtt)
+		}
+		fmt.Fprintf(tw, "%3d\t%s\t%s\t%s\t%s\t%s\n", i+1, h.ip, rtt, h.name, h.mac, h.vendor)
+	}
+	tw.Flush()
+
+	fmt.Print("Monitor which hosts? (e.g. 1,3-5, nothing to quit): ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	picked, err := parsePicks(strings.TrimSpace(line), len(hosts))
+	if err != nil {
+		return nil, err
+	}
+	var targets []target
+	for _, i := range picked {
+		h := hosts[i]
+		label := h.name
+		if label == "" {
+			label = h.vendor
+		}
+		targets = append(targets, target{scheme: "icmp", address: h.ip.String(), label: label, group: h.ip.String()})
+	}
+	return targets, nil
+}
+
+// localSubnet returns the interface reaching ip and its IPv4 subnet,
+// narrowed to the /24 around its address when larger.
+func localSubnet(ip net.IP) (string, *net.IPNet, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, iface := range ifaces {
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			n, ok := addr.(*net.IPNet)
+			if !ok || n.IP.To4() == nil || !n.Contains(ip) {
+				continue
+			}
+			if ones, _ := n.Mask.Size(); ones < 24 {
+				n = &net.IPNet{IP: n.IP, Mask: net.CIDRMask(24, 32)}
+			}
+			return iface.Name, &net.IPNet{IP: n.IP.Mask(n.Mask), Mask: n.Mask}, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no interface on the subnet of %s", ip)
+}
+
+// sweep pings every host address of subnet, and returns the hosts that
+// replied or are in the neighbor table afterwards, with their names.
+func sweep(subnet *net.IPNet) []discovered {
+	ones, bits := subnet.Mask.Size()
+	first := binary.BigEndian.Uint32(subnet.IP.To4())
+	size := uint32(1) << (bits - ones)
+
+	var mu sync.Mutex
+	found := map[string]*discovered{}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, discoverParallel)
+	for i := uint32(1); i < size-1; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, first+i)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer resetOnPanic()
+			defer wg.Done()
+			defer func() { <-sem }()
+			p, err := newPinger(target{scheme: "icmp", address: ip.String()})
+			if err != nil {
+				return
+			}
+			defer p.conn.Close()
+			for seq := 1; seq <= 2; seq++ {
+				if rtt, err := p.ping(seq, probeTimeout); err == nil {
+					mu.Lock()
+					found[ip.String()] = &discovered{ip: ip, rtt: rtt}
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// hosts that drop pings still answer ARP
+	for ipText, mac := range neighbors() {
+		ip := net.ParseIP(ipText)
+		if ip == nil || !subnet.Contains(ip) {
+			continue
+		}
+		if found[ipText] == nil {
+			found[ipText] = &discovered{ip: ip}
+		}
+		found[ipText].mac = mac
+		found[ipText].vendor = macVendor(mac)
+	}
+
+	hosts := make([]discovered, 0, len(found))
+	for _, h := range found {
+		hosts = append(hosts, *h)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return bytes.Compare(hosts[i].ip.To4(), hosts[j].ip.To4()) < 0 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for i := range hosts {
+		wg.Add(1)
+		go func() {
+			defer resetOnPanic()
+			defer wg.Done()
+			if names, err := net.DefaultResolver.LookupAddr(ctx, hosts[i].ip.String()); err == nil && len(names) > 0 {
+				hosts[i].name = strings.TrimSuffix(names[0], ".")
+			}
+		}()
+	}
+	wg.Wait()
+	return hosts
+}
+
+// macVendor names the maker of the device with the given MAC address, if
+// known.
+func macVendor(mac string) string {
+	if len(mac) < 8 {
+		return ""
+	}
+	if vendor, ok := ouiVendors[mac[:8]]; ok {
+		return vendor
+	}
+	// phones and laptops make up addresses to not be tracked
+	if b, err := strconv.ParseUint(mac[:2], 16, 8); err == nil && b&0x02 != 0 {
+		return "private address"
+	}
+	return ""
+}
+
+// parsePicks parses a list of 1-based indexes and ranges like "1,3-5" into
+// 0-based indexes below n.
+func parsePicks(s string, n int) ([]int, error) {
+	var picked []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(from)
+		hi := lo
+		if err == nil && isRange {
+			hi, err = strconv.Atoi(to)
+		}
+		if err != nil || lo < 1 || hi > n || lo > hi {
+			return nil, fmt.Errorf("bad pick %q, numbers go from 1 to %d", part, n)
+		}
+		for i := lo; i <= hi; i++ {
+			picked = append(picked, i-1)
+		}
+	}
+	return picked, nil
+}
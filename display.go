@@ -0,0 +1,592 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buger/goterm"
+	"github.com/fatih/color"
+	"github.com/jesseduffield/asciigraph"
+)
+
+// screen is the state of the interactive display.
+type screen struct {
+	all          []*series
+	start        func(target) *series // starts probing a new target
+	selected     int                  // index in all of the selected target
+	prompt       *string              // target being typed, when adding one, or marker label
+	marking      bool                 // the prompt is for the label of a marker
+	max          float64              // highest RTT in ms, the top of the graphs
+	ownScale     bool                 // scale every graph to its own highest RTT instead
+	eventsScroll int                  // how many events back from the latest the log shows
+	scroll       int                  // how many replies back from the latest the graphs show
+	heatmap      bool                 // show the per-minute heatmap instead of the graphs
+	clear        bool                 // the layout changed, clear leftovers of the old one
+	delta        *deltaSeries         // shown under the graphs with -delta
+	segments     *segments            // breakdown shown above the graphs with -segments
+	tab          tab                  // the view shown
+	trace        *trace               // of the selected target, for the hops view
+	zoom         int                  // replies per graph point, changed with the mouse wheel
+	focus        *series              // shown alone when its graph was clicked
+	regions      []region             // where the graphs were drawn, for mouse clicks
+	eventRows    []eventRow           // where the events were drawn, for mouse clicks
+	top          int                  // row where the view starts, viewTop unless the header is dropped
+	lastLayout   layout
+}
+
+// handleKey updates the display state after a key press.
+func (sc *screen) handleKey(k rune) {
+	if sc.prompt != nil {
+		sc.handlePromptKey(k)
+		return
+	}
+
+	switch k {
+	case '1', '2', '3', '4', '5':
+		sc.switchTab(tab(k - '1'))
+	case '\t':
+		sc.switchTab((sc.tab + 1) % tab(len(tabNames)))
+	case 'r':
+		if sc.tab == tabHops {
+			sc.trace = startTrace(sc.all[sc.selected].target)
+			sc.clear = true
+		}
+	case keyUp:
+		if sc.eventsScroll+sc.eventsPage() < events.len() {
+			sc.eventsScroll++
+		}
+	case keyDown:
+		if sc.eventsScroll > 0 {
+			sc.eventsScroll--
+		}
+	case keyLeft:
+		sc.scroll += maxLen / 4 * sc.zoom
+		if longest := sc.longestHistory() - (maxLen-1)*sc.zoom; sc.scroll > longest {
+			sc.scroll = longest
+		}
+		if sc.scroll < 0 {
+			sc.scroll = 0
+		}
+	case keyRight:
+		sc.scroll -= maxLen / 4 * sc.zoom
+		if sc.scroll < 0 {
+			sc.scroll = 0
+		}
+	case 'h':
+		sc.heatmap = !sc.heatmap
+		sc.clear = true
+	case 'y':
+		sc.ownScale = !sc.ownScale
+	case 'u':
+		t := sc.all[sc.selected].target
+		if toggleMute(t) {
+			events.add(time.Now(), "muted the alerts of "+t.String())
+		} else {
+			events.add(time.Now(), "unmuted the alerts of "+t.String())
+		}
+	case 'j':
+		if sc.selected+1 < len(sc.all) {
+			sc.selected++
+		}
+	case 'k':
+		if sc.selected > 0 {
+			sc.selected--
+		}
+	case 's':
+		path := snapshotFile()
+		if err := writeSnapshot(path, sc.all, sc.scroll, sc.max); err != nil {
+			events.add(time.Now(), err.Error())
+		} else {
+			events.add(time.Now(), "saved the graphs to "+path)
+		}
+	case 'a', 'm':
+		input := ""
+		sc.prompt = &input
+		sc.marking = k == 'm'
+	case 'd':
+		if len(sc.all) > 1 {
+			s := sc.all[sc.selected]
+			s.stop()
+			removeFromSinks(s.target)
+			sc.all = append(sc.all[:sc.selected:sc.selected], sc.all[sc.selected+1:]...)
+			if sc.selected == len(sc.all) {
+				sc.selected--
+			}
+			events.add(time.Now(), "removed "+s.target.String())
+			sc.clear = true
+		}
+	}
+}
+
+// handlePromptKey edits the target being added.
+func (sc *screen) handlePromptKey(k rune) {
+	switch k {
+	case '\r', '\n':
+		input := strings.TrimSpace(*sc.prompt)
+		sc.prompt = nil
+		sc.clear = true
+		if sc.marking {
+			if input == "" {
+				input = "marker"
+			}
+			events.mark(time.Now(), input)
+			return
+		}
+		if input == "" {
+			return
+		}
+		targets, err := parseTargets(input)
+		if err != nil {
+			events.add(time.Now(), err.Error())
+			return
+		}
+		for _, t := range targets {
+			sc.all = append(sc.all, sc.start(t))
+		}
+		events.add(time.Now(), "added "+input)
+	case '\x1b':
+		sc.prompt = nil
+		sc.clear = true
+	case '\x7f', '\b':
+		if r := []rune(*sc.prompt); len(r) > 0 {
+			*sc.prompt = string(r[:len(r)-1])
+		}
+	default:
+		if k >= ' ' {
+			*sc.prompt += string(k)
+		}
+	}
+}
+
+func (sc *screen) has(s *series) bool {
+	for _, other := range sc.all {
+		if other == s {
+			return true
+		}
+	}
+	return false
+}
+
+// viewEnd returns the time of the last reply the graphs show when scrolled
+// back, and zero when they show the latest replies.
+func (sc *screen) viewEnd() time.Time {
+	s := sc.longest()
+	if sc.scroll == 0 || s == nil || sc.scroll >= len(s.history) {
+		return time.Time{}
+	}
+	return s.history[len(s.history)-1-sc.scroll].time
+}
+
+// keepView keeps the graphs on the period ending at end, as returned by
+// viewEnd before a reply was added, which moves the latest replies and may
+// merge older ones.
+func (sc *screen) keepView(end time.Time) {
+	if end.IsZero() {
+		return
+	}
+	// the scroll counts points of the longest history
+	s := sc.longest()
+	i := sort.Search(len(s.history), func(i int) bool { return s.history[i].time.After(end) })
+	sc.scroll = len(s.history) - i
+}
+
+// longest returns the series with the longest history, or nil if there are
+// no series.
+func (sc *screen) longest() *series {
+	var longest *series
+	for _, s := range sc.all {
+		if longest == nil || len(s.history) > len(longest.history) {
+			longest = s
+		}
+	}
+	return longest
+}
+
+func (sc *screen) longestHistory() int {
+	if s := sc.longest(); s != nil {
+		return len(s.history)
+	}
+	return 0
+}
+
+// viewTop is the row of the screen where the views start, below the header,
+// the targets and the tab bar.
+const viewTop = 5
+
+// draw displays the current view: by default the graphs of all targets, or
+// their heatmap, followed by the event log. It is drawn into the frame,
+// colors included, then flushed to the terminal at once.
+func (sc *screen) draw() {
+	terminal := color.Output
+	color.Output = &frame
+	defer func() { color.Output = terminal }()
+
+	if sc.clear {
+		frame.clear()
+		sc.clear = false
+	}
+	width, height := goterm.Width(), goterm.Height()
+	lay := sc.layout(width, height)
+	if lay != sc.lastLayout {
+		frame.clear()
+		sc.lastLayout = lay
+	}
+	sc.regions, sc.eventRows = sc.regions[:0], sc.eventRows[:0]
+
+	sc.top = 1
+	if lay.chrome {
+		sc.drawHeader()
+		sc.top = viewTop
+	}
+
+	switch sc.tab {
+	case tabStats:
+		sc.drawStats()
+	case tabEvents:
+		sc.drawEvents()
+	case tabHops:
+		sc.drawHops()
+	case tabConfig:
+		sc.drawConfig()
+	default:
+		if lay.graphHeight == 0 && !sc.heatmap {
+			sc.drawSparklines(width)
+		} else {
+			sc.drawGraphs(lay.graphHeight, width)
+		}
+	}
+	if lay.chrome || sc.prompt != nil {
+		fmt.Fprintln(&frame, "\033[K")
+	}
+
+	color.Set(activeTheme.text...)
+	if sc.prompt != nil && sc.marking {
+		fmt.Fprintf(&frame, "Marker label: %s\033[K\n", *sc.prompt)
+	} else if sc.prompt != nil {
+		fmt.Fprintf(&frame, "Add target: %s\033[K\n", *sc.prompt)
+	} else if lay.chrome {
+		fmt.Fprintln(&frame, truncate("Press 1-5 or Tab to switch views, ← to scroll back, h to toggle the heatmap, y the shared scale, s to save a snapshot, m to mark the timeline, a/d to add/delete and u to mute the selected (j/k) target, Control-C to exit", width))
+	}
+
+	frame.flush(terminal)
+}
+
+// drawHeader shows the header, the targets and the tab bar, up to viewTop.
+func (sc *screen) drawHeader() {
+	color.Set(activeTheme.text...)
+	fmt.Fprint(&frame, header())
+	if q := headlineQuality(sc.all); q != "" {
+		fmt.Fprintf(&frame, " %s", q)
+		color.Set(activeTheme.text...)
+	}
+	fmt.Fprintln(&frame, "\033[K")
+	targets := make([]target, len(sc.all))
+	for i, s := range sc.all {
+		targets[i] = s.target
+	}
+	var names []string
+	for i := 0; i < len(targets); {
+		j := i + 1
+		for j < len(targets) && targets[j].group == targets[i].group {
+			j++
+		}
+		names = append(names, groupName(targets[i:j]))
+		i = j
+	}
+	fmt.Fprintf(&frame, "%s\033[K\n", strings.Join(names, " vs "))
+	fmt.Fprintf(&frame, "%s\033[K\n\n", sc.tabBar())
+}
+
+// drawGraphs shows the graphs of all targets, height rows high, or their
+// heatmap, followed by the latest events.
+func (sc *screen) drawGraphs(height, width int) {
+	row := sc.top
+	if sc.heatmap {
+		row += displayHeatmap(sc.all, width)
+	} else {
+		if sc.scroll > 0 || sc.zoom > 1 {
+			forward := ""
+			if sc.scroll > 0 {
+				forward = ", → to go forward"
+			}
+			fmt.Fprintf(&frame, "History %s%s%s\033[K\n\n", sc.timeRange(), sc.zoomText(), forward)
+			row += 2
+		}
+		if sc.segments != nil {
+			sc.segments.draw(width)
+			row += 2
+		}
+		if sc.focus != nil && !sc.has(sc.focus) {
+			sc.focus = nil
+		}
+		// the series of a group share their color
+		group := -1
+		for i, s := range sc.all {
+			if i == 0 || s.target.group != sc.all[i-1].target.group {
+				group++
+			}
+			if sc.focus != nil && s != sc.focus {
+				continue
+			}
+			color.Set(activeTheme.series[group%len(activeTheme.series)]...)
+			if n, ok := noteOf(s.meta); ok && n.warn {
+				color.Set(activeTheme.warn...)
+			}
+			n := display(s, sc.scaleOf(s), sc.scroll, sc.zoom, height, i == sc.selected)
+			sc.regions = append(sc.regions, region{row, row + n, s})
+			row += n
+		}
+		if sc.delta != nil && sc.focus == nil {
+			color.Set(activeTheme.text...)
+			row += display(sc.delta.series, sc.scaleOf(sc.delta.series), sc.scroll, sc.zoom, height, false)
+		}
+	}
+	sc.drawLatestEvents(row, width)
+}
+
+// drawLatestEvents shows the last events from the given row.
+func (sc *screen) drawLatestEvents(row, width int) {
+	color.Set(activeTheme.text...)
+	if n := events.len(); n > 0 {
+		shown := events.window(sc.eventsScroll, eventsShown)
+		fmt.Fprintf(&frame, "Events (%d of %d, ↑/↓ to scroll):\033[K\n", n-sc.eventsScroll, n)
+		for i, e := range shown {
+			fmt.Fprintf(&frame, "%s\033[K\n", truncate(e.String(), width))
+			sc.eventRows = append(sc.eventRows, eventRow{row + 1 + i, e.time})
+		}
+	}
+}
+
+// scaleOf returns the top of the graph of s: the highest RTT of all targets,
+// so that graphs can be compared, or with y the highest RTT shown for s, so
+// that a fast gateway is not flattened by a slow remote target. Values other
+// than RTTs always get their own scale.
+func (sc *screen) scaleOf(s *series) float64 {
+	if !sc.ownScale && s.target.unit() == "" {
+		return sc.max
+	}
+	data, _ := s.window(sc.scroll, sc.zoom)
+	return max(slices.Max(data), 1)
+}
+
+// zoomText describes how many replies a point of the graphs stands for.
+func (sc *screen) zoomText() string {
+	if sc.zoom == 1 {
+		return ""
+	}
+	return fmt.Sprintf(", %d replies per point", sc.zoom)
+}
+
+// header is the first line of the display, with the ICMP options that apply
+// to every target.
+func header() string {
+	var options []string
+	if *pingSize != 56 {
+		options = append(options, fmt.Sprintf("size %d B", *pingSize))
+	}
+	if *pingTTL > 0 {
+		options = append(options, fmt.Sprintf("ttl %d", *pingTTL))
+	}
+	if options == nil {
+		return "Network check:"
+	}
+	return fmt.Sprintf("Network check (%s):", strings.Join(options, ", "))
+}
+
+// timeRange describes the period the graphs show when scrolled back.
+func (sc *screen) timeRange() string {
+	var from, to time.Time
+	for _, s := range sc.all {
+		_, first := s.window(sc.scroll, sc.zoom)
+		end := len(s.history) - sc.scroll
+		if first >= end || first < 0 {
+			continue
+		}
+		if t := s.history[first].time; from.IsZero() || t.Before(from) {
+			from = t
+		}
+		if t := s.history[end-1].time; t.After(to) {
+			to = t
+		}
+	}
+	if from.IsZero() {
+		return "before the first reply"
+	}
+	return from.Format("15:04:05") + " – " + to.Format("15:04:05")
+}
+
+// groupName describes targets probing the same host.
+func groupName(group []target) string {
+	if len(group) == 1 {
+		t := group[0]
+		var about []string
+		if name, ok := hostnameOf(hostOf(t)); ok {
+			about = append(about, name)
+		}
+		if t.label != "" {
+			about = append(about, t.label)
+		}
+		if about != nil {
+			return fmt.Sprintf("%s (%s)", t, strings.Join(about, ", "))
+		}
+		return t.String()
+	}
+
+	var probes []string
+	for _, t := range group {
+		probes = append(probes, probeNames[t.scheme])
+	}
+	return fmt.Sprintf("%s (%s)", group[0].group, strings.Join(probes, ", "))
+}
+
+// display draws the graph of s and returns how many lines it took.
+func display(s *series, maxValue float64, scroll, zoom, height int, selected bool) int {
+	t := s.target
+	data, first := s.window(scroll, zoom)
+	name := t.String()
+	if host, ok := hostnameOf(hostOf(t)); ok {
+		name += " (" + host + ")"
+	}
+	caption := fmt.Sprintf("%s %s: %s", probeNames[t.scheme], name, s.result())
+	if selected {
+		caption = "▶ " + caption
+	}
+	if n, ok := noteOf(s.meta); ok {
+		caption += ", " + n.text
+	}
+	if !probeTypes[t.scheme].opaque && t.scheme != "delta" {
+		if asn, ok := asnOf(hostOf(t)); ok {
+			caption += ", " + asn
+		}
+	}
+	if mos, ok := s.recentMOS(time.Now()); ok && t.scheme != "delta" {
+		caption += fmt.Sprintf(", MOS %.1f", mos)
+	}
+	for _, o := range s.slos {
+		caption += fmt.Sprintf(", SLO %s %.0f%% of budget used", o.rule, 100*o.used(time.Now()))
+	}
+	if n := len(s.outages); n > 0 {
+		caption += fmt.Sprintf(", %d outages, %s down", n, s.downtime(time.Now()).Round(time.Second))
+	}
+	if muted(t, time.Now()) {
+		caption += ", alerts muted"
+	}
+	if l, ok := lastSessions[t.String()]; ok {
+		caption += ", " + l.String()
+	}
+	base, hasBase := baselines[t.String()]
+	if hasBase {
+		caption += fmt.Sprintf(", baseline p50 %s (-) p95 %s (=)", formatMs(base.p50), formatMs(base.p95))
+	}
+	if s.waiting() {
+		// leave the graph empty rather than plot the zero anchoring the axis
+		// as if it were a reply
+		graph := strings.Repeat("\n", height+1) + "  " + caption
+		fmt.Fprintf(&frame, "%s\n\n", graph)
+		return strings.Count(graph, "\n") + 2
+	}
+	var smoothed []float64
+	if *emaAlpha > 0 {
+		smoothed = ema(data, *emaAlpha)
+		caption += ", ema " + formatValue(t, smoothed[len(smoothed)-1])
+	}
+	var graph string
+	if *renderer == "braille" {
+		// the EMA and bands cannot be told apart from the series with
+		// braille dots, so only the EMA is shown, in the caption
+		graph = plotBraille(data, height, maxValue, caption)
+	} else {
+		graph = asciigraph.Plot(data,
+			asciigraph.Height(height),
+			asciigraph.Caption(caption),
+			asciigraph.Max(maxValue),
+		)
+		if *bands && len(data) > 1 {
+			// the anchoring zero stays out of the percentiles
+			p50 := append([]float64{0}, percentileBand(data[1:], bandWindow, 50)...)
+			p95 := append([]float64{0}, percentileBand(data[1:], bandWindow, 95)...)
+			graph = shade(graph, p50, p95, maxValue, '░')
+		}
+		if smoothed != nil {
+			graph = overlay(graph, smoothed, maxValue, '·')
+		}
+		if hasBase {
+			graph = overlay(graph, constant(len(data), base.p50), maxValue, '-')
+			graph = overlay(graph, constant(len(data), base.p95), maxValue, '=')
+		}
+	}
+	if *gridLines {
+		graph = grid(graph, maxValue, t.unit())
+	}
+	if *timeAxisMode != "none" && len(s.history) > 0 {
+		times := make([]time.Time, (len(data)+pointsPerColumn()-1)/pointsPerColumn())
+		for col := 1; col < len(data); col++ {
+			if i := first + (col-1)*zoom; i >= 0 && i < len(s.history) && times[col/pointsPerColumn()].IsZero() {
+				times[col/pointsPerColumn()] = s.history[i].time
+			}
+		}
+		latest := s.history[len(s.history)-1].time
+		graph = belowPlot(graph, timeAxis(times, latest, *timeAxisMode == "clock"))
+	}
+	marks := map[int]rune{}
+	for _, i := range s.anomalies {
+		if col := (i-first)/zoom + 1; col >= 1 && col < len(data) {
+			marks[col/pointsPerColumn()] = '^'
+		}
+	}
+	// markers go under the first reply after them
+	for _, m := range events.markers() {
+		i := sort.Search(len(s.history), func(i int) bool { return !s.history[i].time.Before(m.time) })
+		if i == len(s.history) {
+			i--
+		}
+		if col := (i-first)/zoom + 1; i >= 0 && col >= 1 && col < len(data) {
+			marks[col/pointsPerColumn()] = '▲'
+		}
+	}
+	if len(marks) > 0 {
+		graph = annotate(graph, marks)
+	}
+	fmt.Fprintf(&frame, "%s\n\n", graph)
+	return strings.Count(graph, "\n") + 2
+}
+
+// bandWindow is how many replies the percentile bands are computed over.
+const bandWindow = 10
+
+// pointsPerColumn is how many samples the graph renderer fits in a column.
+func pointsPerColumn() int {
+	if *renderer == "braille" {
+		return 2
+	}
+	return 1
+}
+
+// formatResult describes the outcome of a probe for captions.
+func formatResult(s sample) string {
+	if s.lost() {
+		// skip the operation and addresses wrapping the actual cause
+		err := s.err
+		for errors.Unwrap(err) != nil {
+			err = errors.Unwrap(err)
+		}
+		return err.Error()
+	}
+	return formatValue(s.target, ms(s.rtt))
+}
+
+// displayLine prints a single uncolored line with a new sample. It uses no
+// escape sequences nor box-drawing characters, so it works on the minimal
+// terminals found on routers.
+func displayLine(s sample) {
+	t := s.target
+	line := fmt.Sprintf("%s  %s %s %s", s.time.Format("15:04:05"), probeNames[t.scheme], t, formatResult(s))
+	if n, ok := noteOf(s.meta); ok {
+		line += ", " + n.text
+	}
+	fmt.Println(line)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+var dnsQueryName = flag.String("dns-query", "example.com",
+	"name that dns:// targets are asked to resolve, or comma separated names to resolve in turn")
+
+// DNS types and classes used by the probes.
+const (
+	dnsTypeA   = 1
+	dnsClassIN = 1
+)
+
+func init() {
+	registerSource("dns", "DNS", "53", dnsSource)
+}
+
+// dnsSource measures how long the DNS server t takes to answer an A query,
+// rotating over the names of -dns-query, and every -dns-node-every which of
+// its anycast nodes answers.
+func dnsSource(ctx context.Context, t target, out chan<- sample) error {
+	names := strings.Split(*dnsQueryName, ",")
+	var node string
+	var asked time.Time
+	return probeEvery(ctx, t, out, func(seq int, meta map[string]string) (time.Duration, error) {
+		name := names[seq%len(names)]
+		rtt, _, err := dnsQuery(t.dialer("udp", probeTimeout), t.address, name, dnsTypeA, dnsClassIN, probeTimeout)
+		if err == nil && *dnsNodeEvery > 0 && time.Since(asked) >= *dnsNodeEvery {
+			asked = time.Now()
+			if n, err := dnsNode(t); err == nil {
+				node = n
+			}
+		}
+		if node != "" {
+			meta["node"] = node
+		}
+		return rtt, err
+	})
+}
+
+// dnsQuery sends a recursive query to server over UDP with d and returns how
+// long the answer took and the raw response, or an error if the server could
+// not resolve name.
+func dnsQuery(d *net.Dialer, server, name string, qtype, qclass uint16, timeout time.Duration) (time.Duration, []byte, error) {
+	query, err := dnsMessage(name, qtype, qclass)
+	if err != nil {
+		return 0, nil, err
+	}
+	return dnsExchange(d, server, query, timeout)
+}
+
+// dnsExchange sends query to server over UDP with d and returns how long the
+// answer took and the raw response.
+func dnsExchange(d *net.Dialer, server string, query []byte, timeout time.Duration) (time.Duration, []byte, error) {
+	conn, err := d.Dial("udp", server)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	conn.SetDeadline(start.Add(timeout))
+	if _, err := conn.Write(query); err != nil {
+		return 0, nil, err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		resp := buf[:n]
+		// ignore datagrams that do not answer our query ID
+		if len(resp) < 12 || resp[0] != query[0] || resp[1] != query[1] || resp[2]&0x80 == 0 {
+			continue
+		}
+		rtt := time.Since(start)
+		if rcode := resp[3] & 0x0f; rcode != 0 {
+			return rtt, resp, fmt.Errorf("DNS error %s", dnsRcodes[rcode])
+		}
+		return rtt, resp, nil
+	}
+}
+
+var dnsRcodes = map[byte]string{
+	1: "FORMERR",
+	2: "SERVFAIL",
+	3: "NXDOMAIN",
+	4: "NOTIMP",
+	5: "REFUSED",
+}
+
+// dnsMessage builds a query asking for recursion.
+func dnsMessage(name string, qtype, qclass uint16) ([]byte, error) {
+	msg := make([]byte, 12, 512)
+	if _, err := rand.Read(msg[:2]); err != nil {
+		return nil, err
+	}
+	msg[2] = 0x01                          // RD
+	binary.BigEndian.PutUint16(msg[4:], 1) // QDCOUNT
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue // the root
+		}
+		if len(label) > 63 {
+			return nil, fmt.Errorf("DNS label too long in %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, qclass)
+	return msg, nil
+}
+
+// dnsRecord is a resource record of a response.
+type dnsRecord struct {
+	qtype      uint16
+	ttl        time.Duration
+	data       []byte
+	additional bool // in the additional section rather than the answer
+}
+
+// dnsAnswers parses the answer section of a response.
+func dnsAnswers(resp []byte) ([]dnsRecord, error) {
+	records, err := dnsRecords(resp)
+	return slices.DeleteFunc(records, func(r dnsRecord) bool { return r.additional }), err
+}
+
+// dnsRecords parses the answer and additional sections of a response.
+func dnsRecords(resp []byte) ([]dnsRecord, error) {
+	if len(resp) < 12 {
+		return nil, errors.New("short DNS response")
+	}
+	questions := binary.BigEndian.Uint16(resp[4:])
+	answers := int(binary.BigEndian.Uint16(resp[6:]))
+	authority := int(binary.BigEndian.Uint16(resp[8:]))
+	additional := int(binary.BigEndian.Uint16(resp[10:]))
+	off := 12
+	for range questions {
+		if off = skipDNSName(resp, off); off < 0 || off+4 > len(resp) {
+			return nil, errors.New("bad DNS question")
+		}
+		off += 4
+	}
+	var records []dnsRecord
+	for i := range answers + authority + additional {
+		if off = skipDNSName(resp, off); off < 0 || off+10 > len(resp) {
+			return records, errors.New("bad DNS record")
+		}
+		r := dnsRecord{
+			qtype:      binary.BigEndian.Uint16(resp[off:]),
+			ttl:        time.Duration(binary.BigEndian.Uint32(resp[off+4:])) * time.Second,
+			additional: i >= answers+authority,
+		}
+		n := int(binary.BigEndian.Uint16(resp[off+8:]))
+		off += 10
+		if off+n > len(resp) {
+			return records, errors.New("bad DNS record")
+		}
+		r.data = resp[off : off+n]
+		off += n
+		if i < answers || r.additional {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// skipDNSName returns the offset after the name at off, -1 if it is bad.
+func skipDNSName(msg []byte, off int) int {
+	for off < len(msg) {
+		switch n := int(msg[off]); {
+		case n == 0:
+			return off + 1
+		case n&0xc0 == 0xc0: // compressed, a pointer ends the name
+			return off + 2
+		default:
+			off += 1 + n
+		}
+	}
+	return -1
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"os"
+	"strings"
+)
+
+// benchDomains are resolved in turn by "netcheck dns-bench", popular enough
+// to be cached by every resolver, as browsing would find them.
+var benchDomains = []string{
+	"google.com", "youtube.com", "facebook.com", "wikipedia.org", "amazon.com",
+	"github.com", "netflix.com", "reddit.com", "apple.com", "microsoft.com",
+}
+
+// benchResolvers are compared with the system resolver by default.
+var benchResolvers = []target{
+	{scheme: "dns", address: "1.1.1.1:53", label: "CloudFlare"},
+	{scheme: "dns", address: "8.8.8.8:53", label: "Google"},
+	{scheme: "dns", address: "9.9.9.9:53", label: "Quad9"},
+}
+
+// dnsBenchTargets implements "netcheck dns-bench [resolver...]": it compares
+// the resolution time of the given resolvers, or of the system resolver and
+// public ones, graphing them side by side while resolving benchDomains.
+func dnsBenchTargets(args []string) ([]target, error) {
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		explicit = explicit || f.Name == "dns-query"
+	})
+	if !explicit {
+		*dnsQueryName = strings.Join(benchDomains, ",")
+	}
+
+	var targets []target
+	for _, arg := range args {
+		t, err := parseTarget("dns://" + strings.TrimPrefix(arg, "dns://"))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) > 0 {
+		return targets, nil
+	}
+
+	for _, server := range systemResolvers() {
+		t, err := parseTarget("dns://" + server)
+		if err != nil {
+			continue
+		}
+		t.label = "system"
+		targets = append(targets, t)
+	}
+	for _, t := range benchResolvers {
+		t.group = t.address
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// systemResolvers returns the name servers in /etc/resolv.conf, usually the
+// ones handed out by DHCP.
+func systemResolvers() []string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
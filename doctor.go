@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/buger/goterm"
+	"github.com/fatih/color"
+	"github.com/jackpal/gateway"
+	"golang.org/x/net/icmp"
+)
+
+const (
+	// doctorSlowDNS is the lookup time above which DNS is reported slow.
+	doctorSlowDNS = 500 * time.Millisecond
+	// doctorClockSkew is the clock offset above which the clock is reported
+	// off, which skews the timestamps of samples and alerts.
+	doctorClockSkew = time.Second
+	doctorNTPServer = "pool.ntp.org:123"
+)
+
+// checkResult is the outcome of a check of "netcheck doctor": a failure
+// keeps netcheck from working, a warning only limits it.
+type checkResult struct {
+	level string // ok, warn or FAIL
+	text  string
+	fix   string // what to do about a warning or a failure
+}
+
+func passed(format string, a ...any) checkResult {
+	return checkResult{level: "ok", text: fmt.Sprintf(format, a...)}
+}
+
+// runDoctor implements "netcheck doctor": it checks what netcheck needs
+// from the system and the network, printing how to fix what is wrong, and
+// fails if something keeps it from working.
+func runDoctor(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: netcheck doctor")
+		return 2
+	}
+	checks := []struct {
+		name  string
+		check func() checkResult
+	}{
+		{"ICMP sockets", checkICMP},
+		{"gateway", checkGateway},
+		{"DNS", checkDNS},
+		{"IPv6", checkIPv6},
+		{"clock", checkClock},
+		{"terminal", checkTerminal},
+	}
+	failed := false
+	for _, c := range checks {
+		r := c.check()
+		fmt.Printf("%-4s  %-12s  %s\n", r.level, c.name, r.text)
+		if r.fix != "" {
+			fmt.Printf("%-4s  %-12s  fix: %s\n", "", "", r.fix)
+		}
+		failed = failed || r.level == "FAIL"
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// checkICMP checks whether netcheck can open ICMP sockets, unprivileged
+// ones, which Linux allows to the groups in net.ipv4.ping_group_range and
+// macOS to everyone, or raw ones.
+func checkICMP() checkResult {
+	if conn, err := icmp.ListenPacket("udp4", ""); err == nil {
+		conn.Close()
+		return passed("unprivileged ICMP sockets are allowed")
+	}
+	conn, err := icmp.ListenPacket("ip4:icmp", "")
+	if err == nil {
+		conn.Close()
+		return passed("raw ICMP sockets are allowed")
+	}
+	r := checkResult{level: "FAIL", text: "cannot open ICMP sockets: " + err.Error(), fix: "run netcheck with sudo"}
+	if runtime.GOOS == "linux" {
+		exe, _ := os.Executable()
+		r.fix = fmt.Sprintf(`sudo sysctl -w net.ipv4.ping_group_range="0 2147483647", or sudo setcap cap_net_raw+ep %s`, exe)
+	}
+	return r
+}
+
+// checkGateway checks that there is a default gateway and that it answers
+// pings.
+func checkGateway() checkResult {
+	ip, err := gateway.DiscoverGateway()
+	if err != nil {
+		return checkResult{level: "FAIL", text: "no default gateway: " + err.Error(),
+			fix: "check the cable or the Wi-Fi connection, and that DHCP gave this machine an address"}
+	}
+	p, err := newPinger(target{scheme: "icmp", address: ip.String()})
+	if err != nil {
+		return checkResult{level: "warn", text: fmt.Sprintf("gateway %s, cannot ping it: %v", ip, err),
+			fix: "see ICMP sockets"}
+	}
+	defer p.conn.Close()
+	for seq := range 3 {
+		if rtt, err := p.ping(seq, probeTimeout); err == nil {
+			return passed("gateway %s answers pings in %s", ip, formatRTT(rtt))
+		}
+	}
+	return checkResult{level: "warn", text: fmt.Sprintf("gateway %s does not answer pings", ip),
+		fix: fmt.Sprintf("some routers drop pings, probe it with tcp://%s:80 instead; otherwise check the cable or Wi-Fi", ip)}
+}
+
+// checkDNS checks that the resolver of the system answers, quickly and
+// correctly, and that it does not rewrite non-existent domains, as some ISPs
+// do to show ads.
+func checkDNS() checkResult {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, "one.one.one.one")
+	took := time.Since(start)
+	if err != nil {
+		return checkResult{level: "FAIL", text: "cannot resolve one.one.one.one: " + err.Error(),
+			fix: "check the DNS servers of the system, e.g. in /etc/resolv.conf, or find a working one with netcheck dns-bench"}
+	}
+	if !slices.Contains(addrs, cloudFlareIP) {
+		return checkResult{level: "warn", text: fmt.Sprintf("one.one.one.one resolves to %s instead of %s", strings.Join(addrs, ", "), cloudFlareIP),
+			fix: "the resolver may be filtering or hijacking queries, compare with netcheck dns-bench"}
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	if _, err := net.DefaultResolver.LookupHost(ctx, hex.EncodeToString(b)+".com"); err == nil {
+		return checkResult{level: "warn", text: "domains that do not exist resolve, the resolver rewrites NXDOMAIN answers",
+			fix: "use another DNS server, e.g. 1.1.1.1, as this breaks search domains and hides typos"}
+	}
+	if took > doctorSlowDNS {
+		return checkResult{level: "warn", text: fmt.Sprintf("resolving one.one.one.one took %s", formatRTT(took)),
+			fix: "find a faster DNS server with netcheck dns-bench"}
+	}
+	return passed("resolving one.one.one.one took %s", formatRTT(took))
+}
+
+// checkIPv6 checks for a global IPv6 address and a route to the Internet
+// over IPv6.
+func checkIPv6() checkResult {
+	global := false
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.To4() == nil && n.IP.IsGlobalUnicast() && !n.IP.IsPrivate() {
+			global = true
+		}
+	}
+	noIPv6 := checkResult{level: "warn", fix: "fine unless you expect IPv6; otherwise enable it on the router, or ask the ISP for it"}
+	if !global {
+		noIPv6.text = "no global IPv6 address"
+		return noIPv6
+	}
+	conn, err := net.Dial("udp6", "[2606:4700:4700::1111]:53")
+	if err != nil {
+		noIPv6.text = "a global IPv6 address but no IPv6 route: " + err.Error()
+		return noIPv6
+	}
+	conn.Close()
+	p, err := newPinger(target{scheme: "icmp", address: "2606:4700:4700::1111"})
+	if err != nil {
+		return passed("a global IPv6 address and route")
+	}
+	defer p.conn.Close()
+	if rtt, err := p.ping(0, probeTimeout); err == nil {
+		return passed("2606:4700:4700::1111 answers pings over IPv6 in %s", formatRTT(rtt))
+	}
+	return checkResult{level: "warn", text: "a global IPv6 address and route, but 2606:4700:4700::1111 does not answer pings",
+		fix: "IPv6 may be broken upstream, which slows down connections that try it first; check the router"}
+}
+
+// checkClock compares the clock to NTP.
+func checkClock() checkResult {
+	conn, err := net.DialTimeout("udp", doctorNTPServer, probeTimeout)
+	if err != nil {
+		return checkResult{level: "warn", text: "cannot reach " + doctorNTPServer + ": " + err.Error()}
+	}
+	defer conn.Close()
+	_, offset, err := ntpQuery(conn, probeTimeout)
+	if err != nil {
+		return checkResult{level: "warn", text: "no answer from " + doctorNTPServer + ": " + err.Error(),
+			fix: "UDP port 123 may be blocked"}
+	}
+	if offset.Abs() > doctorClockSkew {
+		return checkResult{level: "warn", text: fmt.Sprintf("the clock is off by %s", offset.Round(time.Millisecond)),
+			fix: "enable time synchronization, e.g. sudo timedatectl set-ntp true, as timestamps of samples and alerts are off"}
+	}
+	return passed("the clock is within %s of %s", offset.Abs().Round(time.Millisecond), doctorNTPServer)
+}
+
+// checkTerminal checks that the terminal can show the graphs.
+func checkTerminal() checkResult {
+	if fi, err := os.Stdout.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return checkResult{level: "warn", text: "the output is not a terminal",
+			fix: "run netcheck in a terminal for the graphs, or use -plain for logs"}
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return checkResult{level: "warn", text: "TERM is dumb, netcheck prints plain lines",
+			fix: "set TERM, e.g. to xterm-256color, for the graphs"}
+	}
+	var problems []string
+	if color.NoColor {
+		problems = append(problems, "no colors, as NO_COLOR is set or the terminal has none")
+	}
+	if runtime.GOOS != "windows" {
+		locale := os.Getenv("LC_ALL") + os.Getenv("LC_CTYPE") + os.Getenv("LANG")
+		if l := strings.ToUpper(locale); !strings.Contains(l, "UTF-8") && !strings.Contains(l, "UTF8") {
+			problems = append(problems, "the locale is not UTF-8, so graphs may show garbage")
+		}
+	}
+	width, height := goterm.Width(), goterm.Height()
+	if width < 80 || height < 24 {
+		problems = append(problems, fmt.Sprintf("%dx%d is small, the display is reduced", width, height))
+	}
+	if len(problems) > 0 {
+		return checkResult{level: "warn", text: strings.Join(problems, "; "),
+			fix: "use a UTF-8 locale, e.g. LANG=en_US.UTF-8, and a terminal of 80x24 or more"}
+	}
+	return passed("%dx%d, colors and UTF-8", width, height)
+}
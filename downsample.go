@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// historyTiers bound the memory of long sessions: the replies older than
+// after are merged into a point per step, the coarser tiers first. The
+// latest replies are kept as they are.
+var historyTiers = []struct{ after, step time.Duration }{
+	{time.Hour, time.Minute},
+	{10 * time.Minute, 10 * time.Second},
+}
+
+// downsampleEvery is how often the history is downsampled.
+const downsampleEvery = time.Minute
+
+// downsample merges the replies of the history of s that got older than a
+// tier. A merged point has the time of its first reply, the highest RTT, so
+// that spikes are kept as when zooming out, and counts the replies merged.
+func (s *series) downsample(now time.Time) {
+	if now.Sub(s.downsampled) < downsampleEvery {
+		return
+	}
+	s.downsampled = now
+	if s.tierEnds == nil {
+		s.tierEnds = make([]int, len(historyTiers))
+	}
+	for i, tier := range historyTiers {
+		from := s.tierEnds[i]
+		for _, end := range s.tierEnds[:i] {
+			from = max(from, end)
+		}
+		cutoff := now.Add(-tier.after).Truncate(tier.step)
+		to := sort.Search(len(s.history), func(i int) bool { return !s.history[i].time.Before(cutoff) })
+		if to <= from {
+			continue
+		}
+		n := s.merge(from, to, tier.step)
+		for j, end := range s.tierEnds {
+			if end >= to {
+				s.tierEnds[j] = end + n - (to - from)
+			} else if end > from {
+				s.tierEnds[j] = from + n
+			}
+		}
+		s.tierEnds[i] = from + n
+	}
+
+	// lost probes are merged the same way, the coarsest tier last
+	for i := len(historyTiers) - 1; i >= 0; i-- {
+		tier := historyTiers[i]
+		cutoff := now.Add(-tier.after).Truncate(tier.step)
+		lost := s.lost[:0]
+		for _, p := range s.lost {
+			if n := len(lost); p.time.Before(cutoff) && n > 0 && lost[n-1].time.Truncate(tier.step).Equal(p.time.Truncate(tier.step)) {
+				lost[n-1].n += p.n
+				continue
+			}
+			lost = append(lost, p)
+		}
+		s.lost = lost
+	}
+}
+
+// merge replaces the replies in history[from:to] with a point per step, and
+// returns how many points there are now.
+func (s *series) merge(from, to int, step time.Duration) int {
+	merged := s.history[from:from] // in place, as there are fewer points
+	moved := make([]int, to-from)  // where every reply went
+	for i, p := range s.history[from:to] {
+		if n := len(merged); n > 0 && merged[n-1].time.Truncate(step).Equal(p.time.Truncate(step)) {
+			merged[n-1].rtt = max(merged[n-1].rtt, p.rtt)
+			merged[n-1].n += p.n
+		} else {
+			merged = append(merged, p)
+		}
+		moved[i] = from + len(merged) - 1
+	}
+	n := len(merged)
+	s.history = append(s.history[:from+n], s.history[to:]...)
+
+	shift := n - (to - from)
+	anomalies := s.anomalies[:0]
+	for _, a := range s.anomalies {
+		if a >= to {
+			a += shift
+		} else if a >= from {
+			a = moved[a-from]
+		}
+		if len(anomalies) == 0 || anomalies[len(anomalies)-1] != a {
+			anomalies = append(anomalies, a)
+		}
+	}
+	s.anomalies = anomalies
+	return n
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// downsampleTestSeries returns a series with a reply every second for d
+// until now, and every tenth probe lost besides, the RTT of the reply at
+// spike being 100 ms and the others 1 ms.
+func downsampleTestSeries(now time.Time, d time.Duration, spike int) *series {
+	s := &series{}
+	for i := range int(d / time.Second) {
+		at := now.Add(-d + time.Duration(i)*time.Second)
+		rtt := 1.0
+		if i == spike {
+			rtt = 100
+		}
+		s.history = append(s.history, point{time: at, rtt: rtt, n: 1})
+		if i%10 == 0 {
+			s.lost = append(s.lost, point{time: at, n: 1})
+		}
+	}
+	return s
+}
+
+func TestDownsample(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		d          time.Duration
+		spike      int
+		points     int
+		lostPoints int
+	}{
+		{"recent", 5 * time.Minute, 10, 300, 30},
+		{"older than 10 minutes", 20 * time.Minute, 10, 60 + 600, 60 + 60},
+		{"older than an hour", 2 * time.Hour, 10, 60 + 300 + 600, 60 + 300 + 60},
+		{"spike in the 10 s tier", 2 * time.Hour, 3605, 60 + 300 + 600, 60 + 300 + 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := downsampleTestSeries(now, tt.d, tt.spike)
+			s.downsample(now)
+			if len(s.history) != tt.points || len(s.lost) != tt.lostPoints {
+				t.Errorf("%d points and %d of lost probes, want %d and %d", len(s.history), len(s.lost), tt.points, tt.lostPoints)
+			}
+			replies, lost, highest := 0, 0, 0.0
+			for i, p := range s.history {
+				replies += p.n
+				highest = max(highest, p.rtt)
+				if i > 0 && !p.time.After(s.history[i-1].time) {
+					t.Errorf("point %d at %v, not after the one before", i, p.time)
+				}
+			}
+			for _, p := range s.lost {
+				lost += p.n
+			}
+			if want := int(tt.d / time.Second); replies != want || lost != want/10 {
+				t.Errorf("points count %d replies and %d lost probes, want %d and %d", replies, lost, want, want/10)
+			}
+			if highest != 100 {
+				t.Errorf("highest RTT %v, want the spike of 100", highest)
+			}
+
+			_, sent, periodLost := s.period(now.Add(-tt.d), now)
+			if want := int(tt.d/time.Second) * 11 / 10; sent != want || periodLost != want/11 {
+				t.Errorf("period() = %d sent, %d lost, want %d and %d", sent, periodLost, want, want/11)
+			}
+		})
+	}
+}
+
+func TestKeepView(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		scroll int
+	}{
+		{"latest", 0},
+		{"recent", 100},
+		{"merged by downsampling", 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := downsampleTestSeries(now, 20*time.Minute, -1)
+			s.downsampled = now // as if just downsampled without tiers
+			s.history = s.history[:len(s.history)-60]
+			sc := &screen{all: []*series{s}, scroll: tt.scroll}
+			var shown time.Time
+			if tt.scroll > 0 {
+				shown = s.history[len(s.history)-1-tt.scroll].time
+			}
+
+			end := sc.viewEnd()
+			s.history = append(s.history, point{time: now, rtt: 1, n: 1})
+			s.downsample(now.Add(downsampleEvery))
+			sc.keepView(end)
+
+			switch {
+			case tt.scroll == 0 && sc.scroll != 0:
+				t.Errorf("scrolled back by %d, want the latest replies", sc.scroll)
+			case tt.scroll > 0 && s.history[len(s.history)-1-sc.scroll].time.After(shown):
+				t.Errorf("graphs end at %v, after %v", s.history[len(s.history)-1-sc.scroll].time, shown)
+			case tt.scroll > 0 && !s.history[len(s.history)-sc.scroll].time.After(shown):
+				t.Errorf("graphs end before the point of %v", shown)
+			}
+		})
+	}
+}
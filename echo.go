@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+)
+
+// probeKey signs the payloads of echo probes, see signPayload.
+var probeKey []byte
+
+func init() {
+	registerSource("echo", "ECHO", "7", echoSource)
+	registerSource("echo+tcp", "ECHO/TCP", "7", echoSource)
+}
+
+// echoSource sends signed payloads to an echo service or reflector over UDP,
+// or TCP for "echo+tcp" targets, and measures how long the reply takes.
+// Replies that do not carry a valid signature are ignored.
+func echoSource(ctx context.Context, t target, out chan<- sample) error {
+	network := "udp"
+	if t.scheme == "echo+tcp" {
+		network = "tcp"
+	}
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	return probeEvery(ctx, t, out, func(seq int, meta map[string]string) (time.Duration, error) {
+		if conn == nil {
+			var d contextDialer = t.dialer(network, probeTimeout)
+			if network == "tcp" {
+				var err error
+				if d, err = t.streamDialer(probeTimeout); err != nil {
+					return 0, err
+				}
+			}
+			dialCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+			defer cancel()
+			var err error
+			if conn, err = d.DialContext(dialCtx, network, t.address); err != nil {
+				return 0, err
+			}
+		}
+		rtt, err := echo(conn, network == "tcp", uint32(seq), probeTimeout)
+		// a stream may be left in the middle of a payload, start over
+		if err != nil && network == "tcp" {
+			conn.Close()
+			conn = nil
+		}
+		return rtt, err
+	})
+}
+
+func echo(conn net.Conn, stream bool, seq uint32, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write(signPayload(probeKey, seq, start)); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, authPayloadLen)
+	for {
+		var n int
+		var err error
+		if stream {
+			n, err = io.ReadFull(conn, buf)
+		} else {
+			n, err = conn.Read(buf)
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		got, _, err := verifyPayload(probeKey, buf[:n])
+		if err != nil && stream {
+			return 0, err
+		}
+		// datagrams may be forged or late replies to earlier probes
+		if err == nil && got == seq {
+			return time.Since(start), nil
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// eventsShown is how many of the latest events are displayed.
+const eventsShown = 5
+
+// event is something notable that happened during the session.
+type event struct {
+	time   time.Time
+	text   string
+	marker bool // dropped by the user with m, text being its label
+}
+
+func (e event) String() string {
+	if e.marker {
+		return e.time.Format("15:04:05") + " ▲ " + e.text
+	}
+	return e.time.Format("15:04:05") + " " + e.text
+}
+
+// eventLog keeps every event of the session, so they are not lost when the
+// samples that caused them scroll off the graphs.
+type eventLog struct {
+	mu     sync.Mutex
+	events []event
+}
+
+var events eventLog
+
+func (l *eventLog) add(t time.Time, text string) {
+	l.log(event{time: t, text: text})
+}
+
+// mark adds a marker labeled by the user, e.g. "started video call", to
+// correlate spikes with what was going on.
+func (l *eventLog) mark(t time.Time, label string) {
+	l.log(event{time: t, text: label, marker: true})
+}
+
+func (l *eventLog) log(e event) {
+	l.mu.Lock()
+	l.events = append(l.events, e)
+	l.mu.Unlock()
+
+	writeEventSinks(e)
+}
+
+// since returns the events after the first n.
+func (l *eventLog) since(n int) []event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n > len(l.events) {
+		return nil
+	}
+	return append([]event(nil), l.events[n:]...)
+}
+
+// window returns up to n events, ending skip events before the latest one.
+func (l *eventLog) window(skip, n int) []event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	end := len(l.events) - skip
+	if end < 0 {
+		end = 0
+	}
+	start := end - n
+	if start < 0 {
+		start = 0
+	}
+	return append([]event(nil), l.events[start:end]...)
+}
+
+// markers returns the markers of the session.
+func (l *eventLog) markers() []event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var markers []event
+	for _, e := range l.events {
+		if e.marker {
+			markers = append(markers, e)
+		}
+	}
+	return markers
+}
+
+func (l *eventLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.events)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var allowExec = flag.Bool("allow-exec", false,
+	"run the commands of exec:// targets in the config file, not only of the ones given as arguments")
+
+func init() {
+	registerProbe("exec", probeType{name: "EXEC", opaque: true, newProbe: func(t target) probe {
+		return &sourceProbe{name: "EXEC", target: t, source: execSource}
+	}})
+}
+
+// execLatency is the first number in the output of a command, with an
+// optional unit.
+var execLatency = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(ns|us|µs|ms|s)?\b`)
+
+// execSource runs the shell command of t, as in "exec://redis-cli ping", once
+// per probe interval. The latency is the first number the command prints, in
+// milliseconds unless followed by a unit, or how long the command took if it
+// prints none. Commands that fail or time out count as lost.
+func execSource(ctx context.Context, t target, out chan<- sample) error {
+	return probeEvery(ctx, t, out, func(int, map[string]string) (time.Duration, error) {
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+
+		start := time.Now()
+		output, err := shellCommand(ctx, t.address).Output()
+		elapsed := time.Since(start)
+		if ctx.Err() == context.DeadlineExceeded {
+			return 0, errTimeout
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return 0, fmt.Errorf("exit status %d", exitErr.ExitCode())
+		}
+		if err != nil {
+			return 0, err
+		}
+		if latency, ok := parseLatency(string(output)); ok {
+			return latency, nil
+		}
+		return elapsed, nil
+	})
+}
+
+// checkConfigExec returns an error if targets, read from the config file,
+// have exec:// ones without -allow-exec, as whoever can write the config,
+// which is reloaded when it changes, would run commands.
+func checkConfigExec(targets []target) error {
+	for _, t := range targets {
+		if t.scheme == "exec" && !*allowExec {
+			return fmt.Errorf("%s is in the config file, which needs -allow-exec to run commands", t)
+		}
+	}
+	return nil
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// parseLatency finds the latency in the output of a command.
+func parseLatency(output string) (time.Duration, bool) {
+	m := execLatency.FindStringSubmatch(output)
+	if m == nil {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	unit := map[string]time.Duration{
+		"ns": time.Nanosecond, "us": time.Microsecond, "µs": time.Microsecond,
+		"ms": time.Millisecond, "s": time.Second, "": time.Millisecond,
+	}[strings.ToLower(m[2])]
+	return time.Duration(v * float64(unit)), true
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLatency(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   time.Duration
+		ok     bool
+	}{
+		{"bare number", "12", 12 * time.Millisecond, true},
+		{"decimal", "12.5", 12500 * time.Microsecond, true},
+		{"milliseconds", "latency: 3 ms", 3 * time.Millisecond, true},
+		{"seconds", "took 1.5s", 1500 * time.Millisecond, true},
+		{"microseconds", "250us", 250 * time.Microsecond, true},
+		{"micro sign", "250µs", 250 * time.Microsecond, true},
+		{"nanoseconds", "800ns", 800 * time.Nanosecond, true},
+		{"after text", "rtt time=1.5 ms", 1500 * time.Microsecond, true},
+		{"first number", "3 ms, then 9 ms", 3 * time.Millisecond, true},
+		{"no number", "PONG", 0, false},
+		{"empty", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseLatency(tt.output)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseLatency(%q) = %v, %v, want %v, %v", tt.output, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
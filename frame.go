@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// frame is the display being drawn. It is built off-screen and flush only
+// writes the lines that changed since the previous frame, so the terminal
+// never shows a partially drawn one, and lines that got shorter, e.g. when
+// an RTT goes from "100 ms" to "9 ms", leave nothing behind.
+var frame frameBuffer
+
+type frameBuffer struct {
+	buf  bytes.Buffer
+	last []string // lines of the previous frame, with their colors
+	// cleared is set when the screen was cleared, to redraw every line
+	cleared bool
+}
+
+func (f *frameBuffer) Write(p []byte) (int, error) {
+	return f.buf.Write(p)
+}
+
+// clear clears the screen before the next frame is written.
+func (f *frameBuffer) clear() {
+	f.cleared = true
+}
+
+// sgr matches the escape sequences setting colors and text attributes.
+var sgr = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// flush writes the frame to w, moving the cursor to every line that differs
+// from the previous frame. A line starts with the colors set before it, as
+// the lines before it may not be written.
+func (f *frameBuffer) flush(w io.Writer) {
+	var out bytes.Buffer
+	if f.cleared {
+		out.WriteString("\033[2J")
+		f.last, f.cleared = nil, false
+	}
+	lines := strings.Split(f.buf.String(), "\n")
+	f.buf.Reset()
+	colors := ""
+	for i, line := range lines {
+		line = colors + strings.ReplaceAll(line, "\033[K", "")
+		lines[i] = line
+		if seqs := sgr.FindAllString(line, -1); len(seqs) > 0 {
+			colors = seqs[len(seqs)-1]
+		}
+		if i < len(f.last) && f.last[i] == line {
+			continue
+		}
+		fmt.Fprintf(&out, "\033[%d;1H%s\033[K", i+1, line)
+	}
+	if len(lines) < len(f.last) {
+		// clear what is left of a longer frame
+		fmt.Fprintf(&out, "\033[%d;1H\033[J", len(lines)+1)
+	}
+	f.last = lines
+	w.Write(out.Bytes())
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackpal/gateway"
+)
+
+const gatewayCheckInterval = 10 * time.Second
+
+// watchGateway logs an event whenever the default gateway changes, e.g. when
+// switching networks or when a DHCP lease hands out a different router.
+func watchGateway(ctx context.Context) {
+	defer resetOnPanic()
+	last, _ := gateway.DiscoverGateway()
+
+	ticker := time.NewTicker(gatewayCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			ip, err := gateway.DiscoverGateway()
+			switch {
+			case err != nil && last != nil:
+				events.add(now, "gateway lost: "+err.Error())
+			case err == nil && last == nil:
+				events.add(now, "gateway found: "+ip.String())
+			case err == nil && !ip.Equal(last):
+				events.add(now, fmt.Sprintf("gateway changed from %s to %s", last, ip))
+			}
+			if err != nil {
+				ip = nil
+			}
+			last = ip
+		}
+	}
+}
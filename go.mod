@@ -7,12 +7,12 @@ require (
 	github.com/fatih/color v1.9.0
 	github.com/jackpal/gateway v1.0.5
 	github.com/jesseduffield/asciigraph v0.4.2-0.20190605104717-6d88e39309ee
-	github.com/sparrc/go-ping v0.0.0-20190613174326-4e5b6552494c
+	golang.org/x/net v0.29.0
+	golang.org/x/sys v0.25.0
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.4 // indirect
 	github.com/mattn/go-isatty v0.0.11 // indirect
-	golang.org/x/net v0.29.0 // indirect
-	golang.org/x/sys v0.25.0 // indirect
+	golang.org/x/text v0.18.0 // indirect
 )
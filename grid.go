@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var gridLines = flag.Bool("grid", true,
+	"label the Y axis of the graphs with round values in ms, with grid lines across")
+
+// gridMaxLines is how many grid lines, besides the zero, a graph gets at
+// most.
+const gridMaxLines = 4
+
+// gridStep returns the round step in ms, 1, 2 or 5 times a power of ten,
+// between the grid lines of a graph up to maxValue.
+func gridStep(maxValue float64) float64 {
+	raw := maxValue / gridMaxLines
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*magnitude >= raw {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// grid replaces the labels of the Y axis of a graph, rendered by asciigraph
+// or as braille, with round values in ms, or unit if not "", and draws a
+// line across the plot at each of them, on the cells left blank, so values
+// can be read off the graph.
+func grid(graph string, maxValue float64, unit string) string {
+	if unit == "" {
+		unit = "ms"
+	}
+	lines := strings.Split(graph, "\n")
+	var rows []string
+	for _, line := range lines {
+		if !strings.ContainsAny(line, "┤┼") {
+			break
+		}
+		rows = append(rows, line)
+	}
+	if len(rows) < 2 || maxValue <= 0 {
+		return graph
+	}
+
+	// the rows the round values fall on, with their labels
+	labels := make([]string, len(rows))
+	height := float64(len(rows) - 1)
+	step := gridStep(maxValue)
+	for v := 0.0; v <= maxValue; v += step {
+		row := len(rows) - 1 - int(math.Round(v/maxValue*height))
+		if labels[row] != "" {
+			continue
+		}
+		if step < 1 {
+			labels[row] = fmt.Sprintf("%.1f %s", v, unit)
+		} else {
+			labels[row] = fmt.Sprintf("%.0f %s", v, unit)
+		}
+	}
+	width := 0
+	for _, l := range labels {
+		width = max(width, len(l))
+	}
+
+	for i, line := range rows {
+		axis := strings.IndexFunc(line, func(r rune) bool { return r == '┤' || r == '┼' })
+		plot := []rune(line[axis:])
+		if labels[i] != "" && i != len(rows)-1 {
+			for x := 1; x < len(plot); x++ {
+				if plot[x] == ' ' || plot[x] == 0x2800 {
+					plot[x] = '┄'
+				}
+			}
+		}
+		lines[i] = fmt.Sprintf(" %*s %s", width, labels[i], string(plot))
+	}
+	return strings.Join(lines, "\n")
+}
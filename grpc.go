@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+var grpcService = flag.String("grpc-service", "",
+	"service that grpc:// targets are asked about, the whole server by default")
+
+func init() {
+	registerSource("grpc", "GRPC", "50051", grpcSource)
+	registerSource("grpc+tls", "GRPC/TLS", "443", grpcSource)
+}
+
+// grpcStatuses are the serving statuses of grpc.health.v1.
+var grpcStatuses = []string{"UNKNOWN", "SERVING", "NOT_SERVING", "SERVICE_UNKNOWN"}
+
+// grpcSource measures the latency of grpc.health.v1.Health/Check calls to t,
+// over cleartext HTTP/2 for "grpc", and TLS for "grpc+tls". Calls share a
+// connection like the clients of the service would, and services that are
+// not serving count as lost. Messages are encoded by hand to avoid pulling
+// in the gRPC and protobuf libraries for a single call.
+func grpcSource(ctx context.Context, t target, out chan<- sample) error {
+	transport := &http2.Transport{AllowHTTP: t.scheme == "grpc"}
+	if t.scheme == "grpc" {
+		transport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			d, err := t.streamDialer(probeTimeout)
+			if err != nil {
+				return nil, err
+			}
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+	defer transport.CloseIdleConnections()
+
+	scheme := "http"
+	if t.scheme == "grpc+tls" {
+		scheme = "https"
+	}
+	url := scheme + "://" + t.address + "/grpc.health.v1.Health/Check"
+	return probeEvery(ctx, t, out, func(int, map[string]string) (time.Duration, error) {
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		return grpcHealthCheck(ctx, transport, url, *grpcService)
+	})
+}
+
+func grpcHealthCheck(ctx context.Context, rt http.RoundTripper, url, service string) (time.Duration, error) {
+	// HealthCheckRequest{service = 1}, after the uncompressed message prefix
+	msg := append([]byte{0x0a, byte(len(service))}, service...)
+	if service == "" {
+		msg = nil
+	}
+	body := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+	body = append(body, msg...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	start := time.Now()
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	reply, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("http status %s", resp.Status)
+	}
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status") // trailers-only response
+	}
+	if status != "0" {
+		return 0, fmt.Errorf("grpc status %s %s", status, resp.Trailer.Get("Grpc-Message"))
+	}
+
+	// HealthCheckResponse{status = 1}
+	if len(reply) < 5 {
+		return 0, errors.New("empty health check response")
+	}
+	serving := 0
+	if m := reply[5:]; len(m) >= 2 && m[0] == 0x08 {
+		serving = int(m[1])
+	}
+	if serving != 1 {
+		if serving < len(grpcStatuses) {
+			return 0, errors.New(grpcStatuses[serving])
+		}
+		return 0, fmt.Errorf("health status %d", serving)
+	}
+	return rtt, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var hdrPath = flag.String("hdr", "",
+	"write a histogram of the RTTs of every target to this .hgrm file at exit, in the percentile format of HdrHistogram")
+
+// HDR histograms count values in µs, in buckets 0.1% wide at most.
+const (
+	hdrSubBuckets   = 2048
+	hdrHalfBuckets  = hdrSubBuckets / 2
+	hdrTicksPerHalf = 5 // percentiles listed every halving of the distance to 100%
+)
+
+// hdrHistogram counts RTTs in log-linear buckets, like HdrHistogram does with
+// 3 significant digits: every value up to 2048 µs has its own bucket, and
+// above every power of 2 is split into 1024 buckets.
+type hdrHistogram struct {
+	counts []int64
+	total  int64
+	sum    float64 // of the values, for the mean
+	sumSq  float64
+	max    int64
+}
+
+func hdrIndex(v int64) int {
+	if v < hdrSubBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - bits.Len64(hdrSubBuckets-1)
+	return hdrSubBuckets + (shift-1)*hdrHalfBuckets + int(v>>shift) - hdrHalfBuckets
+}
+
+// hdrValue returns the highest value counted in the bucket i.
+func hdrValue(i int) int64 {
+	if i < hdrSubBuckets {
+		return int64(i)
+	}
+	shift := (i-hdrSubBuckets)/hdrHalfBuckets + 1
+	low := int64((i-hdrSubBuckets)%hdrHalfBuckets+hdrHalfBuckets) << shift
+	return low + 1<<shift - 1
+}
+
+// record counts n values of v µs.
+func (h *hdrHistogram) record(v, n int64) {
+	if v < 0 || n <= 0 {
+		return
+	}
+	i := hdrIndex(v)
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]int64, i+1-len(h.counts))...)
+	}
+	h.counts[i] += n
+	h.total += n
+	h.sum += float64(v) * float64(n)
+	h.sumSq += float64(v) * float64(v) * float64(n)
+	h.max = max(h.max, v)
+}
+
+// merge adds the counts of o.
+func (h *hdrHistogram) merge(o *hdrHistogram) {
+	for i, n := range o.counts {
+		if n > 0 {
+			h.record(hdrValue(i), n)
+		}
+	}
+}
+
+// writePercentiles writes the percentile distribution of h in ms, as the
+// outputPercentileDistribution of HdrHistogram does, for its plotter and
+// other tools reading .hgrm files.
+func (h *hdrHistogram) writePercentiles(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+	var cumulative int64
+	level := 0.0 // the next percentile to list
+	for i, n := range h.counts {
+		if n == 0 {
+			continue
+		}
+		cumulative += n
+		value := float64(hdrValue(i)) / 1000
+		if cumulative == h.total {
+			fmt.Fprintf(bw, "%12.3f %1.12f %10d\n", value, 1.0, cumulative)
+			break
+		}
+		percentile := float64(cumulative) / float64(h.total)
+		if 100*percentile < level {
+			continue
+		}
+		fmt.Fprintf(bw, "%12.3f %1.12f %10d %14.2f\n", value, percentile, cumulative, 1/(1-percentile))
+		for level <= 100*percentile {
+			ticks := hdrTicksPerHalf * math.Pow(2, math.Floor(math.Log2(100/(100-level)))+1)
+			level += 100 / ticks
+		}
+	}
+	mean, stddev := 0.0, 0.0
+	if h.total > 0 {
+		mean = h.sum / float64(h.total)
+		stddev = math.Sqrt(max(h.sumSq/float64(h.total)-mean*mean, 0))
+	}
+	fmt.Fprintf(bw, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", mean/1000, stddev/1000)
+	fmt.Fprintf(bw, "#[Max     = %12.3f, Total count    = %12d]\n", float64(h.max)/1000, h.total)
+	fmt.Fprintf(bw, "#[Buckets = %12d, SubBuckets     = %12d]\n", max(len(h.counts)-hdrSubBuckets, 0)/hdrHalfBuckets+1, hdrSubBuckets)
+	return bw.Flush()
+}
+
+// readPercentiles reads a histogram from a .hgrm file. Its values are only
+// known at the percentiles listed, so the values between two of them are
+// counted at the higher one.
+func readPercentiles(r io.Reader) (*hdrHistogram, error) {
+	h := &hdrHistogram{}
+	scanner := bufio.NewScanner(r)
+	var last int64
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || fields[0] == "Value" {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad .hgrm line %q", scanner.Text())
+		}
+		cumulative, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || cumulative < last {
+			return nil, fmt.Errorf("bad .hgrm line %q", scanner.Text())
+		}
+		h.record(int64(math.Round(value*1000)), cumulative-last)
+		last = cumulative
+	}
+	return h, scanner.Err()
+}
+
+// hdrSink accumulates the RTTs of every target in a histogram, written to
+// -hdr at exit.
+type hdrSink struct {
+	path       string
+	targets    []string
+	histograms map[string]*hdrHistogram
+}
+
+func newHDRSink(path string) *hdrSink {
+	return &hdrSink{path: path, histograms: map[string]*hdrHistogram{}}
+}
+
+func (k *hdrSink) write(s sample) {
+	t := s.target.String()
+	h, ok := k.histograms[t]
+	if !ok {
+		h = &hdrHistogram{}
+		k.histograms[t] = h
+		k.targets = append(k.targets, t)
+	}
+	if !s.lost() {
+		h.record(s.rtt.Microseconds(), 1)
+	}
+}
+
+// close writes a file per target, named after it when there are several,
+// e.g. rtt-1.1.1.1.hgrm for -hdr rtt.hgrm.
+func (k *hdrSink) close() error {
+	for _, t := range k.targets {
+		path := k.path
+		if len(k.targets) > 1 {
+			path = hdrPathOf(k.path, t)
+		}
+		if err := writeHDRFile(path, k.histograms[t]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsafeFileChars are replaced in the names of the files of targets.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func hdrPathOf(path, target string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + strings.Trim(unsafeFileChars.ReplaceAllString(target, "_"), "_") + ext
+}
+
+func writeHDRFile(path string, h *hdrHistogram) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := h.writePercentiles(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runHDRMerge implements "netcheck hdr-merge out.hgrm in.hgrm...": it adds
+// up the histograms written by -hdr, e.g. on several machines or days, into
+// one.
+func runHDRMerge(args []string) int {
+	fs := flag.NewFlagSet("hdr-merge", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "usage: netcheck hdr-merge out.hgrm in.hgrm...")
+		return 2
+	}
+	merged := &hdrHistogram{}
+	for _, path := range fs.Args()[1:] {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		h, err := readPercentiles(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 2
+		}
+		merged.merge(h)
+	}
+	if err := writeHDRFile(fs.Arg(0), merged); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%d RTTs, p50 %s, p99 %s\n", merged.total, formatRTT(merged.at(50)), formatRTT(merged.at(99)))
+	return 0
+}
+
+// at returns the RTT at the percentile p.
+func (h *hdrHistogram) at(p float64) time.Duration {
+	var cumulative int64
+	for i, n := range h.counts {
+		cumulative += n
+		if n > 0 && float64(cumulative) >= p/100*float64(h.total) {
+			return time.Duration(hdrValue(i)) * time.Microsecond
+		}
+	}
+	return 0
+}
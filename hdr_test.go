@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHDRBuckets(t *testing.T) {
+	tests := []struct {
+		v       int64
+		index   int
+		highest int64
+	}{
+		{0, 0, 0},
+		{1, 1, 1},
+		{2047, 2047, 2047},
+		{2048, 2048, 2049},
+		{2049, 2048, 2049},
+		{2050, 2049, 2051},
+		{4095, 3071, 4095},
+		{4096, 3072, 4099},
+		{1000000, 11169, 1000447},
+	}
+	for _, tt := range tests {
+		if got := hdrIndex(tt.v); got != tt.index {
+			t.Errorf("hdrIndex(%d) = %d, want %d", tt.v, got, tt.index)
+		}
+		if got := hdrValue(tt.index); got != tt.highest {
+			t.Errorf("hdrValue(%d) = %d, want %d", tt.index, got, tt.highest)
+		}
+	}
+}
+
+func TestHDRBucketsPrecision(t *testing.T) {
+	last := -1
+	for v := int64(0); v < 100_000_000; v += 1 + v/997 {
+		i := hdrIndex(v)
+		if i < last {
+			t.Fatalf("hdrIndex(%d) = %d, below the index of a lower value %d", v, i, last)
+		}
+		last = i
+		high := hdrValue(i)
+		if high < v || float64(high-v) > float64(v)/1000 {
+			t.Fatalf("hdrValue(hdrIndex(%d)) = %d, not within 0.1%%", v, high)
+		}
+		if hdrIndex(high) != i {
+			t.Fatalf("hdrIndex(%d) = %d, want %d", high, hdrIndex(high), i)
+		}
+	}
+}
+
+func TestHDRRoundTrip(t *testing.T) {
+	h := &hdrHistogram{}
+	for ms := int64(1); ms <= 1000; ms++ {
+		h.record(ms*1000, 1)
+	}
+	h.record(5_000_000, 3)
+
+	var buf bytes.Buffer
+	if err := h.writePercentiles(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"Value     Percentile", "1.000000000000", "#[Max     =     5000.000, Total count    =         1003]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("writePercentiles() lacks %q:\n%s", want, out)
+		}
+	}
+
+	read, err := readPercentiles(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.total != h.total {
+		t.Errorf("total = %d, want %d", read.total, h.total)
+	}
+	for _, p := range []float64{50, 100} {
+		if got, want := read.at(p), h.at(p); got != want {
+			t.Errorf("at(%v) = %v, want %v", p, got, want)
+		}
+	}
+	// values between two listed percentiles are counted at the higher one
+	if got, want := read.at(90), h.at(90); got < want || got > want+want/50 {
+		t.Errorf("at(90) = %v, want %v within 2%%", got, want)
+	}
+}
+
+func TestReadPercentilesErrors(t *testing.T) {
+	for _, in := range []string{
+		"abc 0.5 10 2.0\n",
+		"1.0 0.5 10 2.0\n0.5 0.4 5 1.6\n", // counts going down
+	} {
+		if _, err := readPercentiles(strings.NewReader(in)); err == nil {
+			t.Errorf("readPercentiles(%q) succeeded", in)
+		}
+	}
+	if h, err := readPercentiles(strings.NewReader("")); err != nil || h.total != 0 || h.at(50) != 0 {
+		t.Errorf("readPercentiles(\"\") = %+v, %v", h, err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var healthListen = flag.String("health", "",
+	"serve /healthz and /status, with the recent loss and RTT of every target as JSON, on this address, e.g. :9901")
+
+// healthWindow is how far back the loss and RTT served are computed over.
+const healthWindow = time.Minute
+
+// healthSink keeps the recent samples of every target to serve them over
+// HTTP, for orchestrators and uptime monitors to check on netcheck and the
+// network: /healthz fails when a target is down, got no samples lately, or
+// is beyond -loss-threshold or -rtt-threshold, and /status has the details.
+type healthSink struct {
+	server *http.Server
+	mu     sync.Mutex
+	recent map[target][]sample // of the last healthWindow
+	down   map[target]bool
+	order  []target
+}
+
+// targetStatus is the JSON of a target in /status.
+type targetStatus struct {
+	Target string    `json:"target"`
+	Probe  string    `json:"probe"`
+	Sent   int       `json:"sent"`
+	Lost   int       `json:"lost"`
+	Loss   float64   `json:"loss"`
+	Avg    float64   `json:"avg_ms"`
+	Max    float64   `json:"max_ms"`
+	Last   time.Time `json:"last"`
+	Down   bool      `json:"down"`
+	OK     bool      `json:"ok"`
+}
+
+func newHealthSink(addr string) (*healthSink, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	k := &healthSink{recent: map[target][]sample{}, down: map[target]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", k.serveHealth)
+	mux.HandleFunc("/status", k.serveStatus)
+	k.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		defer resetOnPanic()
+		k.server.Serve(l)
+	}()
+	return k, nil
+}
+
+func (k *healthSink) write(s sample) {
+	if s.target.scheme == "delta" {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	recent, ok := k.recent[s.target]
+	if !ok {
+		k.order = append(k.order, s.target)
+	}
+	drop := 0
+	for drop < len(recent) && s.time.Sub(recent[drop].time) > healthWindow {
+		drop++
+	}
+	k.recent[s.target] = append(recent[drop:], s)
+}
+
+func (k *healthSink) writeAlert(a alert) {
+	if a.name != "down" {
+		return
+	}
+	k.mu.Lock()
+	k.down[a.target] = !a.resolved
+	k.mu.Unlock()
+}
+
+func (k *healthSink) remove(t target) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.recent, t)
+	delete(k.down, t)
+	k.order = slices.DeleteFunc(k.order, func(o target) bool { return o == t })
+}
+
+func (k *healthSink) close() error {
+	return k.server.Close()
+}
+
+// status returns the status of every target over the samples of the last
+// healthWindow, or of the last two probe intervals when scheduled less often.
+// Targets without such samples, e.g. as their probes hang, are failing.
+func (k *healthSink) status() []targetStatus {
+	now := time.Now()
+	window := max(healthWindow, 2*scheduledInterval(now))
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	all := []targetStatus{}
+	for _, t := range k.order {
+		var st stats
+		for _, s := range k.recent[t] {
+			if now.Sub(s.time) <= window {
+				st.add(s)
+			}
+		}
+		recent := k.recent[t]
+		ts := targetStatus{
+			Target: t.String(), Probe: t.scheme, Sent: st.sent, Lost: st.lost, Loss: st.loss(),
+			Avg: ms(st.avg()), Max: ms(st.max), Last: recent[len(recent)-1].time, Down: k.down[t],
+		}
+		ts.OK = !ts.Down && st.sent > 0 && !st.failed()
+		all = append(all, ts)
+	}
+	return all
+}
+
+func (k *healthSink) serveHealth(w http.ResponseWriter, r *http.Request) {
+	var failed []string
+	for _, ts := range k.status() {
+		if !ts.OK {
+			failed = append(failed, ts.Target)
+		}
+	}
+	if len(failed) > 0 {
+		http.Error(w, "failing: "+strings.Join(failed, ", "), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func (k *healthSink) serveStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(k.status())
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthStatus(t *testing.T) {
+	saved := settings
+	t.Cleanup(func() { settings = saved })
+	settings = config{lossThreshold: 20, rttThreshold: 100 * time.Millisecond}
+
+	// replies returns n samples a second apart, the latest ago before now,
+	// every lostEvery-th one lost if lostEvery > 0
+	replies := func(tg target, n int, ago, rtt time.Duration, lostEvery int) []sample {
+		now := time.Now()
+		var samples []sample
+		for i := range n {
+			s := sample{target: tg, time: now.Add(-ago - time.Duration(n-1-i)*time.Second), rtt: rtt}
+			if lostEvery > 0 && i%lostEvery == 0 {
+				s.err = errTimeout
+			}
+			samples = append(samples, s)
+		}
+		return samples
+	}
+	tg := target{scheme: "icmp", address: "192.0.2.1"}
+	tests := []struct {
+		name    string
+		samples []sample
+		down    bool
+		sent    int
+		lost    int
+		ok      bool
+	}{
+		{"fine", replies(tg, 30, 0, 10*time.Millisecond, 0), false, 30, 0, true},
+		{"lossy", replies(tg, 30, 0, 10*time.Millisecond, 2), false, 30, 15, false},
+		{"slow", replies(tg, 30, 0, 200*time.Millisecond, 0), false, 30, 0, false},
+		{"down", replies(tg, 30, 0, 10*time.Millisecond, 0), true, 30, 0, false},
+		{"no recent samples", replies(tg, 30, 2*time.Minute, 10*time.Millisecond, 0), false, 0, 0, false},
+		{"old losses expired", append(replies(tg, 10, 71*time.Second, 0, 1), replies(tg, 20, 15*time.Second, 10*time.Millisecond, 0)...),
+			false, 20, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &healthSink{recent: map[target][]sample{}, down: map[target]bool{}}
+			for _, s := range tt.samples {
+				k.write(s)
+			}
+			if tt.down {
+				k.writeAlert(alert{target: tg, name: "down"})
+			}
+			all := k.status()
+			if len(all) != 1 {
+				t.Fatalf("status() of %d targets, want 1", len(all))
+			}
+			if got := all[0]; got.Sent != tt.sent || got.Lost != tt.lost || got.OK != tt.ok {
+				t.Errorf("status() = sent %d, lost %d, ok %v, want %d, %d, %v", got.Sent, got.Lost, got.OK, tt.sent, tt.lost, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHealthRemove(t *testing.T) {
+	k := &healthSink{recent: map[target][]sample{}, down: map[target]bool{}}
+	a, b := target{scheme: "icmp", address: "192.0.2.1"}, target{scheme: "icmp", address: "192.0.2.2"}
+	k.write(sample{target: a, time: time.Now()})
+	k.write(sample{target: b, time: time.Now()})
+	k.writeAlert(alert{target: a, name: "down"})
+	k.remove(a)
+	all := k.status()
+	if len(all) != 1 || all[0].Target != b.String() || !all[0].OK {
+		t.Errorf("status() = %+v, want %s only, ok", all, b)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// bucket aggregates the samples of a target sent within a period of time.
+type bucket struct {
+	start      time.Time
+	sent, lost int
+	rtts       []time.Duration
+}
+
+func (b *bucket) add(s sample) {
+	b.sent++
+	if s.lost() {
+		b.lost++
+		return
+	}
+	b.rtts = append(b.rtts, s.rtt)
+}
+
+func (b bucket) median() time.Duration {
+	if len(b.rtts) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), b.rtts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// heatmapScale are the median RTTs below which a minute gets every color of
+// the scale of the theme, the last color being for anything higher.
+var heatmapScale = []time.Duration{
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+}
+
+// heatmapCell renders a minute: its color is the median RTT, and its shape
+// tells whether probes were lost.
+func heatmapCell(b bucket) string {
+	switch {
+	case b.sent == 0:
+		return " "
+	case b.lost == b.sent:
+		return color.New(activeTheme.warn...).Sprint("×")
+	}
+
+	c := activeTheme.scale[len(heatmapScale)]
+	for i, below := range heatmapScale {
+		if b.median() < below {
+			c = activeTheme.scale[i]
+			break
+		}
+	}
+	cell := "█"
+	if b.lost > 0 {
+		cell = "▒"
+	}
+	return color.New(c...).Sprint(cell)
+}
+
+// displayHeatmap draws a row per target where every column is a minute, as
+// many minutes as fit in width, so patterns over hours stand out. Targets
+// measuring something else than RTTs are left out. It returns how many lines
+// it took.
+func displayHeatmap(all []*series, width int) int {
+	all = slices.DeleteFunc(slices.Clone(all), func(s *series) bool { return s.target.unit() != "" })
+	labelWidth := 0
+	for _, s := range all {
+		if n := len(s.target.String()); n > labelWidth {
+			labelWidth = n
+		}
+	}
+	columns := width - labelWidth - 2
+	if columns < 10 {
+		columns = 10
+	}
+
+	// align the rows on the same minutes
+	var end time.Time
+	for _, s := range all {
+		if n := len(s.minutes); n > 0 && s.minutes[n-1].start.After(end) {
+			end = s.minutes[n-1].start
+		}
+	}
+	start := end.Add(-time.Duration(columns-1) * time.Minute)
+
+	color.Set(activeTheme.text...)
+	fmt.Fprintf(&frame, "Median RTT per minute: ")
+	for i, c := range activeTheme.scale {
+		label := fmt.Sprintf("≥%d ms", heatmapScale[len(heatmapScale)-1].Milliseconds())
+		if i < len(heatmapScale) {
+			label = fmt.Sprintf("<%d ms", heatmapScale[i].Milliseconds())
+		}
+		fmt.Fprintf(&frame, "%s %s  ", color.New(c...).Sprint("█"), label)
+	}
+	fmt.Fprintf(&frame, "▒ loss  × down\n\n")
+
+	for _, s := range all {
+		cells := make([]string, columns)
+		for i := range cells {
+			cells[i] = " "
+		}
+		for _, b := range s.minutes {
+			if i := int(b.start.Sub(start) / time.Minute); i >= 0 && i < columns {
+				cells[i] = heatmapCell(b)
+			}
+		}
+		color.Set(activeTheme.text...)
+		fmt.Fprintf(&frame, "%-*s  %s\n", labelWidth, s.target, strings.Join(cells, ""))
+	}
+
+	// time labels at the start of every hour
+	axis := []rune(strings.Repeat(" ", columns))
+	for i := 0; i < columns; i++ {
+		t := start.Add(time.Duration(i) * time.Minute)
+		if t.Minute() == 0 && i+5 <= columns {
+			copy(axis[i:], []rune(t.Format("15:04")))
+		}
+	}
+	fmt.Fprintf(&frame, "%-*s  %s\n\n", labelWidth, "", strings.TrimRight(string(axis), " "))
+	return len(all) + 4
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// initialTTLs are the TTLs systems send packets with: 64 on Linux and
+// macOS, 128 on Windows and 255 on network equipment.
+var initialTTLs = []int{64, 128, 255}
+
+// hopsOf infers how many hops away the sender of a reply received with ttl
+// is, assuming it sent it with the closest of initialTTLs above it.
+func hopsOf(ttl int) (int, bool) {
+	for _, initial := range initialTTLs {
+		if ttl > 0 && ttl <= initial {
+			return initial - ttl, true
+		}
+	}
+	return 0, false
+}
+
+// checkHops logs an event when the hop count of the replies of s changes,
+// which means the route to the target likely did.
+func (s *series) checkHops(smp sample) {
+	ttl, err := strconv.Atoi(smp.meta["ttl"])
+	if err != nil {
+		return
+	}
+	hops, ok := hopsOf(ttl)
+	if !ok {
+		return
+	}
+	if s.hops > 0 && hops+1 != s.hops {
+		events.add(smp.time, fmt.Sprintf("hop count of %s changed from %d to %d, the route may have changed", s.target, s.hops-1, hops))
+	}
+	s.hops = hops + 1
+}
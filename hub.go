@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var hubURL = flag.String("hub", "",
+	`send samples and events to a "netcheck hub", e.g. ws://hub.lan:9900`)
+var hubListen = flag.String("listen", ":9900",
+	`address "netcheck hub" accepts agents on`)
+var agentName = flag.String("agent-name", "",
+	"name of this machine on the hub, the host name by default")
+
+const (
+	hubReconnectDelay = 5 * time.Second
+	// hubAuthTimeout is how long the hub and agents wait for each other to
+	// authenticate once connected.
+	hubAuthTimeout = 5 * time.Second
+)
+
+// hubMessage is what agents send to the hub, for every sample and event.
+type hubMessage struct {
+	Sample *sampleJSON `json:"sample,omitempty"`
+	Event  *eventJSON  `json:"event,omitempty"`
+}
+
+// hubHello is what the hub sends agents once connected: a random nonce they
+// sign their messages over, so that messages seen on one connection cannot
+// be replayed on another one.
+type hubHello struct {
+	Nonce string `json:"nonce"`
+}
+
+// hubEnvelope carries a message of an agent, signed with the key of the hub
+// over the nonce of the connection, the agent name and the sequence number
+// of the message, which starts at 0 and grows by one with every message.
+type hubEnvelope struct {
+	Seq     uint64          `json:"seq"`
+	Message json.RawMessage `json:"message"`
+	MAC     string          `json:"mac"`
+}
+
+// signHubMessage returns the HMAC of a message of an agent, see hubEnvelope.
+func signHubMessage(key, nonce []byte, agent string, seq uint64, message []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(nonce)
+	mac.Write(binary.BigEndian.AppendUint64([]byte(agent+"\x00"), seq))
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// openHubEnvelope checks that e is the message seq of agent on the
+// connection of nonce, and returns the message.
+func openHubEnvelope(key, nonce []byte, agent string, seq uint64, e hubEnvelope) (hubMessage, error) {
+	var m hubMessage
+	mac, err := hex.DecodeString(e.MAC)
+	if err != nil || !hmac.Equal(mac, signHubMessage(key, nonce, agent, e.Seq, e.Message)) {
+		return m, errBadSignature
+	}
+	if e.Seq != seq {
+		return m, fmt.Errorf("message %d out of order, expected %d", e.Seq, seq)
+	}
+	err = json.Unmarshal(e.Message, &m)
+	return m, err
+}
+
+type eventJSON struct {
+	Time   time.Time `json:"time"`
+	Text   string    `json:"text"`
+	Marker bool      `json:"marker,omitempty"`
+}
+
+// hubSink streams samples and events to a hub over a WebSocket, connecting
+// again whenever the connection drops.
+type hubSink struct {
+	url      string
+	name     string
+	messages chan hubMessage
+	stop     chan struct{}
+	done     chan error
+}
+
+func newHubSink(rawURL, name string) (*hubSink, error) {
+	if !strings.HasPrefix(rawURL, "ws://") && !strings.HasPrefix(rawURL, "wss://") {
+		return nil, fmt.Errorf("hub URL %q must be ws:// or wss://", rawURL)
+	}
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	k := &hubSink{
+		url:      strings.TrimSuffix(rawURL, "/") + "/agent",
+		name:     name,
+		messages: make(chan hubMessage, 1000),
+		stop:     make(chan struct{}),
+		done:     make(chan error),
+	}
+	go k.run()
+	return k, nil
+}
+
+func (k *hubSink) write(s sample) {
+	j := newSampleJSON(s)
+	k.send(hubMessage{Sample: &j})
+}
+
+func (k *hubSink) writeEvent(e event) {
+	k.send(hubMessage{Event: &eventJSON{e.time, e.text, e.marker}})
+}
+
+func (k *hubSink) send(m hubMessage) {
+	select {
+	case k.messages <- m:
+	case <-k.stop:
+	default:
+		// the hub is unreachable or too slow, drop the message
+	}
+}
+
+func (k *hubSink) close() error {
+	close(k.stop)
+	return <-k.done
+}
+
+func (k *hubSink) run() {
+	defer resetOnPanic()
+	var lastErr error
+	for {
+		err := k.stream()
+		if err == nil {
+			k.done <- nil
+			return
+		}
+		if lastErr == nil || err.Error() != lastErr.Error() {
+			fmt.Fprintf(os.Stderr, "hub: %v\n", err)
+		}
+		lastErr = err
+		select {
+		case <-time.After(hubReconnectDelay):
+		case <-k.stop:
+			k.done <- lastErr
+			return
+		}
+	}
+}
+
+// stream connects to the hub and sends it messages until stopped, when it
+// returns nil, or until the connection fails. The first message is empty,
+// to authenticate at once.
+func (k *hubSink) stream() error {
+	config, err := websocket.NewConfig(k.url, "http://localhost/")
+	if err != nil {
+		return err
+	}
+	config.Header.Set("X-Netcheck-Agent", k.name)
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	var hello hubHello
+	ws.SetReadDeadline(time.Now().Add(hubAuthTimeout))
+	if err := websocket.JSON.Receive(ws, &hello); err != nil {
+		return err
+	}
+	nonce, err := hex.DecodeString(hello.Nonce)
+	if err != nil {
+		return err
+	}
+	var seq uint64
+	send := func(m hubMessage) error {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		mac := signHubMessage(probeKey, nonce, k.name, seq, b)
+		seq++
+		return websocket.JSON.Send(ws, hubEnvelope{Seq: seq - 1, Message: b, MAC: hex.EncodeToString(mac)})
+	}
+
+	if err := send(hubMessage{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case m := <-k.messages:
+			if err := send(m); err != nil {
+				return err
+			}
+		case <-k.stop:
+			for len(k.messages) > 0 {
+				send(<-k.messages)
+			}
+			return nil
+		}
+	}
+}
+
+// hub accepts agents and turns their samples into updates of series of their
+// own, named after the agent.
+type hub struct {
+	ctx     context.Context
+	updates chan<- update
+	mu      sync.Mutex
+	series  map[string]*series // by agent and target
+	removed map[*series]bool   // deleted from the display
+}
+
+// serveHub accepts agents on ln until ctx is done.
+func serveHub(ctx context.Context, ln net.Listener, updates chan<- update) {
+	defer resetOnPanic()
+	h := &hub{ctx: ctx, updates: updates, series: map[string]*series{}, removed: map[*series]bool{}}
+	mux := http.NewServeMux()
+	mux.Handle("/agent", websocket.Server{Handshake: h.authenticate, Handler: h.serve})
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	srv.Serve(ln)
+}
+
+// authenticate checks that the agent gave its name. The agent then signs
+// every message with the key of the hub, from NETCHECK_KEY or -key-file.
+func (h *hub) authenticate(config *websocket.Config, r *http.Request) error {
+	if r.Header.Get("X-Netcheck-Agent") == "" {
+		return errors.New("agent name missing")
+	}
+	return nil
+}
+
+// serve receives the messages of an agent, after sending it the nonce to
+// sign them over, until the connection drops or a message is not signed.
+func (h *hub) serve(ws *websocket.Conn) {
+	defer resetOnPanic()
+	defer ws.Close()
+	agent := ws.Request().Header.Get("X-Netcheck-Agent")
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	if err := websocket.JSON.Send(ws, hubHello{Nonce: hex.EncodeToString(nonce)}); err != nil {
+		return
+	}
+	ws.SetReadDeadline(time.Now().Add(hubAuthTimeout))
+	for seq := uint64(0); ; seq++ {
+		var e hubEnvelope
+		err := websocket.JSON.Receive(ws, &e)
+		var m hubMessage
+		if err == nil {
+			m, err = openHubEnvelope(probeKey, nonce, agent, seq, e)
+		}
+		if err != nil && seq == 0 {
+			events.add(time.Now(), fmt.Sprintf("agent refused: %s: %v", agent, err))
+			return
+		} else if err != nil {
+			events.add(time.Now(), fmt.Sprintf("agent disconnected: %s: %v", agent, err))
+			return
+		}
+		if seq == 0 {
+			ws.SetReadDeadline(time.Time{})
+			events.add(time.Now(), "agent connected: "+agent)
+			continue
+		}
+		if m.Event != nil {
+			if m.Event.Marker {
+				events.mark(m.Event.Time, agent+": "+m.Event.Text)
+			} else {
+				events.add(m.Event.Time, agent+": "+m.Event.Text)
+			}
+		}
+		if m.Sample != nil {
+			h.received(agent, *m.Sample)
+		}
+	}
+}
+
+// received sends a sample of an agent to the display, making a series for
+// its target the first time.
+func (h *hub) received(agent string, j sampleJSON) {
+	key := agent + " " + j.Target
+	h.mu.Lock()
+	s, ok := h.series[key]
+	if !ok {
+		address := strings.TrimPrefix(j.Target, j.Probe+"://")
+		s = newSeries(target{scheme: j.Probe, address: address, label: agent, group: key})
+		s.stop = func() {
+			h.mu.Lock()
+			h.removed[s] = true
+			h.mu.Unlock()
+		}
+		h.series[key] = s
+	}
+	removed := h.removed[s]
+	h.mu.Unlock()
+	if removed {
+		return
+	}
+
+	smp := sample{target: s.target, seq: j.Seq, time: j.Time, meta: j.Meta}
+	if j.Lost {
+		smp.err = errors.New(j.Error)
+	} else {
+		smp.rtt = time.Duration(j.RTT * float64(time.Millisecond))
+	}
+	select {
+	case h.updates <- update{series: s, sample: smp, added: !ok}:
+	case <-h.ctx.Done():
+	}
+}
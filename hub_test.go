@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestOpenHubEnvelope(t *testing.T) {
+	key, nonce := []byte("secret"), []byte("nonce of the connection")
+	message := []byte(`{"event":{"time":"2026-10-16T12:00:00Z","text":"hello"}}`)
+	seal := func(key, nonce []byte, agent string, seq uint64, message []byte) hubEnvelope {
+		return hubEnvelope{Seq: seq, Message: message, MAC: hex.EncodeToString(signHubMessage(key, nonce, agent, seq, message))}
+	}
+	tests := []struct {
+		name     string
+		envelope hubEnvelope
+		wantErr  error // nil for any error other than errBadSignature
+		ok       bool
+	}{
+		{"valid", seal(key, nonce, "home", 3, message), nil, true},
+		{"other key", seal([]byte("other"), nonce, "home", 3, message), errBadSignature, false},
+		{"other connection", seal(key, []byte("other nonce"), "home", 3, message), errBadSignature, false},
+		{"other agent", seal(key, nonce, "office", 3, message), errBadSignature, false},
+		{"agent name shifted into the seq", seal(key, nonce, "hom", 3, message), errBadSignature, false},
+		{"tampered", func() hubEnvelope {
+			e := seal(key, nonce, "home", 3, message)
+			e.Message = []byte(`{"event":{"time":"2026-10-16T12:00:00Z","text":"bye"}}`)
+			return e
+		}(), errBadSignature, false},
+		{"seq changed", func() hubEnvelope {
+			e := seal(key, nonce, "home", 3, message)
+			e.Seq = 4
+			return e
+		}(), errBadSignature, false},
+		{"not hex", hubEnvelope{Seq: 3, Message: message, MAC: "zz"}, errBadSignature, false},
+		{"replayed", seal(key, nonce, "home", 2, message), nil, false},
+		{"skipped ahead", seal(key, nonce, "home", 4, message), nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := openHubEnvelope(key, nonce, "home", 3, tt.envelope)
+			switch {
+			case tt.ok && err != nil:
+				t.Fatalf("openHubEnvelope() = %v", err)
+			case tt.ok && (m.Event == nil || m.Event.Text != "hello"):
+				t.Errorf("openHubEnvelope() = %+v, want the event", m)
+			case !tt.ok && err == nil:
+				t.Error("openHubEnvelope() accepted the message")
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("openHubEnvelope() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+var ifaces = flag.String("iface", "",
+	"comma separated interfaces to probe every target from, e.g. en0,en1 to compare Wi-Fi and Ethernet")
+
+// bindInterface makes the probes of t go out from the interface named
+// name, using its IPv4 address as the source of the probes.
+func bindInterface(t target, name string) (target, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return target{}, fmt.Errorf("interface %s: %v", name, err)
+	}
+	ip := interfaceIPv4(*iface)
+	if ip == nil {
+		return target{}, fmt.Errorf("interface %s has no IPv4 address", name)
+	}
+	t.iface, t.source = name, ip.String()
+	return t, nil
+}
+
+// perInterface probes every target not bound to an interface yet from each
+// of the interfaces of the -iface flag.
+func perInterface(targets []target) ([]target, error) {
+	if *ifaces == "" {
+		return targets, nil
+	}
+	var bound []target
+	for _, t := range targets {
+		if t.iface != "" {
+			bound = append(bound, t)
+			continue
+		}
+		for _, name := range strings.Split(*ifaces, ",") {
+			b, err := bindInterface(t, strings.TrimSpace(name))
+			if err != nil {
+				return nil, err
+			}
+			b.group = t.group + "@" + b.iface
+			bound = append(bound, b)
+		}
+	}
+	return bound, nil
+}
+
+// dialer returns a dialer for network whose connections go out from the
+// interface t is bound to, if any.
+func (t target) dialer(network string, timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	if t.source == "" {
+		return d
+	}
+	ip := net.ParseIP(t.source)
+	if strings.HasPrefix(network, "udp") {
+		d.LocalAddr = &net.UDPAddr{IP: ip}
+	} else {
+		d.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return d
+}
+
+func interfaceIPv4(iface net.Interface) net.IP {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if n, ok := addr.(*net.IPNet); ok && n.IP.To4() != nil {
+			return n.IP
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	influxBatchSize     = 100
+	influxFlushInterval = 10 * time.Second
+)
+
+// influxSink writes samples in InfluxDB line protocol, in batches, to the
+// write endpoint of an InfluxDB server, e.g. "http://host:8086?db=net".
+type influxSink struct {
+	url     string
+	samples chan sample
+	stop    chan struct{}
+	done    chan error
+}
+
+func newInfluxSink(rawURL string) (*influxSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("influx URL %q must be http or https", rawURL)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/write"
+	}
+	q := u.Query()
+	q.Set("precision", "ns")
+	u.RawQuery = q.Encode()
+
+	k := &influxSink{
+		url:     u.String(),
+		samples: make(chan sample, 10*influxBatchSize),
+		stop:    make(chan struct{}),
+		done:    make(chan error),
+	}
+	go k.run()
+	return k, nil
+}
+
+func (k *influxSink) write(s sample) {
+	select {
+	case k.samples <- s:
+	case <-k.stop:
+	default:
+		// the server is too slow, drop the sample rather than the display
+	}
+}
+
+func (k *influxSink) close() error {
+	close(k.stop)
+	return <-k.done
+}
+
+func (k *influxSink) run() {
+	defer resetOnPanic()
+	var batch bytes.Buffer
+	var lastErr error
+	flush := func() {
+		if batch.Len() == 0 {
+			return
+		}
+		if err := k.post(batch.Bytes()); err != nil {
+			lastErr = err
+			events.add(time.Now(), "influx: "+err.Error())
+		}
+		batch.Reset()
+	}
+
+	ticker := time.NewTicker(influxFlushInterval)
+	defer ticker.Stop()
+	n := 0
+	for {
+		select {
+		case s := <-k.samples:
+			writeLineProtocol(&batch, s)
+			if n++; n == influxBatchSize {
+				flush()
+				n = 0
+			}
+		case <-ticker.C:
+			flush()
+			n = 0
+		case <-k.stop:
+			for len(k.samples) > 0 {
+				writeLineProtocol(&batch, <-k.samples)
+			}
+			flush()
+			k.done <- lastErr
+			return
+		}
+	}
+}
+
+func (k *influxSink) post(body []byte) error {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(k.url, "text/plain; charset=utf-8", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("influx write: %s", resp.Status)
+	}
+	return nil
+}
+
+// writeLineProtocol appends a line like
+//
+//	netcheck,target=1.1.1.1,probe=icmp rtt_ms=12.5,lost=false,mos=4.41 1577836800000000000
+func writeLineProtocol(b *bytes.Buffer, s sample) {
+	fmt.Fprintf(b, "netcheck,target=%s,probe=%s ", influxEscaper.Replace(s.target.String()), s.target.scheme)
+	if s.lost() {
+		b.WriteString("lost=true")
+	} else {
+		fmt.Fprintf(b, "rtt_ms=%g,lost=false", ms(s.rtt))
+	}
+	if s.mos > 0 {
+		fmt.Fprintf(b, ",mos=%.2f", s.mos)
+	}
+	fmt.Fprintf(b, " %d\n", s.time.UnixNano())
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
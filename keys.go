@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"io"
+)
+
+// Keys without a printable rune.
+const (
+	keyUp rune = -(iota + 1)
+	keyDown
+	keyRight
+	keyLeft
+)
+
+// readKeys sends the key presses read from r, translating the escape
+// sequences of arrow keys, and the mouse events reported by the terminal.
+func readKeys(r io.Reader) (<-chan rune, <-chan mouseEvent) {
+	keys := make(chan rune)
+	mouse := make(chan mouseEvent)
+	go func() {
+		defer resetOnPanic()
+		br := bufio.NewReader(r)
+		for {
+			k, _, err := br.ReadRune()
+			if err != nil {
+				return
+			}
+			if k == '\x1b' && br.Buffered() >= 2 {
+				seq := make([]byte, 2)
+				io.ReadFull(br, seq)
+				if seq[0] == '[' && seq[1] == '<' {
+					if m, ok := readMouse(br); ok {
+						mouse <- m
+					}
+					continue
+				}
+				if seq[0] == '[' && seq[1] >= 'A' && seq[1] <= 'D' {
+					k = keyUp - rune(seq[1]-'A')
+				}
+			}
+			keys <- k
+		}
+	}()
+	return keys, mouse
+}
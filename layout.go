@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/fatih/color"
+)
+
+var compact = flag.Bool("compact", false,
+	"show only a line per target, with a sparkline, its last RTT and loss, for tiny panes")
+
+// layout is how the display fits the terminal, degrading as it gets small,
+// e.g. in a tmux pane: first the graphs get shorter, then the header lines
+// are dropped, and at last every target gets a single line with a
+// sparkline.
+type layout struct {
+	chrome      bool // show the header, targets, tab bar and help lines
+	graphHeight int  // 0 for sparklines
+}
+
+const (
+	// chromeMinRows is the height of the terminal below which the header
+	// lines are dropped.
+	chromeMinRows = 30
+	// graphMinHeight and graphMinColumns are the smallest graphs drawn,
+	// sparklines being shown below that.
+	graphMinHeight  = 3
+	graphMinColumns = 50
+	// graphOverhead is the rows of a graph besides its height: the zero
+	// row, the caption, the time axis and a blank line.
+	graphOverhead = 4
+)
+
+// layout fits the graphs view to a terminal of the given size.
+func (sc *screen) layout(width, height int) layout {
+	if *compact {
+		return layout{}
+	}
+	l := layout{chrome: height >= chromeMinRows}
+	rows := height - 1 // the blank line after the view
+	if l.chrome {
+		rows -= viewTop - 1 + 1
+	}
+	if n := min(events.len(), eventsShown); n > 0 {
+		rows -= n + 1
+	}
+	if sc.scroll > 0 || sc.zoom > 1 {
+		rows -= 2
+	}
+	if sc.segments != nil {
+		rows -= 2
+	}
+	graphs := 1
+	if sc.focus == nil {
+		graphs = len(sc.all)
+		if sc.delta != nil {
+			graphs++
+		}
+	}
+
+	l.graphHeight = rows/max(graphs, 1) - graphOverhead
+	if sc.focus == nil {
+		l.graphHeight = min(l.graphHeight, maxHeight)
+	}
+	if width < graphMinColumns || l.graphHeight < graphMinHeight {
+		l.graphHeight = 0
+	}
+	return l
+}
+
+// sparkLevels are the characters of sparklines, from the lowest value up.
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders data as a line of block characters scaled to maxValue.
+func sparkline(data []float64, maxValue float64) string {
+	line := make([]rune, len(data))
+	for i, v := range data {
+		level := 0
+		if maxValue > 0 {
+			level = int(v / maxValue * float64(len(sparkLevels)-1))
+		}
+		line[i] = sparkLevels[max(min(level, len(sparkLevels)-1), 0)]
+	}
+	return string(line)
+}
+
+// sparklineRow describes s in a line of at most width columns: its name,
+// a sparkline of its latest replies, its last result and its loss.
+func sparklineRow(s *series, width int, maxValue float64) string {
+	name := s.target.String()
+	if s.target.label != "" {
+		name = s.target.label
+	}
+	result := fmt.Sprintf(" %s, %.0f%% loss", s.result(), s.stats.loss())
+	if s.last.time.IsZero() {
+		result = " " + waitingText // and no loss to tell yet
+	}
+	columns := width - len([]rune(name)) - len([]rune(result)) - 1
+	data, _ := s.window(0, 1)
+	data = data[1:]
+	if columns < len(data) {
+		data = data[len(data)-max(columns, 0):]
+	}
+	return truncate(name+" "+sparkline(data, maxValue)+result, width)
+}
+
+// truncate cuts s to width columns, so that it does not wrap.
+func truncate(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:max(width, 0)])
+	}
+	return s
+}
+
+// drawSparklines shows a sparkline row per target, followed by the latest
+// events unless -compact.
+func (sc *screen) drawSparklines(width int) {
+	group := -1
+	for i, s := range sc.all {
+		if i == 0 || s.target.group != sc.all[i-1].target.group {
+			group++
+		}
+		color.Set(activeTheme.series[group%len(activeTheme.series)]...)
+		sc.regions = append(sc.regions, region{sc.top + i, sc.top + i + 1, s})
+		fmt.Fprintf(&frame, "%s\033[K\n", sparklineRow(s, width, sc.scaleOf(s)))
+	}
+	if !*compact {
+		sc.drawLatestEvents(sc.top+len(sc.all), width)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// lookupCache runs slow lookups, e.g. DNS queries, in the background and
+// keeps their results for a while, so that the display never waits for
+// them.
+type lookupCache struct {
+	fetch   func(ctx context.Context, key string) (string, error)
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	value   string
+	expires time.Time // zero while the lookup runs
+}
+
+func newLookupCache(ttl time.Duration, fetch func(ctx context.Context, key string) (string, error)) *lookupCache {
+	return &lookupCache{fetch: fetch, ttl: ttl, entries: map[string]*cacheEntry{}}
+}
+
+// get returns the value of key, or reports false if it is not known yet,
+// starting a lookup when there is none running. Failed lookups are cached
+// as empty values.
+func (c *lookupCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if ok && (e.expires.IsZero() || time.Now().Before(e.expires)) {
+		return e.value, e.value != ""
+	}
+	if !ok {
+		e = &cacheEntry{}
+		c.entries[key] = e
+	}
+	e.expires = time.Time{}
+	go func() {
+		defer resetOnPanic()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		v, _ := c.fetch(ctx, key)
+		c.mu.Lock()
+		e.value, e.expires = v, time.Now().Add(c.ttl)
+		c.mu.Unlock()
+	}()
+	return e.value, e.value != ""
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+)
+
+// Buttons of mouse events, as xterm reports them.
+const (
+	mouseLeft      = 0
+	mouseWheelUp   = 64
+	mouseWheelDown = 65
+)
+
+// maxZoom is how many replies a graph point can stand for at most.
+const maxZoom = 32
+
+// mouseEvent is a button press, or a turn of the wheel, at a cell of the
+// terminal counted from 1.
+type mouseEvent struct {
+	button, x, y int
+}
+
+// readMouse reads the rest of a mouse report in SGR format, "\x1b[<b;x;yM"
+// for a press and with a final "m" for a release, after its "\x1b[<". It
+// reports false for releases and malformed reports.
+func readMouse(br *bufio.Reader) (mouseEvent, bool) {
+	var report []byte
+	for {
+		c, err := br.ReadByte()
+		if err != nil || len(report) > 16 {
+			return mouseEvent{}, false
+		}
+		if c == 'M' || c == 'm' {
+			var m mouseEvent
+			_, err := fmt.Sscanf(string(report), "%d;%d;%d", &m.button, &m.x, &m.y)
+			return m, err == nil && c == 'M'
+		}
+		report = append(report, c)
+	}
+}
+
+// region is the rows of the screen a graph was drawn on.
+type region struct {
+	top, bottom int // bottom excluded
+	series      *series
+}
+
+// eventRow is a row of the screen an event was drawn on.
+type eventRow struct {
+	row  int
+	time time.Time
+}
+
+// handleMouse focuses the graph clicked, zooms the graphs with the wheel,
+// and scrolls the graphs to the time of the event clicked.
+func (sc *screen) handleMouse(m mouseEvent) {
+	switch m.button {
+	case mouseWheelUp:
+		if sc.zoom > 1 {
+			sc.zoom /= 2
+			sc.clear = true
+		}
+	case mouseWheelDown:
+		if sc.zoom < maxZoom {
+			sc.zoom *= 2
+			sc.clear = true
+		}
+	case mouseLeft:
+		for _, e := range sc.eventRows {
+			if e.row == m.y {
+				sc.jumpTo(e.time)
+				return
+			}
+		}
+		for _, r := range sc.regions {
+			if m.y >= r.top && m.y < r.bottom {
+				sc.toggleFocus(r.series)
+				return
+			}
+		}
+	}
+}
+
+// toggleFocus selects s and shows only its graph, taller, or shows all the
+// graphs again when s was already focused.
+func (sc *screen) toggleFocus(s *series) {
+	sc.clear = true
+	if sc.focus == s {
+		sc.focus = nil
+		return
+	}
+	sc.focus = s
+	for i, other := range sc.all {
+		if other == s {
+			sc.selected = i
+		}
+	}
+}
+
+// jumpTo shows the graphs with t in the middle.
+func (sc *screen) jumpTo(t time.Time) {
+	longest := sc.longest()
+	if longest == nil || len(longest.history) == 0 {
+		return
+	}
+	i := 0
+	for i < len(longest.history) && longest.history[i].time.Before(t) {
+		i++
+	}
+	sc.scroll = max(len(longest.history)-i-(maxLen-1)*sc.zoom/2, 0)
+	sc.switchTab(tabGraphs)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+const mqttKeepAlive = 60 * time.Second
+
+// mqttSink publishes every sample as JSON to an MQTT broker, and the events
+// of the session to the "events" subtopic, e.g. for Home Assistant. It speaks
+// just enough MQTT 3.1.1 to publish at QoS 0.
+type mqttSink struct {
+	broker   *url.URL
+	topic    string
+	messages chan mqttMessage
+	stop     chan struct{}
+	done     chan error
+}
+
+type mqttMessage struct {
+	topic   string
+	payload []byte
+}
+
+func newMQTTSink(rawURL, topic string) (*mqttSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		u.Host = withDefaultPort(u.Host, "1883")
+	case "ssl", "tls", "mqtts":
+		u.Host = withDefaultPort(u.Host, "8883")
+	default:
+		return nil, fmt.Errorf("MQTT broker %q must be tcp:// or ssl://", rawURL)
+	}
+
+	k := &mqttSink{
+		broker:   u,
+		topic:    topic,
+		messages: make(chan mqttMessage, 1000),
+		stop:     make(chan struct{}),
+		done:     make(chan error),
+	}
+	go k.run()
+	return k, nil
+}
+
+func (k *mqttSink) write(s sample) {
+	payload, _ := json.Marshal(newSampleJSON(s))
+	k.publish(mqttMessage{topic: k.topic, payload: payload})
+}
+
+func (k *mqttSink) writeEvent(e event) {
+	payload, _ := json.Marshal(eventJSON{e.time, e.text, e.marker})
+	k.publish(mqttMessage{topic: k.topic + "/events", payload: payload})
+}
+
+func (k *mqttSink) publish(m mqttMessage) {
+	select {
+	case k.messages <- m:
+	case <-k.stop:
+	default:
+		// the broker is unreachable or too slow, drop the message
+	}
+}
+
+func (k *mqttSink) close() error {
+	close(k.stop)
+	return <-k.done
+}
+
+func (k *mqttSink) run() {
+	defer resetOnPanic()
+	var conn net.Conn
+	var lastErr error
+	fail := func(err error) {
+		lastErr = err
+		// report it to stderr, as the event would be published to MQTT too
+		fmt.Fprintln(os.Stderr, "mqtt:", err)
+		if conn != nil {
+			conn.Close()
+			conn = nil
+		}
+	}
+
+	ping := time.NewTicker(mqttKeepAlive / 2)
+	defer ping.Stop()
+	var retryAt time.Time
+	for {
+		select {
+		case m := <-k.messages:
+			if conn == nil {
+				if time.Now().Before(retryAt) {
+					continue
+				}
+				var err error
+				if conn, err = k.connect(); err != nil {
+					retryAt = time.Now().Add(10 * time.Second)
+					fail(err)
+					continue
+				}
+			}
+			if _, err := conn.Write(mqttPublish(m.topic, m.payload)); err != nil {
+				fail(err)
+			}
+		case <-ping.C:
+			if conn != nil {
+				if _, err := conn.Write([]byte{0xc0, 0}); err != nil {
+					fail(err)
+				}
+			}
+		case <-k.stop:
+			if conn != nil {
+				for len(k.messages) > 0 {
+					m := <-k.messages
+					conn.Write(mqttPublish(m.topic, m.payload))
+				}
+				conn.Write([]byte{0xe0, 0}) // DISCONNECT
+				conn.Close()
+			}
+			k.done <- lastErr
+			return
+		}
+	}
+}
+
+func (k *mqttSink) connect() (net.Conn, error) {
+	d := net.Dialer{Timeout: 5 * time.Second}
+	var conn net.Conn
+	var err error
+	if k.broker.Scheme == "tcp" || k.broker.Scheme == "mqtt" {
+		conn, err = d.Dial("tcp", k.broker.Host)
+	} else {
+		conn, err = tls.DialWithDialer(&d, "tcp", k.broker.Host, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// CONNECT with a clean session, and credentials from the URL
+	var flags byte = 0x02
+	var payload []byte
+	host, _ := os.Hostname()
+	payload = appendMQTTString(payload, "netcheck-"+host)
+	if user := k.broker.User; user != nil {
+		flags |= 0x80
+		payload = appendMQTTString(payload, user.Username())
+		if password, ok := user.Password(); ok {
+			flags |= 0x40
+			payload = appendMQTTString(payload, password)
+		}
+	}
+	header := appendMQTTString(nil, "MQTT")
+	header = append(header, 4, flags, byte(mqttKeepAlive/time.Second>>8), byte(mqttKeepAlive/time.Second))
+	if _, err := conn.Write(mqttPacket(0x10, append(header, payload...))); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ack[0] != 0x20 || ack[3] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("connection refused by broker, code %d", ack[3])
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	// discard PINGRESP and anything else the broker sends
+	go io.Copy(io.Discard, bufio.NewReader(conn))
+
+	return conn, nil
+}
+
+// mqttPublish encodes a QoS 0 PUBLISH packet.
+func mqttPublish(topic string, payload []byte) []byte {
+	return mqttPacket(0x30, append(appendMQTTString(nil, topic), payload...))
+}
+
+// mqttPacket prepends the fixed header, with its variable length encoding of
+// the remaining length, to the rest of a packet.
+func mqttPacket(kind byte, rest []byte) []byte {
+	b := []byte{kind}
+	n := len(rest)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, rest...)
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
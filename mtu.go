@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// icmpHeaderLen is the overhead of an ICMP echo request over its payload:
+// the IPv4 and ICMP headers.
+const icmpHeaderLen = 20 + 8
+
+// mtuHints are the usual causes of a given path MTU.
+var mtuHints = map[int]string{
+	1500: "Ethernet, no tunnel on the path",
+	1492: "PPPoE",
+	1480: "6in4 tunnel",
+	1476: "GRE tunnel",
+	1420: "WireGuard",
+}
+
+// runMTU implements "netcheck mtu <target>": it binary searches the largest
+// echo request that reaches the target with the don't fragment bit set, and
+// reports it as the path MTU.
+func runMTU(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: netcheck mtu <target>")
+		return 2
+	}
+	ip, err := net.ResolveIPAddr("ip4", args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	conn, raw, err := listenDontFragment()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+	p := &pinger{conn: conn, dst: &net.UDPAddr{IP: ip.IP}, token: newToken()}
+	if raw {
+		p.dst = ip
+	}
+
+	seq := 0
+	fits := func(mtu int) bool {
+		p.size = mtu - icmpHeaderLen
+		// a request may be lost for other reasons than its size
+		for try := 0; try < 3; try++ {
+			seq++
+			_, err := p.ping(seq, probeTimeout)
+			if err == nil {
+				fmt.Printf("%5d bytes  ok\n", mtu)
+				return true
+			}
+			if errors.Is(err, syscall.EMSGSIZE) {
+				break // the system already knows the path MTU is lower
+			}
+		}
+		fmt.Printf("%5d bytes  too big\n", mtu)
+		return false
+	}
+
+	fmt.Printf("Path MTU discovery to %s\n", ip)
+	lo, hi := 68, interfaceMTU(ip.IP)
+	if !fits(lo) {
+		fmt.Fprintf(os.Stderr, "no reply from %s\n", ip)
+		return 1
+	}
+	if fits(hi) {
+		lo = hi
+	}
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if fits(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	fmt.Printf("Path MTU: %d bytes", lo)
+	if hint, ok := mtuHints[lo]; ok {
+		fmt.Printf(", typical of %s", hint)
+	} else if lo < 1500 {
+		fmt.Print(", lower than Ethernet, likely a tunnel or VPN on the path")
+	}
+	fmt.Println()
+	return 0
+}
+
+// interfaceMTU returns the MTU of the interface the route to ip goes
+// through, the largest packet the search tries.
+func interfaceMTU(ip net.IP) int {
+	// dialing UDP picks the route without sending anything
+	conn, err := net.DialTimeout("udp4", net.JoinHostPort(ip.String(), "9"), time.Second)
+	if err != nil {
+		return 1500
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if n, ok := addr.(*net.IPNet); ok && n.IP.Equal(local) {
+				return iface.MTU
+			}
+		}
+	}
+	return 1500
+}
@@ -0,0 +1,7 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+func dontFragment(fd int) error {
+	return unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_DO)
+}
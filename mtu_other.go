@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+func listenDontFragment() (net.PacketConn, bool, error) {
+	return nil, false, errors.New("path MTU discovery is not supported on this system")
+}
@@ -0,0 +1,31 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenDontFragment opens an ICMP socket whose packets cannot be
+// fragmented, unprivileged where allowed and raw otherwise.
+func listenDontFragment() (net.PacketConn, bool, error) {
+	var err error
+	for _, typ := range []int{unix.SOCK_DGRAM, unix.SOCK_RAW} {
+		var fd int
+		if fd, err = unix.Socket(unix.AF_INET, typ, unix.IPPROTO_ICMP); err != nil {
+			continue
+		}
+		if err = dontFragment(fd); err != nil {
+			unix.Close(fd)
+			return nil, false, err
+		}
+		f := os.NewFile(uintptr(fd), "icmp")
+		conn, err := net.FilePacketConn(f)
+		f.Close()
+		return conn, typ == unix.SOCK_RAW, err
+	}
+	return nil, false, os.NewSyscallError("socket", err)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// muteRule mutes the alerts of a target, or of every one without a target,
+// during the days and hours it covers, all of them by default, e.g.
+//
+//	{"target": "192.168.1.20"}
+//	{"days": "sun", "hours": "02:00-04:00"}
+//
+// for a known flaky host, and the weekly maintenance of the ISP. Muted
+// alerts are logged, but not handed to the alert sinks.
+type muteRule struct {
+	timeWindow
+	Target string `json:"target"`
+}
+
+func (r *muteRule) parse() error {
+	return r.timeWindow.parse()
+}
+
+// mutedTargets are the targets muted with the u key, by name.
+var mutedTargets = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: map[string]bool{}}
+
+// toggleMute mutes the alerts of t, or unmutes them, and reports whether
+// they are muted now.
+func toggleMute(t target) bool {
+	mutedTargets.Lock()
+	defer mutedTargets.Unlock()
+	mutedTargets.m[t.String()] = !mutedTargets.m[t.String()]
+	return mutedTargets.m[t.String()]
+}
+
+// muted reports whether the alerts of t are muted at now.
+func muted(t target, now time.Time) bool {
+	mutedTargets.Lock()
+	byKey := mutedTargets.m[t.String()]
+	mutedTargets.Unlock()
+	if byKey {
+		return true
+	}
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	for i := range settings.Mute {
+		r := &settings.Mute[i]
+		if (r.Target == "" || r.Target == t.group || r.Target == t.String()) && r.covers(now) {
+			return true
+		}
+	}
+	return false
+}
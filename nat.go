@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// natSecondServer is the STUN server the mapping is compared with when the
+// first one does not tell an alternate address.
+const natSecondServer = "stun.cloudflare.com:3478"
+
+// natTries is how many times a STUN test is sent before concluding that
+// its response was filtered, as datagrams get lost.
+const natTries = 3
+
+// natBehavior is how a NAT maps and filters UDP (RFC 4787): independently of
+// the endpoint a host sends to, or dependent on its address, or on its
+// address and port.
+type natBehavior int
+
+const (
+	natUnknown natBehavior = iota
+	natEndpointIndependent
+	natAddressDependent
+	natAddressPortDependent
+)
+
+func (b natBehavior) String() string {
+	return [...]string{"unknown", "endpoint independent", "address dependent", "address and port dependent"}[b]
+}
+
+// natResult is the outcome of the tests of RFC 5780 against a STUN server.
+type natResult struct {
+	public    *net.UDPAddr
+	open      bool // no NAT, the local address is public
+	mapping   natBehavior
+	filtering natBehavior
+}
+
+// kind returns the classic name of the NAT type, from RFC 3489.
+func (r natResult) kind() string {
+	switch {
+	case r.open:
+		return "none, open internet"
+	case r.mapping == natUnknown:
+		return "unknown"
+	case r.mapping != natEndpointIndependent:
+		return "symmetric"
+	}
+	switch r.filtering {
+	case natEndpointIndependent:
+		return "full cone"
+	case natAddressDependent:
+		return "restricted cone"
+	case natAddressPortDependent:
+		return "port restricted cone"
+	}
+	return "cone, filtering unknown"
+}
+
+// holePunching tells how well peers behind r can reach each other directly,
+// as games and WebRTC calls try.
+func (r natResult) holePunching() string {
+	switch {
+	case r.open || (r.mapping == natEndpointIndependent && r.filtering == natEndpointIndependent):
+		return "works with every peer"
+	case r.mapping == natEndpointIndependent:
+		return "works with most peers, except those behind symmetric NATs"
+	case r.mapping != natUnknown:
+		return "works only with peers behind full cone NATs or without NAT, calls and games will often need a relay (TURN)"
+	}
+	return "unknown"
+}
+
+// runNAT implements "netcheck nat": it classifies the NAT of the network
+// with STUN, and tells whether UDP hole punching can work through it.
+func runNAT(args []string) int {
+	fs := flag.NewFlagSet("nat", flag.ContinueOnError)
+	server := fs.String("server", *stunServer, "STUN server, the tests of filtering need one supporting RFC 5780")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	r, err := classifyNAT(*server)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	printNAT(os.Stdout, r)
+	return 0
+}
+
+func printNAT(w io.Writer, r natResult) {
+	fmt.Fprintf(w, "Public address: %s\n", r.public)
+	fmt.Fprintf(w, "NAT type:       %s\n", r.kind())
+	if !r.open {
+		fmt.Fprintf(w, "  mapping:      %s\n", r.mapping)
+		fmt.Fprintf(w, "  filtering:    %s\n", r.filtering)
+	}
+	fmt.Fprintf(w, "Hole punching:  %s\n", r.holePunching())
+	if r.filtering == natUnknown && !r.open {
+		fmt.Fprintln(w, "The STUN server does not tell its alternate address, use -server with one that does, e.g. stun.stunprotocol.org, to test filtering.")
+	}
+}
+
+// classifyNAT runs the mapping and filtering tests of RFC 5780 with server,
+// all from the same local port. Servers without an alternate address only
+// allow comparing the mapping with the one towards another server.
+func classifyNAT(server string) (natResult, error) {
+	primary, err := resolveSTUN(server)
+	if err != nil {
+		return natResult{}, err
+	}
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return natResult{}, err
+	}
+	defer conn.Close()
+
+	first, err := stunTry(conn, primary, 0)
+	if err != nil {
+		return natResult{}, fmt.Errorf("STUN server %s: %v", server, err)
+	}
+	r := natResult{public: first.mapped}
+	if local, err := localAddrTo(primary.IP.String()); err == nil && local.Equal(first.mapped.IP) {
+		r.open = true
+		return r, nil
+	}
+
+	if first.other == nil {
+		second, err := resolveSTUN(natSecondServer)
+		if err != nil {
+			return r, nil
+		}
+		if other, err := stunTry(conn, second, 0); err == nil {
+			r.mapping = natEndpointIndependent
+			if other.mapped.String() != first.mapped.String() {
+				r.mapping = natAddressPortDependent // at least
+			}
+		}
+		return r, nil
+	}
+
+	// mapping: towards the alternate address, then its alternate port too
+	alternate := &net.UDPAddr{IP: first.other.IP, Port: primary.Port}
+	second, err := stunTry(conn, alternate, 0)
+	if err != nil {
+		return r, nil
+	}
+	if second.mapped.String() == first.mapped.String() {
+		r.mapping = natEndpointIndependent
+	} else if third, err := stunTry(conn, first.other, 0); err == nil {
+		r.mapping = natAddressPortDependent
+		if third.mapped.String() == second.mapped.String() {
+			r.mapping = natAddressDependent
+		}
+	}
+
+	// filtering: responses from the alternate address, then port
+	switch {
+	case stunReplies(conn, primary, stunChangeIP|stunChangePort):
+		r.filtering = natEndpointIndependent
+	case stunReplies(conn, primary, stunChangePort):
+		r.filtering = natAddressDependent
+	default:
+		r.filtering = natAddressPortDependent
+	}
+	return r, nil
+}
+
+// stunTry sends a binding request up to natTries times.
+func stunTry(conn net.PacketConn, server *net.UDPAddr, change byte) (stunResult, error) {
+	var err error
+	for range natTries {
+		var r stunResult
+		if r, err = stunBinding(conn, server, change, 500*time.Millisecond); err == nil {
+			return r, nil
+		}
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			return r, err
+		}
+	}
+	return stunResult{}, errTimeout
+}
+
+// stunReplies reports whether a request asking server to reply from another
+// address or port gets through the NAT.
+func stunReplies(conn net.PacketConn, server *net.UDPAddr, change byte) bool {
+	_, err := stunTry(conn, server, change)
+	return err == nil
+}
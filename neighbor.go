@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackpal/gateway"
+)
+
+const (
+	// neighborSlow is how long resolving the MAC address of the gateway may
+	// take before it is logged.
+	neighborSlow = 200 * time.Millisecond
+	// macFlapWindow is how long a MAC address of the gateway is remembered,
+	// going back to one of them within it being a flap.
+	macFlapWindow = 10 * time.Minute
+)
+
+// watchNeighbor checks the neighbor table entry of the gateway, logging an
+// event when it takes long to resolve, and when its MAC address changes,
+// which is an alert when it flaps between addresses: ARP spoofing, or a
+// misbehaving mesh node.
+func watchNeighbor(ctx context.Context) {
+	defer resetOnPanic()
+	var last string
+	seen := map[string]time.Time{} // MAC addresses of the gateway, when last seen
+
+	ticker := time.NewTicker(gatewayCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			ip, err := gateway.DiscoverGateway()
+			if err != nil {
+				continue
+			}
+			mac, took, err := resolveNeighbor(ip)
+			switch {
+			case err != nil:
+				events.add(now, fmt.Sprintf("gateway %s: %v", ip, err))
+				continue
+			case took > neighborSlow:
+				events.add(now, fmt.Sprintf("gateway %s took %s to answer ARP", ip, formatRTT(took)))
+			}
+
+			if last != "" && mac != last {
+				if t, ok := seen[mac]; ok && now.Sub(t) < macFlapWindow {
+					raiseAlert(alert{
+						time:   now,
+						target: target{scheme: "icmp", address: ip.String(), label: "gateway", group: ip.String()},
+						name:   "mac-flap",
+						text:   fmt.Sprintf("gateway %s MAC flapping between %s and %s, ARP spoofing or a misbehaving mesh node?", ip, last, mac),
+					})
+				} else {
+					events.add(now, fmt.Sprintf("gateway %s MAC changed from %s to %s", ip, last, mac))
+				}
+			}
+			if last != "" {
+				seen[last] = now
+			}
+			last = mac
+		}
+	}
+}
+
+// resolveNeighbor returns the MAC address of ip from the neighbor table.
+// When it is not there, it sends a datagram to ip for the system to resolve
+// it, and returns how long that took.
+func resolveNeighbor(ip net.IP) (string, time.Duration, error) {
+	if mac, ok := neighbors()[ip.String()]; ok {
+		return mac, 0, nil
+	}
+	conn, err := net.Dial("udp4", net.JoinHostPort(ip.String(), "9"))
+	if err != nil {
+		return "", 0, err
+	}
+	defer conn.Close()
+	start := time.Now()
+	conn.Write(nil)
+	for time.Since(start) < probeTimeout {
+		time.Sleep(10 * time.Millisecond)
+		if mac, ok := neighbors()[ip.String()]; ok {
+			return mac, time.Since(start), nil
+		}
+	}
+	return "", 0, fmt.Errorf("no ARP reply in %s", probeTimeout)
+}
+
+// neighbors returns the MAC addresses of the neighbor table of the system,
+// by IP address, read from /proc/net/arp on Linux and from arp -an
+// elsewhere.
+func neighbors() map[string]string {
+	table := map[string]string{}
+	if b, err := os.ReadFile("/proc/net/arp"); err == nil {
+		lines := strings.Split(string(b), "\n")
+		for _, line := range lines[1:] {
+			// IP address, HW type, flags, HW address, mask, device
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[2] != "0x0" {
+				table[fields[0]] = fields[3]
+			}
+		}
+		return table
+	}
+
+	out, err := exec.Command("arp", "-an").Output()
+	if err != nil {
+		return table
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		// "? (192.168.1.1) at a4:91:b1:0:1:2 on en0" on BSDs,
+		// "  192.168.1.1  a4-91-b1-00-01-02  dynamic" on Windows
+		var ip, mac string
+		for _, field := range strings.Fields(line) {
+			field = strings.Trim(field, "()")
+			if net.ParseIP(field) != nil {
+				ip = field
+			} else if m := normalizeMAC(field); m != "" {
+				mac = m
+			}
+		}
+		if ip != "" && mac != "" {
+			table[ip] = mac
+		}
+	}
+	return table
+}
+
+// normalizeMAC returns s as lowercase colon separated bytes with two digits,
+// or nothing if it is not a MAC address.
+func normalizeMAC(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == ':' || r == '-' })
+	if len(parts) != 6 {
+		return ""
+	}
+	for i, p := range parts {
+		b, err := strconv.ParseUint(p, 16, 8)
+		if err != nil {
+			return ""
+		}
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const netConfigCheckInterval = 5 * time.Second
+
+// resolvConfPaths are read in order for the DNS configuration, the first
+// being where systemd-resolved keeps the servers it forwards to, as
+// /etc/resolv.conf only points to it then.
+var resolvConfPaths = []string{"/run/systemd/resolve/resolv.conf", "/etc/resolv.conf"}
+
+// leasePatterns match the lease files of the usual DHCP clients, rewritten
+// whenever a lease is obtained or renewed.
+var leasePatterns = []string{
+	"/var/lib/dhcp/*.leases",          // dhclient
+	"/var/lib/dhclient/*.leases",      // dhclient on Red Hat
+	"/var/lib/NetworkManager/*.lease", // NetworkManager
+	"/run/systemd/netif/leases/*",     // systemd-networkd
+	"/var/lib/dhcpcd/*.lease",         // dhcpcd
+	"/var/db/dhcpcd/*.lease",          // dhcpcd on BSDs
+	"/var/db/dhcpclient/leases/*",     // macOS
+}
+
+// dnsConfig is the resolver configuration of the system.
+type dnsConfig struct {
+	servers []string
+	search  []string
+}
+
+// readDNSConfig parses the first resolv.conf of resolvConfPaths that exists.
+func readDNSConfig() dnsConfig {
+	var conf dnsConfig
+	for _, path := range resolvConfPaths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			switch fields[0] {
+			case "nameserver":
+				conf.servers = append(conf.servers, fields[1])
+			case "search", "domain":
+				conf.search = fields[1:]
+			}
+		}
+		break
+	}
+	return conf
+}
+
+// leaseTimes returns when every DHCP lease file was last written.
+func leaseTimes() map[string]time.Time {
+	times := map[string]time.Time{}
+	for _, pattern := range leasePatterns {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				times[path] = info.ModTime()
+			}
+		}
+	}
+	return times
+}
+
+// watchNetConfig logs an event whenever the DNS servers or search domains
+// of the system change, or a DHCP lease is obtained or renewed, as a
+// network that got slow may just have a new DNS server.
+func watchNetConfig(ctx context.Context) {
+	defer resetOnPanic()
+	lastDNS, lastLeases := readDNSConfig(), leaseTimes()
+
+	ticker := time.NewTicker(netConfigCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			dns := readDNSConfig()
+			if !slices.Equal(dns.servers, lastDNS.servers) {
+				events.add(now, fmt.Sprintf("DNS servers changed from %s to %s", listOrNone(lastDNS.servers), listOrNone(dns.servers)))
+			}
+			if !slices.Equal(dns.search, lastDNS.search) {
+				events.add(now, fmt.Sprintf("DNS search domains changed from %s to %s", listOrNone(lastDNS.search), listOrNone(dns.search)))
+			}
+			lastDNS = dns
+
+			leases := leaseTimes()
+			for path, t := range leases {
+				if last, ok := lastLeases[path]; !ok {
+					events.add(now, "DHCP lease obtained: "+filepath.Base(path))
+				} else if t.After(last) {
+					events.add(now, "DHCP lease renewed: "+filepath.Base(path))
+				}
+			}
+			lastLeases = leases
+		}
+	}
+}
+
+func listOrNone(list []string) string {
+	if len(list) == 0 {
+		return "none"
+	}
+	return strings.Join(list, " ")
+}
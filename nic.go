@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var nicStats = flag.Bool("nic", false,
+	"also graph the errors and drops of the interfaces the targets go out from, as nic://<interface> targets")
+
+func init() {
+	registerProbe("nic", probeType{name: "NIC", opaque: true, unit: "errors", newProbe: func(t target) probe {
+		return &sourceProbe{name: "NIC", target: t, source: nicSource}
+	}})
+}
+
+// nicCounters are the error counters of an interface.
+type nicCounters struct {
+	rxErrors, rxDropped, txErrors, txDropped uint64
+}
+
+// sub returns the counts since last, the whole counts if they were reset,
+// e.g. when the interface went down.
+func (c nicCounters) sub(last nicCounters) nicCounters {
+	diff := func(a, b uint64) uint64 {
+		if a < b {
+			return a
+		}
+		return a - b
+	}
+	return nicCounters{
+		rxErrors:  diff(c.rxErrors, last.rxErrors),
+		rxDropped: diff(c.rxDropped, last.rxDropped),
+		txErrors:  diff(c.txErrors, last.txErrors),
+		txDropped: diff(c.txDropped, last.txDropped),
+	}
+}
+
+// tcpCounters are the TCP segments the system sent, and retransmitted.
+type tcpCounters struct {
+	outSegs, retransSegs uint64
+}
+
+// nicSource graphs the errors and drops of the interface of t, as in
+// "nic://eth0", since the previous probe, as NIC level errors often explain
+// a loss ping alone cannot. The rate of TCP retransmissions of the system
+// goes along in the note of the target.
+func nicSource(ctx context.Context, t target, out chan<- sample) error {
+	last, err := readNICCounters(t.address)
+	if err != nil {
+		return err
+	}
+	lastTCP, tcpErr := readTCPCounters()
+	return probeEvery(ctx, t, out, func(_ int, meta map[string]string) (time.Duration, error) {
+		c, err := readNICCounters(t.address)
+		if err != nil {
+			return 0, err
+		}
+		d := c.sub(last)
+		last = c
+		meta["rx_errors"] = strconv.FormatUint(d.rxErrors, 10)
+		meta["rx_dropped"] = strconv.FormatUint(d.rxDropped, 10)
+		meta["tx_errors"] = strconv.FormatUint(d.txErrors, 10)
+		meta["tx_dropped"] = strconv.FormatUint(d.txDropped, 10)
+		if tcp, err := readTCPCounters(); err == nil && tcpErr == nil {
+			if tcp.outSegs > lastTCP.outSegs && tcp.retransSegs >= lastTCP.retransSegs {
+				pct := 100 * float64(tcp.retransSegs-lastTCP.retransSegs) / float64(tcp.outSegs-lastTCP.outSegs)
+				meta["tcp_retrans_pct"] = strconv.FormatFloat(pct, 'f', 1, 64)
+			}
+			lastTCP = tcp
+		}
+		// the count is stored as ms, and shown in errors
+		return time.Duration(d.rxErrors+d.rxDropped+d.txErrors+d.txDropped) * time.Millisecond, nil
+	})
+}
+
+// nicNote describes the counters of a nic:// sample.
+func nicNote(meta map[string]string) note {
+	text := fmt.Sprintf("rx errors %s drops %s, tx errors %s drops %s",
+		meta["rx_errors"], meta["rx_dropped"], meta["tx_errors"], meta["tx_dropped"])
+	if pct, ok := meta["tcp_retrans_pct"]; ok {
+		text += fmt.Sprintf(", TCP retransmits %s%%", pct)
+	}
+	return note{text: text, warn: meta["rx_errors"] != "0" || meta["tx_errors"] != "0"}
+}
+
+// withNICTargets adds a nic:// target for every interface targets go out
+// from.
+func withNICTargets(targets []target) []target {
+	seen := map[string]bool{}
+	var nics []target
+	for _, t := range targets {
+		if probeTypes[t.scheme].opaque {
+			continue
+		}
+		iface := t.iface
+		if iface == "" {
+			var ok bool
+			if iface, ok = routeInterface(hostOf(t)); !ok {
+				continue
+			}
+		}
+		if !seen[iface] {
+			seen[iface] = true
+			nics = append(nics, target{scheme: "nic", address: iface, group: iface})
+		}
+	}
+	return append(targets, nics...)
+}
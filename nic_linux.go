@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readNICCounters reads the counters of iface from sysfs.
+func readNICCounters(iface string) (nicCounters, error) {
+	var c nicCounters
+	dir := filepath.Join("/sys/class/net", iface, "statistics")
+	for name, dst := range map[string]*uint64{
+		"rx_errors": &c.rxErrors, "rx_dropped": &c.rxDropped,
+		"tx_errors": &c.txErrors, "tx_dropped": &c.txDropped,
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return c, err
+		}
+		if *dst, err = strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// readTCPCounters reads the TCP counters of /proc/net/snmp, a line with the
+// names of the fields followed by one with their values.
+func readTCPCounters() (tcpCounters, error) {
+	f, err := os.Open("/proc/net/snmp")
+	if err != nil {
+		return tcpCounters{}, err
+	}
+	defer f.Close()
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "Tcp:" {
+			continue
+		}
+		if names == nil {
+			names = fields
+			continue
+		}
+		var c tcpCounters
+		for i, name := range names {
+			if i >= len(fields) {
+				break
+			}
+			switch name {
+			case "OutSegs":
+				c.outSegs, _ = strconv.ParseUint(fields[i], 10, 64)
+			case "RetransSegs":
+				c.retransSegs, _ = strconv.ParseUint(fields[i], 10, 64)
+			}
+		}
+		return c, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return tcpCounters{}, err
+	}
+	return tcpCounters{}, errors.New("no TCP counters in /proc/net/snmp")
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+var errNICCounters = errors.New("interface counters are only read on Linux")
+
+func readNICCounters(iface string) (nicCounters, error) {
+	return nicCounters{}, errNICCounters
+}
+
+func readTCPCounters() (tcpCounters, error) {
+	return tcpCounters{}, errNICCounters
+}
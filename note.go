@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// note is extra information from the metadata of the samples of a target
+// shown next to its RTT, such as the expiry of a TLS certificate.
+type note struct {
+	text string
+	warn bool // highlight the target
+}
+
+// noteOf describes the metadata of a sample.
+func noteOf(meta map[string]string) (note, bool) {
+	if v, ok := meta["cert_days"]; ok {
+		days, _ := strconv.Atoi(v)
+		return note{text: fmt.Sprintf("cert expires in %d days", days), warn: days < *certWarnDays}, true
+	}
+	if hop, ok := meta["hop"]; ok {
+		return note{text: "ttl expired at " + hop}, true
+	}
+	if offset, ok := meta["offset_ms"]; ok {
+		return note{text: fmt.Sprintf("clock offset %s ms", offset)}, true
+	}
+	if up, ok := meta["up_ms"]; ok {
+		text := fmt.Sprintf("one way ↑ %s ms ↓ %s ms", up, meta["down_ms"])
+		if lost, ok := meta["up_lost"]; ok {
+			text += fmt.Sprintf(", lost ↑ %s ↓ %s", lost, meta["down_lost"])
+		}
+		return note{text: text}, true
+	}
+	if _, ok := meta["down_mbps"]; ok {
+		return snmpNote(meta), true
+	}
+	if _, ok := meta["rx_errors"]; ok {
+		return nicNote(meta), true
+	}
+	if mapped, ok := meta["mapped"]; ok {
+		return note{text: "seen as " + mapped}, true
+	}
+	if node, ok := meta["node"]; ok {
+		return note{text: "node " + node}, true
+	}
+	if ttl, err := strconv.Atoi(meta["ttl"]); err == nil {
+		if hops, ok := hopsOf(ttl); ok {
+			return note{text: fmt.Sprintf("ttl %d, %d hops", ttl, hops)}, true
+		}
+	}
+	return note{}, false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+func init() {
+	registerSource("ntp", "NTP", "123", ntpSource)
+}
+
+// ntpEpoch is the start of the NTP era 0, 1900-01-01.
+var ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ntpSource queries the NTP server t with SNTP (RFC 4330). The RTT is the
+// network delay, excluding the time the server took to answer, and the
+// offset of the local clock from the server goes in the offset_ms metadata,
+// so local clock drift shows up next to latency.
+func ntpSource(ctx context.Context, t target, out chan<- sample) error {
+	conn, err := t.dialer("udp", 0).Dial("udp", t.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return probeEvery(ctx, t, out, func(_ int, meta map[string]string) (time.Duration, error) {
+		delay, offset, err := ntpQuery(conn, probeTimeout)
+		if err != nil {
+			return 0, err
+		}
+		meta["offset_ms"] = strconv.FormatFloat(ms(offset), 'f', 1, 64)
+		return delay, nil
+	})
+}
+
+// ntpQuery sends a client request and returns the round trip delay and the
+// clock offset computed from the four timestamps of the exchange.
+func ntpQuery(conn net.Conn, timeout time.Duration) (delay, offset time.Duration, err error) {
+	req := make([]byte, 48)
+	req[0] = 4<<3 | 3 // version 4, client mode
+	t1 := time.Now()
+	// the transmit time comes back as the originate time of the reply
+	binary.BigEndian.PutUint64(req[40:], ntpTime(t1))
+
+	conn.SetDeadline(t1.Add(timeout))
+	if _, err := conn.Write(req); err != nil {
+		return 0, 0, err
+	}
+	resp := make([]byte, 48)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return 0, 0, err
+		}
+		t4 := time.Now()
+		if n < 48 || resp[0]&0x7 != 4 || binary.BigEndian.Uint64(resp[24:]) != ntpTime(t1) {
+			continue // not a server reply to this request
+		}
+		if stratum := resp[1]; stratum == 0 {
+			return 0, 0, fmt.Errorf("kiss of death %q", resp[12:16])
+		}
+		t2 := fromNTPTime(binary.BigEndian.Uint64(resp[32:]))
+		t3 := fromNTPTime(binary.BigEndian.Uint64(resp[40:]))
+		if t3.Before(t2) {
+			return 0, 0, errors.New("bad server timestamps")
+		}
+		delay = t4.Sub(t1) - t3.Sub(t2)
+		offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+		return delay, offset, nil
+	}
+}
+
+func ntpTime(t time.Time) uint64 {
+	d := t.Sub(ntpEpoch)
+	secs := uint64(d / time.Second)
+	frac := uint64(d%time.Second) << 32 / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+func fromNTPTime(v uint64) time.Time {
+	secs := time.Duration(v>>32) * time.Second
+	frac := time.Duration((v & 0xffffffff) * uint64(time.Second) >> 32)
+	return ntpEpoch.Add(secs + frac)
+}
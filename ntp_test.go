@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNTPTime(t *testing.T) {
+	tests := []struct {
+		name string
+		time time.Time
+		want uint64
+	}{
+		{"epoch", ntpEpoch, 0},
+		{"unix epoch", time.Unix(0, 0), 2208988800 << 32},
+		{"half second", ntpEpoch.Add(1500 * time.Millisecond), 1<<32 | 1<<31},
+		{"quarter second", ntpEpoch.Add(250 * time.Millisecond), 1 << 30},
+		{"2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 3913056000 << 32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ntpTime(tt.time); got != tt.want {
+				t.Errorf("ntpTime() = %#x, want %#x", got, tt.want)
+			}
+			if got := fromNTPTime(tt.want); !got.Equal(tt.time) {
+				t.Errorf("fromNTPTime(%#x) = %v, want %v", tt.want, got, tt.time)
+			}
+		})
+	}
+}
+
+func TestNTPTimeRoundTrip(t *testing.T) {
+	// the fraction has a resolution of 2^-32 s, below a nanosecond
+	for _, ns := range []int{1, 999, 123456789, 999999999} {
+		in := time.Date(2026, 10, 16, 12, 0, 0, ns, time.UTC)
+		if got := fromNTPTime(ntpTime(in)); in.Sub(got) < 0 || in.Sub(got) > time.Nanosecond {
+			t.Errorf("fromNTPTime(ntpTime(%v)) = %v", in, got)
+		}
+	}
+}
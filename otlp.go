@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var otlpURL = flag.String("otlp", "",
+	"export samples as metrics, and probes as spans, to this OpenTelemetry collector over OTLP/HTTP, e.g. http://localhost:4318")
+
+const (
+	otlpBatchSize     = 100
+	otlpFlushInterval = 10 * time.Second
+)
+
+// otlpSink exports samples to an OpenTelemetry collector with OTLP/HTTP in
+// JSON, in batches: the RTT of every reply as the netcheck.rtt gauge, the
+// netcheck.probes.sent and netcheck.probes.lost counters of every target,
+// and a span per probe, failed when it got no reply.
+type otlpSink struct {
+	url      *url.URL
+	resource otlpResource
+	start    time.Time // of the counters
+	sent     map[string]int
+	lost     map[string]int
+	samples  chan sample
+	stop     chan struct{}
+	done     chan error
+}
+
+func newOTLPSink(rawURL string) (*otlpSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("OTLP URL %q must be http or https", rawURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	host, _ := os.Hostname()
+	k := &otlpSink{
+		url: u,
+		resource: otlpResource{Attributes: []otlpAttribute{
+			otlpString("service.name", "netcheck"),
+			otlpString("host.name", host),
+		}},
+		start:   time.Now(),
+		sent:    map[string]int{},
+		lost:    map[string]int{},
+		samples: make(chan sample, 10*otlpBatchSize),
+		stop:    make(chan struct{}),
+		done:    make(chan error),
+	}
+	go k.run()
+	return k, nil
+}
+
+func (k *otlpSink) write(s sample) {
+	select {
+	case k.samples <- s:
+	case <-k.stop:
+	default:
+		// the collector is too slow, drop the sample rather than the display
+	}
+}
+
+func (k *otlpSink) close() error {
+	close(k.stop)
+	return <-k.done
+}
+
+func (k *otlpSink) run() {
+	defer resetOnPanic()
+	var batch []sample
+	var lastErr error
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := k.post("/v1/metrics", k.metrics(batch)); err != nil {
+			lastErr = err
+			events.add(time.Now(), "otlp: "+err.Error())
+		} else if err := k.post("/v1/traces", k.traces(batch)); err != nil {
+			lastErr = err
+			events.add(time.Now(), "otlp: "+err.Error())
+		}
+		batch = batch[:0]
+	}
+
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case s := <-k.samples:
+			if batch = append(batch, s); len(batch) == otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-k.stop:
+			for len(k.samples) > 0 {
+				batch = append(batch, <-k.samples)
+			}
+			flush()
+			k.done <- lastErr
+			return
+		}
+	}
+}
+
+func (k *otlpSink) post(path string, body any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	u := *k.url
+	u.Path += path
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(u.String(), "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("OTLP export to %s: %s", path, resp.Status)
+	}
+	return nil
+}
+
+// The OTLP JSON encoding, of the protobuf messages of
+// opentelemetry/proto/collector. 64-bit integers are strings.
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+func otlpString(key, value string) otlpAttribute {
+	return otlpAttribute{key, map[string]string{"stringValue": value}}
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          *float64        `json:"asDouble,omitempty"`
+	AsInt             string          `json:"asInt,omitempty"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Unit  string     `json:"unit"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"` // 2 is cumulative
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"` // 3 is client
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            struct {
+		Message string `json:"message,omitempty"`
+		Code    int    `json:"code"` // 1 is ok, 2 is error
+	} `json:"status"`
+}
+
+var otlpScope = map[string]string{"name": "netcheck"}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpTargetAttributes(s sample) []otlpAttribute {
+	return []otlpAttribute{otlpString("target", s.target.String()), otlpString("probe", s.target.scheme)}
+}
+
+// metrics returns the export request of the metrics of batch.
+func (k *otlpSink) metrics(batch []sample) any {
+	rtt := otlpMetric{Name: "netcheck.rtt", Unit: "ms", Gauge: &otlpGauge{}}
+	latest := map[string]sample{}
+	var order []string
+	for _, s := range batch {
+		t := s.target.String()
+		k.sent[t]++
+		if s.lost() {
+			k.lost[t]++
+		} else {
+			v := ms(s.rtt)
+			rtt.Gauge.DataPoints = append(rtt.Gauge.DataPoints, otlpDataPoint{
+				Attributes: otlpTargetAttributes(s), TimeUnixNano: otlpTime(s.time), AsDouble: &v,
+			})
+		}
+		if _, ok := latest[t]; !ok {
+			order = append(order, t)
+		}
+		latest[t] = s
+	}
+
+	sent := otlpMetric{Name: "netcheck.probes.sent", Unit: "1", Sum: &otlpSum{AggregationTemporality: 2, IsMonotonic: true}}
+	lost := otlpMetric{Name: "netcheck.probes.lost", Unit: "1", Sum: &otlpSum{AggregationTemporality: 2, IsMonotonic: true}}
+	for _, t := range order {
+		s := latest[t]
+		for _, m := range []struct {
+			sum *otlpSum
+			n   int
+		}{{sent.Sum, k.sent[t]}, {lost.Sum, k.lost[t]}} {
+			m.sum.DataPoints = append(m.sum.DataPoints, otlpDataPoint{
+				Attributes: otlpTargetAttributes(s), StartTimeUnixNano: otlpTime(k.start),
+				TimeUnixNano: otlpTime(s.time), AsInt: strconv.Itoa(m.n),
+			})
+		}
+	}
+
+	metrics := []otlpMetric{sent, lost}
+	if len(rtt.Gauge.DataPoints) > 0 {
+		metrics = append(metrics, rtt)
+	}
+	return map[string]any{"resourceMetrics": []any{map[string]any{
+		"resource":     k.resource,
+		"scopeMetrics": []any{map[string]any{"scope": otlpScope, "metrics": metrics}},
+	}}}
+}
+
+// traces returns the export request of a span per probe of batch, each in
+// its own trace.
+func (k *otlpSink) traces(batch []sample) any {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		span := &spans[i]
+		span.TraceID, span.SpanID = otlpID(16), otlpID(8)
+		span.Name = "probe " + s.target.scheme
+		span.Kind = 3
+		span.StartTimeUnixNano = otlpTime(s.time)
+		span.Attributes = append(otlpTargetAttributes(s), otlpAttribute{"seq", map[string]string{"intValue": strconv.Itoa(s.seq)}})
+		if s.lost() {
+			span.EndTimeUnixNano = span.StartTimeUnixNano
+			span.Status.Code, span.Status.Message = 2, s.err.Error()
+		} else {
+			span.EndTimeUnixNano = otlpTime(s.time.Add(s.rtt))
+			span.Status.Code = 1
+		}
+	}
+	return map[string]any{"resourceSpans": []any{map[string]any{
+		"resource":   k.resource,
+		"scopeSpans": []any{map[string]any{"scope": otlpScope, "spans": spans}},
+	}}}
+}
+
+// otlpID returns a random trace or span ID of n bytes, in hex.
+func otlpID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// ema returns the exponential moving average of data, where alpha in (0, 1]
+// is the weight given to each new sample.
+func ema(data []float64, alpha float64) []float64 {
+	smoothed := make([]float64, len(data))
+	for i, v := range data {
+		if i == 0 {
+			smoothed[i] = v
+			continue
+		}
+		smoothed[i] = alpha*v + (1-alpha)*smoothed[i-1]
+	}
+	return smoothed
+}
+
+// constant returns a series of n points of value v.
+func constant(n int, v float64) []float64 {
+	series := make([]float64, n)
+	for i := range series {
+		series[i] = v
+	}
+	return series
+}
+
+// overlay draws series on top of a graph rendered by asciigraph with the same
+// number of points, using mark on the cells left blank by the plotted line.
+func overlay(graph string, series []float64, maxValue float64, mark rune) string {
+	lines := strings.Split(graph, "\n")
+
+	// the plot rows are the ones holding the Y axis
+	var rows [][]rune
+	for _, line := range lines {
+		if !strings.ContainsAny(line, "┤┼") {
+			break
+		}
+		rows = append(rows, []rune(line))
+	}
+	if len(rows) < 2 || maxValue <= 0 {
+		return graph
+	}
+
+	height := float64(len(rows) - 1)
+	for x, v := range series {
+		row := len(rows) - 1 - int(math.Round(math.Min(v, maxValue)/maxValue*height))
+		if row < 0 {
+			row = 0
+		}
+		line := rows[row]
+		axis := strings.IndexFunc(string(line), func(r rune) bool { return r == '┤' || r == '┼' })
+		col := len([]rune(string(line)[:axis])) + 1 + x
+		if col < len(line) && line[col] == ' ' {
+			line[col] = mark
+		}
+	}
+
+	for i, row := range rows {
+		lines[i] = string(row)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// percentileBand returns the p-th percentile of every point of data over
+// the sliding window of points ending at it.
+func percentileBand(data []float64, window int, p float64) []float64 {
+	band := make([]float64, len(data))
+	for i := range data {
+		from := i - window + 1
+		if from < 0 {
+			from = 0
+		}
+		band[i] = percentile(data[from:i+1], p)
+	}
+	return band
+}
+
+// shade fills the blank cells of a graph rendered by asciigraph between the
+// lo and hi series with mark, so that the plotted line stays on top.
+func shade(graph string, lo, hi []float64, maxValue float64, mark rune) string {
+	lines := strings.Split(graph, "\n")
+	var rows [][]rune
+	for _, line := range lines {
+		if !strings.ContainsAny(line, "┤┼") {
+			break
+		}
+		rows = append(rows, []rune(line))
+	}
+	if len(rows) < 2 || maxValue <= 0 {
+		return graph
+	}
+
+	height := float64(len(rows) - 1)
+	rowOf := func(v float64) int {
+		return len(rows) - 1 - int(math.Round(math.Min(v, maxValue)/maxValue*height))
+	}
+	for x := range lo {
+		for row := rowOf(hi[x]); row <= rowOf(lo[x]); row++ {
+			line := rows[row]
+			axis := strings.IndexFunc(string(line), func(r rune) bool { return r == '┤' || r == '┼' })
+			col := len([]rune(string(line)[:axis])) + 1 + x
+			if col < len(line) && line[col] == ' ' {
+				line[col] = mark
+			}
+		}
+	}
+
+	for i, row := range rows {
+		lines[i] = string(row)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// annotate adds a line under the plot of a graph rendered by asciigraph with
+// the marks below their data columns.
+func annotate(graph string, marks map[int]rune) string {
+	row := []rune(strings.Repeat(" ", maxLen))
+	for col, mark := range marks {
+		if col < len(row) {
+			row[col] = mark
+		}
+	}
+	return belowPlot(graph, row)
+}
+
+// belowPlot inserts a line under the plot of a graph rendered by asciigraph,
+// row[i] being aligned with data column i.
+func belowPlot(graph string, row []rune) string {
+	lines := strings.Split(graph, "\n")
+	for i, line := range lines {
+		axis := strings.IndexFunc(line, func(r rune) bool { return r == '┤' || r == '┼' })
+		if axis >= 0 && (i+1 == len(lines) || !strings.ContainsAny(lines[i+1], "┤┼")) {
+			indent := strings.Repeat(" ", len([]rune(line[:axis]))+1)
+			below := strings.TrimRight(indent+string(row), " ")
+			rest := append([]string{below}, lines[i+1:]...)
+			return strings.Join(append(lines[:i+1], rest...), "\n")
+		}
+	}
+	return graph
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"flag"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+var pingSize = flag.Int("size", 56,
+	"payload size in bytes of ICMP echo requests, targets can override it with ?size=")
+var pingTTL = flag.Int("ttl", 0,
+	"TTL of ICMP echo requests, 0 for the system default, targets can override it with ?ttl=")
+
+// icmpTokenLen is the size of the random token starting every payload, that
+// tells our replies apart from the ones to other pingers on raw sockets.
+const icmpTokenLen = 8
+
+// pingReplied is how many of the latest replies duplicates are looked for
+// in.
+const pingReplied = 16
+
+// pinger sends ICMP echo requests to a host and waits for their replies.
+type pinger struct {
+	conn  net.PacketConn
+	conn4 *ipv4.PacketConn // conn, to read the TTL of replies, when possible
+	conn6 *ipv6.PacketConn
+	dst   net.Addr
+	v6    bool
+	token []byte
+	size  int
+	hop   net.Addr // router that answered the last request, when TTL limited
+	late  int      // replies to earlier requests read while waiting for the last one
+	dups  int      // replies read again while waiting for the last one
+	ttl   int      // of the last reply, 0 when the system does not tell
+	// replied are the sequence numbers of the latest replies, to tell
+	// duplicates from late replies
+	replied []int
+}
+
+func init() {
+	registerSource("icmp", "PING", "", icmpSource)
+}
+
+// icmpSource pings t with echo requests of -size bytes, limited to -ttl hops
+// if set. A request is lost when no reply arrived after probeTimeout.
+func icmpSource(ctx context.Context, t target, out chan<- sample) error {
+	p, err := newPinger(t)
+	if err != nil {
+		return err
+	}
+	defer func() { p.conn.Close() }()
+
+	return probeEvery(ctx, t, out, func(seq int, meta map[string]string) (time.Duration, error) {
+		if currentSettings().followDNS && moved(t, addrIP(p.dst)) {
+			// the host resolves to another address, ping that one
+			if moved, err := newPinger(t); err == nil {
+				p.conn.Close()
+				p = moved
+			}
+		}
+		rtt, err := p.ping(seq, probeTimeout)
+		if err == nil && p.hop != nil {
+			meta["hop"] = addrIP(p.hop)
+		}
+		if p.late > 0 {
+			meta["late"] = strconv.Itoa(p.late)
+		}
+		if p.dups > 0 {
+			meta["dup"] = strconv.Itoa(p.dups)
+		}
+		if err == nil && p.hop == nil && p.ttl > 0 {
+			meta["ttl"] = strconv.Itoa(p.ttl)
+		}
+		return rtt, err
+	})
+}
+
+// newPinger opens an unprivileged ICMP socket from the source address of t,
+// falling back to a raw socket where those are not allowed.
+func newPinger(t target) (*pinger, error) {
+	ip, err := net.ResolveIPAddr("ip", t.address)
+	if err != nil {
+		return nil, err
+	}
+	p := &pinger{v6: ip.IP.To4() == nil, size: *pingSize, token: newToken()}
+	if t.size > 0 {
+		p.size = t.size
+	}
+	if p.size < icmpTokenLen {
+		p.size = icmpTokenLen
+	}
+
+	network, raw := "udp4", "ip4:icmp"
+	if p.v6 {
+		network, raw = "udp6", "ip6:ipv6-icmp"
+	}
+	p.dst = &net.UDPAddr{IP: ip.IP, Zone: ip.Zone}
+	conn, err := icmp.ListenPacket(network, t.source)
+	if err != nil {
+		p.dst = ip
+		if conn, err = icmp.ListenPacket(raw, t.source); err != nil {
+			return nil, err
+		}
+	}
+	p.conn = conn
+
+	ttl := *pingTTL
+	if t.ttl > 0 {
+		ttl = t.ttl
+	}
+	// the TTL of replies tells how many hops away the host is
+	if p.v6 {
+		if p.conn6 = conn.IPv6PacketConn(); p.conn6.SetControlMessage(ipv6.FlagHopLimit, true) != nil {
+			p.conn6 = nil
+		}
+	} else if p.conn4 = conn.IPv4PacketConn(); p.conn4.SetControlMessage(ipv4.FlagTTL, true) != nil {
+		p.conn4 = nil
+	}
+	if ttl > 0 {
+		if p.v6 {
+			err = conn.IPv6PacketConn().SetHopLimit(ttl)
+		} else {
+			err = conn.IPv4PacketConn().SetTTL(ttl)
+		}
+		if err != nil {
+			p.conn.Close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func newToken() []byte {
+	token := make([]byte, icmpTokenLen)
+	rand.Read(token)
+	return token
+}
+
+// ping sends an echo request and returns how long its reply took. With a
+// TTL too low to reach the host, the time exceeded message of the router
+// where the request expired is the reply, and hop is set to the router.
+func (p *pinger) ping(seq int, timeout time.Duration) (time.Duration, error) {
+	data := make([]byte, p.size)
+	copy(data, p.token)
+	msg := icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: seq >> 16, Seq: seq & 0xffff, Data: data}}
+	proto, replyType := 1, icmp.Type(ipv4.ICMPTypeEchoReply)
+	if p.v6 {
+		msg.Type, proto, replyType = ipv6.ICMPTypeEchoRequest, 58, ipv6.ICMPTypeEchoReply
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	p.conn.SetReadDeadline(start.Add(timeout))
+	if _, err := p.conn.WriteTo(b, p.dst); err != nil {
+		return 0, err
+	}
+
+	p.hop, p.late, p.dups = nil, 0, 0
+	buf := make([]byte, 1500+p.size)
+	for {
+		n, ttl, from, err := p.read(buf)
+		if err != nil {
+			return 0, err
+		}
+		rtt := time.Since(start)
+		b := buf[:n]
+		// some systems deliver the IP header on unprivileged sockets too
+		if !p.v6 && n >= ipv4.HeaderLen && b[0]>>4 == 4 {
+			ttl = int(b[8])
+			b = b[int(b[0]&0x0f)*4:]
+		}
+		reply, err := icmp.ParseMessage(proto, b)
+		if err != nil {
+			continue
+		}
+		switch body := reply.Body.(type) {
+		case *icmp.Echo:
+			// raw sockets read back our own requests to local addresses
+			if reply.Type != replyType || !bytes.HasPrefix(body.Data, p.token) {
+				continue
+			}
+			switch {
+			case slices.Contains(p.replied, body.Seq):
+				// a network loop or a middlebox sent it twice
+				p.dups++
+				continue
+			case body.Seq != seq&0xffff:
+				// the reply to a request given up as lost, out of order
+				p.late++
+			}
+			if p.replied = append(p.replied, body.Seq); len(p.replied) > pingReplied {
+				p.replied = p.replied[1:]
+			}
+			if body.Seq == seq&0xffff {
+				p.ttl = ttl
+				return rtt, nil
+			}
+		case *icmp.TimeExceeded:
+			// the expired request follows the IP header of the router reply
+			if bytes.Contains(body.Data, p.token) {
+				p.hop = from
+				return rtt, nil
+			}
+		}
+	}
+}
+
+// read reads a packet into b, with its TTL or hop limit if the system
+// tells it.
+func (p *pinger) read(b []byte) (n, ttl int, from net.Addr, err error) {
+	switch {
+	case p.conn6 != nil:
+		n, cm, from, err := p.conn6.ReadFrom(b)
+		if cm != nil {
+			ttl = cm.HopLimit
+		}
+		return n, ttl, from, err
+	case p.conn4 != nil:
+		n, cm, from, err := p.conn4.ReadFrom(b)
+		if cm != nil {
+			ttl = cm.TTL
+		}
+		return n, ttl, from, err
+	}
+	n, from, err = p.conn.ReadFrom(b)
+	return n, 0, from, err
+}
+
+// addrIP strips the port from the UDP addresses of unprivileged sockets.
+func addrIP(a net.Addr) string {
+	if u, ok := a.(*net.UDPAddr); ok {
+		return u.IP.String()
+	}
+	return a.String()
+}
+
+// newPing pings address and sends the RTT in milliseconds of every reply to
+// out. It is kept for callers that only need the plain RTTs.
+func newPing(ctx context.Context, address string, out chan int64) error {
+	samples := make(chan sample)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- icmpSource(ctx, target{scheme: "icmp", address: address}, samples)
+		close(samples)
+	}()
+
+	for rtt := range rtts(samples) {
+		out <- rtt
+	}
+	return <-errc
+}
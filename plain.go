@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var plain = flag.Bool("plain", false,
+	"print a status line per target every 10 seconds instead of graphs, for screen readers and narrow terminals")
+
+const (
+	plainInterval = 10 * time.Second
+	// trendMin is how much the average RTT must change between two status
+	// lines to be a trend, as a fraction and in ms.
+	trendMinFraction = 0.1
+	trendMinMs       = 1
+)
+
+// printStatus prints a line per target with its average RTT, trend and loss
+// over the last period, e.g.
+//
+//	gateway 192.168.1.1: 3.2 ms, ↑ rising, 0% loss
+func printStatus(all []*series, period time.Duration) {
+	now := time.Now()
+	for _, s := range all {
+		avg, sent, lost := s.period(now.Add(-period), now)
+		line := fmt.Sprintf("%s %s: ", probeNames[s.target.scheme], s.target)
+		if s.target.label != "" {
+			line = fmt.Sprintf("%s %s: ", s.target.label, s.target)
+		}
+		switch {
+		case sent == 0:
+			line += "no probes"
+		case sent == lost:
+			line += "no replies"
+		default:
+			line += formatValue(s.target, avg)
+			prev, prevSent, prevLost := s.period(now.Add(-2*period), now.Add(-period))
+			if prevSent > prevLost {
+				switch change := avg - prev; {
+				case change > trendMinMs && change > prev*trendMinFraction:
+					line += ", ↑ rising"
+				case -change > trendMinMs && -change > prev*trendMinFraction:
+					line += ", ↓ falling"
+				default:
+					line += ", → steady"
+				}
+			}
+		}
+		if sent > 0 {
+			line += fmt.Sprintf(", %.0f%% loss", 100*float64(lost)/float64(sent))
+		}
+		fmt.Println(line)
+	}
+}
+
+// period returns the average RTT in ms of the replies of s sent between from
+// and to, and how many probes were sent and lost then. The replies merged
+// by downsampling count with the highest RTT of their point.
+func (s *series) period(from, to time.Time) (avg float64, sent, lost int) {
+	var sum float64
+	for i := len(s.history) - 1; i >= 0 && !s.history[i].time.Before(from); i-- {
+		if s.history[i].time.Before(to) {
+			sum += s.history[i].rtt * float64(s.history[i].n)
+			sent += s.history[i].n
+		}
+	}
+	for i := len(s.lost) - 1; i >= 0 && !s.lost[i].time.Before(from); i-- {
+		if s.lost[i].time.Before(to) {
+			lost += s.lost[i].n
+		}
+	}
+	if sent > 0 {
+		avg = sum / float64(sent)
+	}
+	return avg, sent + lost, lost
+}
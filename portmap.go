@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackpal/gateway"
+)
+
+var routerIP = flag.Bool("router-ip", false,
+	"ask the router for its external IP with NAT-PMP or UPnP, logging when it changes, and check that it maps ports reachable from the internet, with a -stun server having an alternate address")
+
+const routerIPInterval = time.Minute
+
+// portMapper is a protocol routers open ports of their NAT with.
+type portMapper interface {
+	String() string
+	externalIP() (net.IP, error)
+	// mapPort maps a UDP port to the internal one for lifetime, and returns
+	// it. A lifetime of 0 deletes the mapping.
+	mapPort(internal, external int, lifetime time.Duration) (int, error)
+}
+
+// routerExternal is the latest external IP the router reported.
+var routerExternal struct {
+	sync.Mutex
+	ip net.IP
+}
+
+// watchRouterIP logs the external IP of the router and whether it maps
+// ports reachable from the internet, and an event whenever the IP changes, as reconnections of the WAN
+// link correlate with latency blips.
+func watchRouterIP(ctx context.Context) {
+	defer resetOnPanic()
+	if !*routerIP {
+		return
+	}
+	m, err := findPortMapper()
+	if err != nil {
+		events.add(time.Now(), "cannot ask the router for its external IP: "+err.Error())
+		return
+	}
+	var last net.IP
+	check := func(now time.Time) {
+		ip, err := m.externalIP()
+		if err != nil {
+			events.add(now, fmt.Sprintf("%s: %v", m, err))
+			return
+		}
+		routerExternal.Lock()
+		routerExternal.ip = ip
+		routerExternal.Unlock()
+		switch {
+		case last == nil:
+			events.add(now, fmt.Sprintf("external IP of the router is %s (%s), %s", ip, m, portMappingText(m, ip)))
+		case !ip.Equal(last):
+			events.add(now, fmt.Sprintf("external IP of the router changed from %s to %s, %s", last, ip, portMappingText(m, ip)))
+		}
+		last = ip
+	}
+	check(time.Now())
+
+	ticker := time.NewTicker(routerIPInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			check(now)
+		}
+	}
+}
+
+// portMappingText tells whether m maps a port, and whether the port is then
+// reachable from the internet, deleting the mapping at once.
+func portMappingText(m portMapper, external net.IP) string {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return "cannot check port mappings: " + err.Error()
+	}
+	defer conn.Close()
+	internal := conn.LocalAddr().(*net.UDPAddr).Port
+	port, err := m.mapPort(internal, internal, time.Minute)
+	if err != nil {
+		return "cannot map ports: " + err.Error()
+	}
+	defer m.mapPort(internal, port, 0)
+	return reachableText(conn, external)
+}
+
+// reachableText tells whether the port of the router mapped to conn is
+// reachable from the internet. The -stun server must see conn at the
+// external IP of the router, and its replies from its alternate address
+// and port, which no request went to, must get through.
+func reachableText(conn net.PacketConn, external net.IP) string {
+	server, err := resolveSTUN(*stunServer)
+	if err != nil {
+		return "it maps ports, but cannot check that they are reachable: " + err.Error()
+	}
+	r, err := stunTry(conn, server, 0)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("it maps ports, but cannot check that they are reachable: STUN server %s: %v", *stunServer, err)
+	case !r.mapped.IP.Equal(external):
+		return fmt.Sprintf("it maps ports, but they are not reachable from the internet, which sees %s behind another NAT", r.mapped.IP)
+	case r.other == nil:
+		return fmt.Sprintf("it maps ports, but cannot check that they are reachable: STUN server %s has no alternate address", *stunServer)
+	case stunReplies(conn, server, stunChangeIP|stunChangePort):
+		return "it maps ports reachable from the internet"
+	default:
+		return "it maps ports, but they are not reachable from the internet, filtered beyond the router"
+	}
+}
+
+// findPortMapper returns the protocol the gateway maps ports with, NAT-PMP
+// (or PCP routers answering it) or else UPnP.
+func findPortMapper() (portMapper, error) {
+	gw, err := gateway.DiscoverGateway()
+	if err != nil {
+		return nil, err
+	}
+	pmp := natPMP{gateway: gw}
+	if _, err := pmp.externalIP(); err == nil {
+		return pmp, nil
+	}
+	igd, upnpErr := discoverIGD()
+	if upnpErr != nil {
+		return nil, fmt.Errorf("%s answers neither NAT-PMP nor UPnP: %v", gw, upnpErr)
+	}
+	return igd, nil
+}
+
+// natPMP speaks NAT-PMP (RFC 6886) to the gateway.
+type natPMP struct {
+	gateway net.IP
+}
+
+func (p natPMP) String() string { return "NAT-PMP" }
+
+func (p natPMP) externalIP() (net.IP, error) {
+	resp, err := p.request([]byte{0, 0}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IP(resp[8:12]), nil
+}
+
+func (p natPMP) mapPort(internal, external int, lifetime time.Duration) (int, error) {
+	if lifetime == 0 {
+		external = 0 // as deletions must
+	}
+	req := []byte{0, 1, 0, 0} // UDP
+	req = binary.BigEndian.AppendUint16(req, uint16(internal))
+	req = binary.BigEndian.AppendUint16(req, uint16(external))
+	req = binary.BigEndian.AppendUint32(req, uint32(lifetime.Seconds()))
+	resp, err := p.request(req, 16)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(resp[10:])), nil
+}
+
+// natPMPResults are the result codes of NAT-PMP responses.
+var natPMPResults = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// request sends req to the gateway and returns the response, of size bytes,
+// retrying as the RFC says, though for a second at most.
+func (p natPMP) request(req []byte, size int) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: p.gateway, Port: 5351})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	buf := make([]byte, 16)
+	for wait := 250 * time.Millisecond; wait <= 500*time.Millisecond; wait *= 2 {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(wait))
+		n, err := conn.Read(buf)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n < size || buf[1] != req[1]|0x80 {
+			return nil, errors.New("bad NAT-PMP response")
+		}
+		if result := binary.BigEndian.Uint16(buf[2:]); result != 0 {
+			return nil, fmt.Errorf("NAT-PMP error: %s", natPMPResults[result])
+		}
+		return buf[:n], nil
+	}
+	return nil, errTimeout
+}
+
+// igd is the WAN connection service of a UPnP Internet Gateway Device.
+type igd struct {
+	controlURL  string
+	serviceType string
+	local       net.IP // address the router sees us at
+}
+
+func (g igd) String() string { return "UPnP" }
+
+// discoverIGD finds the router with SSDP, and its WAN connection service
+// in its description.
+func discoverIGD() (igd, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return igd{}, err
+	}
+	defer conn.Close()
+	ssdp := &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), ssdp); err != nil {
+		return igd{}, err
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 2048)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return igd{}, errors.New("no UPnP router found")
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		location := resp.Header.Get("Location")
+		if location == "" {
+			continue
+		}
+		g, err := describeIGD(location)
+		if err != nil {
+			continue
+		}
+		if g.local, err = localAddrTo(from.(*net.UDPAddr).IP.String()); err != nil {
+			return igd{}, err
+		}
+		return g, nil
+	}
+}
+
+// igdDevice is a device of a UPnP description, with its services and its
+// embedded devices.
+type igdDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []igdDevice `xml:"deviceList>device"`
+}
+
+// find returns the first WAN connection service of d or its devices.
+func (d igdDevice) find() (serviceType, controlURL string, ok bool) {
+	for _, s := range d.Services {
+		if strings.Contains(s.ServiceType, ":WANIPConnection:") || strings.Contains(s.ServiceType, ":WANPPPConnection:") {
+			return s.ServiceType, s.ControlURL, true
+		}
+	}
+	for _, sub := range d.Devices {
+		if serviceType, controlURL, ok := sub.find(); ok {
+			return serviceType, controlURL, true
+		}
+	}
+	return "", "", false
+}
+
+func describeIGD(location string) (igd, error) {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(location)
+	if err != nil {
+		return igd{}, err
+	}
+	defer resp.Body.Close()
+	var root struct {
+		Device igdDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&root); err != nil {
+		return igd{}, err
+	}
+	serviceType, controlURL, ok := root.Device.find()
+	if !ok {
+		return igd{}, errors.New("no WAN connection service in " + location)
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return igd{}, err
+	}
+	control, err := base.Parse(controlURL)
+	if err != nil {
+		return igd{}, err
+	}
+	return igd{controlURL: control.String(), serviceType: serviceType}, nil
+}
+
+func (g igd) externalIP() (net.IP, error) {
+	resp, err := g.call("GetExternalIPAddress", nil)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(resp["NewExternalIPAddress"])
+	if ip == nil {
+		return nil, errors.New("the router has no external IP")
+	}
+	return ip, nil
+}
+
+func (g igd) mapPort(internal, external int, lifetime time.Duration) (int, error) {
+	if lifetime == 0 {
+		_, err := g.call("DeletePortMapping", [][2]string{
+			{"NewRemoteHost", ""}, {"NewExternalPort", strconv.Itoa(external)}, {"NewProtocol", "UDP"},
+		})
+		return external, err
+	}
+	_, err := g.call("AddPortMapping", [][2]string{
+		{"NewRemoteHost", ""}, {"NewExternalPort", strconv.Itoa(external)}, {"NewProtocol", "UDP"},
+		{"NewInternalPort", strconv.Itoa(internal)}, {"NewInternalClient", g.local.String()},
+		{"NewEnabled", "1"}, {"NewPortMappingDescription", "netcheck"},
+		{"NewLeaseDuration", strconv.Itoa(int(lifetime.Seconds()))},
+	})
+	return external, err
+}
+
+// call invokes a SOAP action of the service with args, in order, and
+// returns the elements of the response by name.
+func (g igd) call(action string, args [][2]string) (map[string]string, error) {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:%s xmlns:u="%s">`, action, g.serviceType)
+	for _, arg := range args {
+		fmt.Fprintf(&body, "<%s>", arg[0])
+		xml.EscapeText(&body, []byte(arg[1]))
+		fmt.Fprintf(&body, "</%s>", arg[0])
+	}
+	fmt.Fprintf(&body, "</u:%s></s:Body></s:Envelope>", action)
+
+	req, err := http.NewRequest(http.MethodPost, g.controlURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, g.serviceType, action))
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	values := map[string]string{}
+	dec := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20))
+	var name string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			name = tok.Name.Local
+		case xml.CharData:
+			if name != "" {
+				values[name] += string(tok)
+			}
+		case xml.EndElement:
+			name = ""
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		if desc := values["errorDescription"]; desc != "" {
+			return nil, fmt.Errorf("UPnP %s: %s", action, desc)
+		}
+		return nil, fmt.Errorf("UPnP %s: %s", action, resp.Status)
+	}
+	return values, nil
+}
+
+// localAddrTo returns the local address packets to host go out from.
+// Connecting a UDP socket picks the route, without sending anything.
+func localAddrTo(host string) (net.IP, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, "9"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// probe measures a target. Start sends a sample for every probe, including
+// the ones that got no reply, until ctx is done or Stop is called, and then
+// closes the channel.
+type probe interface {
+	Name() string
+	Start(ctx context.Context) <-chan sample
+	Stop()
+}
+
+// probeType is a kind of probe, selected by the scheme of the targets.
+type probeType struct {
+	name     string // caption prefix, e.g. "PING"
+	port     string // default port of the addresses, none if the probe has no ports
+	opaque   bool   // the address is not a host, e.g. a command, and is kept as is
+	unit     string // unit of the values, stored as ms, of probes not measuring RTTs
+	newProbe func(t target) probe
+}
+
+// unit returns the unit of the values of t, or "" for RTTs.
+func (t target) unit() string {
+	return probeTypes[t.scheme].unit
+}
+
+// probeTypes are the registered probe types by scheme.
+var probeTypes = map[string]probeType{}
+
+// registerProbe makes targets with the given scheme use pt. Probe types
+// register themselves from init functions.
+func registerProbe(scheme string, pt probeType) {
+	if _, ok := probeTypes[scheme]; ok {
+		panic("probe type registered twice: " + scheme)
+	}
+	probeTypes[scheme] = pt
+	probeNames[scheme] = pt.name
+}
+
+// registerSource registers a probe type implemented by a pingSource.
+func registerSource(scheme, name, port string, source pingSource) {
+	registerProbe(scheme, probeType{name: name, port: port, newProbe: func(t target) probe {
+		return &sourceProbe{name: name, target: t, source: source}
+	}})
+}
+
+// sourceProbe adapts a pingSource to the probe interface, and logs why the
+// source failed.
+type sourceProbe struct {
+	name   string
+	target target
+	source pingSource
+	cancel context.CancelFunc
+	err    error // why the source failed, set before the samples are closed
+}
+
+func (p *sourceProbe) Name() string { return p.name }
+
+func (p *sourceProbe) Start(ctx context.Context) <-chan sample {
+	ctx, p.cancel = context.WithCancel(ctx)
+	out := make(chan sample)
+	go func() {
+		defer resetOnPanic()
+		defer close(out)
+		if p.err = p.source(ctx, p.target, out); p.err != nil {
+			events.add(time.Now(), fmt.Sprintf("cannot probe %s: %v", p.target, p.err))
+		}
+	}()
+	return out
+}
+
+// Err returns why the source failed, once the samples of Start are closed.
+func (p *sourceProbe) Err() error { return p.err }
+
+func (p *sourceProbe) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
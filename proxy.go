@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+var proxyURL = flag.String("proxy", "",
+	"send TCP based probes (tcp, tls, echo+tcp) through this SOCKS5 or HTTP proxy, e.g. socks5://127.0.0.1:1080")
+
+// probeProxy is the parsed -proxy flag, if any.
+var probeProxy *url.URL
+
+func init() {
+	proxy.RegisterDialerType("http", newHTTPProxy)
+}
+
+// setupProxy validates the -proxy flag.
+func setupProxy() error {
+	if *proxyURL == "" {
+		return nil
+	}
+	u, err := url.Parse(*proxyURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" && u.Scheme != "http" {
+		return fmt.Errorf("unsupported proxy %q, use socks5:// or http://", *proxyURL)
+	}
+	probeProxy = u
+	return nil
+}
+
+// contextDialer is implemented by net.Dialer and the proxy dialers.
+type contextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// streamDialer returns a dialer for the TCP connections of t, through the
+// -proxy if set. Connection times then include the proxy handshake.
+func (t target) streamDialer(timeout time.Duration) (contextDialer, error) {
+	d := t.dialer("tcp", timeout)
+	if probeProxy == nil {
+		return d, nil
+	}
+	pd, err := proxy.FromURL(probeProxy, d)
+	if err != nil {
+		return nil, err
+	}
+	cd, ok := pd.(contextDialer)
+	if !ok {
+		return nil, fmt.Errorf("proxy %s cannot be cancelled", probeProxy.Redacted())
+	}
+	return cd, nil
+}
+
+// httpProxy dials through an HTTP proxy with the CONNECT method.
+type httpProxy struct {
+	proxy   *url.URL
+	forward proxy.Dialer
+}
+
+func newHTTPProxy(u *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	return &httpProxy{proxy: u, forward: forward}, nil
+}
+
+func (p *httpProxy) Dial(network, address string) (net.Conn, error) {
+	return p.DialContext(context.Background(), network, address)
+}
+
+func (p *httpProxy) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if cd, ok := p.forward.(contextDialer); ok {
+		conn, err = cd.DialContext(ctx, "tcp", p.proxy.Host)
+	} else {
+		conn, err = p.forward.Dial("tcp", p.proxy.Host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if u := p.proxy.User; u != nil {
+		password, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy: %s", resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// the server spoke first, e.g. an SSH banner, right after the response
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were read into r.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestHTTPProxy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	requests := make(chan *http.Request, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		requests <- req
+		// the greeting of the server follows the response in the same write
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\nhello")
+	}()
+
+	u := &url.URL{Scheme: "http", User: url.UserPassword("user", "secret"), Host: ln.Addr().String()}
+	p, _ := newHTTPProxy(u, &net.Dialer{})
+	conn, err := p.Dial("tcp", "example.com:22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	req := <-requests
+	if req.Method != http.MethodConnect || req.Host != "example.com:22" {
+		t.Errorf("request = %s %s, want CONNECT example.com:22", req.Method, req.Host)
+	}
+	if got := req.Header.Get("Proxy-Authorization"); got != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Errorf("Proxy-Authorization = %q", got)
+	}
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(conn, b); err != nil || string(b) != "hello" {
+		t.Errorf("read %q, %v, want hello", b, err)
+	}
+}
+
+func TestHTTPProxyRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		http.ReadRequest(bufio.NewReader(conn))
+		io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")
+	}()
+
+	p, _ := newHTTPProxy(&url.URL{Scheme: "http", Host: ln.Addr().String()}, &net.Dialer{})
+	if conn, err := p.Dial("tcp", "example.com:443"); err == nil {
+		conn.Close()
+		t.Error("Dial() succeeded through a proxy refusing it")
+	}
+}
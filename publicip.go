@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var publicIP = flag.Bool("public-ip", false,
+	"find the public IP of this host with -stun, or -ip-echo, logging when it changes, and whether it is behind a carrier-grade NAT with -router-ip")
+var ipEchoURL = flag.String("ip-echo", "https://api.ipify.org",
+	"URL answering with the IP of the client, when STUN is blocked")
+
+const publicIPInterval = 5 * time.Minute
+
+// sharedAddressSpace is the range ISPs number the WAN side of routers behind
+// a carrier-grade NAT from (RFC 6598).
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// watchPublicIP logs the public IP of this host and whenever it changes. With
+// -router-ip it compares it with the external IP of the router, which
+// differs when the ISP puts the router behind a carrier-grade NAT, as
+// mobile and many fiber ISPs do: ports cannot be forwarded, and peer to
+// peer games and calls may need relays.
+func watchPublicIP(ctx context.Context) {
+	defer resetOnPanic()
+	if !*publicIP {
+		return
+	}
+	var last net.IP
+	var lastNAT string
+	check := func(now time.Time) {
+		ip, how, err := findPublicIP()
+		if err != nil {
+			events.add(now, "cannot find the public IP: "+err.Error())
+			return
+		}
+		switch {
+		case last == nil:
+			events.add(now, fmt.Sprintf("public IP is %s (%s)", ip, how))
+		case !ip.Equal(last):
+			events.add(now, fmt.Sprintf("public IP changed from %s to %s", last, ip))
+		}
+		last = ip
+
+		routerExternal.Lock()
+		wan := routerExternal.ip
+		routerExternal.Unlock()
+		if nat := natBeyondRouter(ip, wan); nat != lastNAT {
+			if nat != "" {
+				events.add(now, nat)
+			} else if lastNAT != "" {
+				events.add(now, fmt.Sprintf("the router has the public IP %s, no NAT beyond it anymore", ip))
+			}
+			lastNAT = nat
+		}
+	}
+	check(time.Now())
+
+	ticker := time.NewTicker(publicIPInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			check(now)
+		}
+	}
+}
+
+// natBeyondRouter describes the NAT between the router, with the external IP
+// wan, and the internet, where this host is seen from public, or returns ""
+// if there is none or wan is unknown.
+func natBeyondRouter(public, wan net.IP) string {
+	switch {
+	case wan == nil || wan.Equal(public):
+		return ""
+	case sharedAddressSpace.Contains(wan):
+		return fmt.Sprintf("the router is behind a carrier-grade NAT: its external IP %s is in the range ISPs share, and the internet sees %s", wan, public)
+	case wan.IsPrivate():
+		return fmt.Sprintf("double NAT: the external IP of the router %s is private, behind another router seen as %s", wan, public)
+	default:
+		return fmt.Sprintf("the router is behind another NAT, likely carrier-grade: its external IP %s is not %s, the one the internet sees", wan, public)
+	}
+}
+
+// findPublicIP returns the public IP of this host, asking -stun, or -ip-echo
+// when UDP is blocked, and how it was found.
+func findPublicIP() (net.IP, string, error) {
+	server, err := resolveSTUN(*stunServer)
+	if err == nil {
+		var conn net.PacketConn
+		if conn, err = net.ListenPacket("udp4", ":0"); err == nil {
+			var r stunResult
+			r, err = stunBinding(conn, server, 0, probeTimeout)
+			conn.Close()
+			if err == nil {
+				return r.mapped.IP, "STUN", nil
+			}
+		}
+	}
+	stunErr := err
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *ipEchoURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("STUN: %v, %s: %v", stunErr, *ipEchoURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return nil, "", err
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return nil, "", fmt.Errorf("%s did not answer with an IP", *ipEchoURL)
+	}
+	return ip, "HTTP", nil
+}
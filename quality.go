@@ -0,0 +1,229 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+var presetName = flag.String("preset", "",
+	"tune probing and MOS estimates for an activity, and show its quality: gaming or voip, "+
+		"or probe a built-in list of targets when none are given: dns-resolvers, cdns, eu-gaming or us-gaming")
+
+// preset tunes netcheck for an activity sensitive to latency, and may come
+// with targets to probe, so that everyone needs not maintain their own lists.
+type preset struct {
+	targets  []target
+	interval time.Duration
+	// jitterWeight is how many milliseconds of latency a millisecond of
+	// jitter is worth: games and calls buffer to absorb it, at the cost of
+	// delay.
+	jitterWeight float64
+	// delay is added to the RTT by the activity itself, e.g. codecs.
+	delay time.Duration
+}
+
+var presets = map[string]preset{
+	"gaming": {interval: 200 * time.Millisecond, jitterWeight: 3},
+	"voip":   {interval: 500 * time.Millisecond, jitterWeight: 2, delay: 10 * time.Millisecond},
+	"dns-resolvers": {targets: []target{
+		{scheme: "dns", address: "1.1.1.1:53", label: "CloudFlare"},
+		{scheme: "dns", address: "8.8.8.8:53", label: "Google"},
+		{scheme: "dns", address: "9.9.9.9:53", label: "Quad9"},
+		{scheme: "dns", address: "208.67.222.222:53", label: "OpenDNS"},
+		{scheme: "dns", address: "94.140.14.14:53", label: "AdGuard"},
+	}},
+	"cdns": {targets: []target{
+		{scheme: "tls", address: "www.cloudflare.com:443", label: "CloudFlare"},
+		{scheme: "tls", address: "www.akamai.com:443", label: "Akamai"},
+		{scheme: "tls", address: "www.fastly.com:443", label: "Fastly"},
+		{scheme: "tls", address: "aws.amazon.com:443", label: "CloudFront"},
+	}},
+	// game servers run mostly in the regions of the cloud providers, whose
+	// endpoints answer TCP when ICMP is filtered
+	"eu-gaming": {interval: 200 * time.Millisecond, jitterWeight: 3, targets: []target{
+		{scheme: "tcp", address: "dynamodb.eu-west-1.amazonaws.com:443", label: "Ireland"},
+		{scheme: "tcp", address: "dynamodb.eu-west-2.amazonaws.com:443", label: "London"},
+		{scheme: "tcp", address: "dynamodb.eu-central-1.amazonaws.com:443", label: "Frankfurt"},
+		{scheme: "tcp", address: "dynamodb.eu-north-1.amazonaws.com:443", label: "Stockholm"},
+	}},
+	"us-gaming": {interval: 200 * time.Millisecond, jitterWeight: 3, targets: []target{
+		{scheme: "tcp", address: "dynamodb.us-east-1.amazonaws.com:443", label: "Virginia"},
+		{scheme: "tcp", address: "dynamodb.us-east-2.amazonaws.com:443", label: "Ohio"},
+		{scheme: "tcp", address: "dynamodb.us-west-1.amazonaws.com:443", label: "California"},
+		{scheme: "tcp", address: "dynamodb.us-west-2.amazonaws.com:443", label: "Oregon"},
+	}},
+}
+
+// activePreset is the preset given by -preset, if any.
+var activePreset *preset
+
+// applyPreset checks -preset and sets the probe interval it calls for.
+func applyPreset() error {
+	if *presetName == "" {
+		return nil
+	}
+	p, ok := presets[*presetName]
+	if !ok {
+		return fmt.Errorf("unknown -preset %q", *presetName)
+	}
+	activePreset = &p
+	if p.interval > 0 {
+		probeInterval = p.interval
+	}
+	return nil
+}
+
+// qualityWindow is how many probe intervals back the MOS shown looks.
+const qualityWindow = 60
+
+// jitter returns the mean difference between consecutive RTTs, in ms.
+func jitter(rtts []float64) float64 {
+	if len(rtts) < 2 {
+		return 0
+	}
+	var sum float64
+	for i := 1; i < len(rtts); i++ {
+		sum += math.Abs(rtts[i] - rtts[i-1])
+	}
+	return sum / float64(len(rtts)-1)
+}
+
+// mosPreset is the preset MOS estimates assume: the one given by -preset,
+// or a call otherwise.
+func mosPreset() preset {
+	if activePreset != nil && activePreset.jitterWeight > 0 {
+		return *activePreset
+	}
+	return presets["voip"]
+}
+
+// mos estimates the mean opinion score, from 1 (unusable) to 4.5 (perfect),
+// of the replies of s since the given time with the ITU-T G.107 E-model,
+// weighting jitter as p says. It reports false when there is nothing to
+// score, or the values of s are not RTTs.
+func (s *series) mos(p preset, since time.Time) (float64, bool) {
+	if s.target.unit() != "" {
+		return 0, false
+	}
+	first := sort.Search(len(s.history), func(i int) bool { return !s.history[i].time.Before(since) })
+	rtts := make([]float64, 0, len(s.history)-first)
+	var sum float64
+	for _, pt := range s.history[first:] {
+		rtts = append(rtts, pt.rtt)
+		sum += pt.rtt
+	}
+	var lost int
+	for _, pt := range s.lost[sort.Search(len(s.lost), func(i int) bool { return !s.lost[i].time.Before(since) }):] {
+		lost += pt.n
+	}
+	sent := len(rtts) + lost
+	if sent == 0 {
+		return 0, false
+	}
+	if len(rtts) == 0 {
+		return 1, true
+	}
+	return scoreMOS(p, sum/float64(len(rtts)), jitter(rtts), 100*float64(lost)/float64(sent)), true
+}
+
+// sessionMOS is the MOS of the whole session of s, from its stats, as the
+// history of long sessions is downsampled to a point per minute.
+func (s *series) sessionMOS(p preset) (float64, bool) {
+	st := s.stats
+	switch {
+	case st.sent == 0 || s.target.unit() != "":
+		return 0, false
+	case st.received() == 0:
+		return 1, true
+	default:
+		return scoreMOS(p, ms(st.avg()), st.jitter(), st.loss()), true
+	}
+}
+
+// scoreMOS estimates the MOS of an average RTT and jitter in ms and a loss
+// percentage.
+func scoreMOS(p preset, rtt, jitter, loss float64) float64 {
+	// the mouth to ear delay: half the RTT, plus the jitter buffer and
+	// whatever the activity adds
+	delay := rtt/2 + p.jitterWeight*jitter + ms(p.delay)
+	return rFactorToMOS(eModel(delay, loss))
+}
+
+// recentMOS is the MOS of the last qualityWindow probe intervals of s.
+func (s *series) recentMOS(now time.Time) (float64, bool) {
+	return s.mos(mosPreset(), now.Add(-qualityWindow*probeInterval))
+}
+
+// eModel returns the transmission rating factor R of a call with the given
+// one way delay in ms and random packet loss percentage, assuming the
+// default G.107 values and G.711 with packet loss concealment.
+func eModel(delay, loss float64) float64 {
+	const (
+		r0MinusIs = 93.2 // the basic signal to noise ratio, less impairments
+		bpl       = 25.1 // the robustness of G.711 with PLC against loss
+	)
+	// delay impairment, which grows faster past 177.3 ms
+	id := 0.024 * delay
+	if delay > 177.3 {
+		id += 0.11 * (delay - 177.3)
+	}
+	// effective equipment impairment, G.711 having none without loss
+	ie := 95 * loss / (loss + bpl)
+	return r0MinusIs - id - ie
+}
+
+// rFactorToMOS converts an E-model rating to a mean opinion score.
+func rFactorToMOS(r float64) float64 {
+	switch {
+	case r <= 0:
+		return 1
+	case r >= 100:
+		return 4.5
+	}
+	return 1 + 0.035*r + 7e-6*r*(r-60)*(100-r)
+}
+
+// qualityLevels name MOS ranges, from the best one down.
+var qualityLevels = []struct {
+	above float64
+	name  string
+	scale int // color in the scale of the theme
+}{
+	{4.3, "excellent", 0},
+	{4.0, "good", 0},
+	{3.6, "fair", 2},
+	{3.1, "poor", 4},
+	{0, "bad", 4},
+}
+
+// headlineQuality describes the worst MOS of all targets, as the activity
+// goes through all of them, e.g. the gateway and the game server.
+func headlineQuality(all []*series) string {
+	if activePreset == nil || activePreset.jitterWeight == 0 {
+		return ""
+	}
+	worst, scored := 5.0, false
+	now := time.Now()
+	for _, s := range all {
+		if q, ok := s.recentMOS(now); ok {
+			worst = math.Min(worst, q)
+			scored = true
+		}
+	}
+	if !scored {
+		return fmt.Sprintf("%s quality: measuring", *presetName)
+	}
+	level := qualityLevels[len(qualityLevels)-1]
+	for _, l := range qualityLevels {
+		if worst >= l.above {
+			level = l
+			break
+		}
+	}
+	return color.New(activeTheme.scale[level.scale]...).Add(color.Bold).Sprintf("%s quality: MOS %.1f, %s", *presetName, worst, level.name)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net"
+	"time"
+)
+
+// quicProbeVersion is a reserved QUIC version (RFC 9000, section 15) that no
+// server implements, so servers always answer it with Version Negotiation.
+var quicProbeVersion = []byte{0x1a, 0x2a, 0x3a, 0x4a}
+
+func init() {
+	registerSource("quic", "QUIC", "443", quicSource)
+}
+
+// quicSource measures the time it takes a QUIC server to answer our first
+// flight. Instead of a full handshake it sends an Initial-sized packet with a
+// reserved version, which every QUIC server must reply to with a Version
+// Negotiation packet: that is enough to tell whether QUIC traffic gets through
+// and how long the round trip takes, without implementing TLS over QUIC.
+func quicSource(ctx context.Context, t target, out chan<- sample) error {
+	conn, err := t.dialer("udp", 0).Dial("udp", t.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return probeEvery(ctx, t, out, func(int, map[string]string) (time.Duration, error) {
+		return quicVersionNegotiation(conn, probeTimeout)
+	})
+}
+
+func quicVersionNegotiation(conn net.Conn, timeout time.Duration) (time.Duration, error) {
+	dcid := make([]byte, 8)
+	scid := make([]byte, 8)
+	if _, err := rand.Read(dcid); err != nil {
+		return 0, err
+	}
+	if _, err := rand.Read(scid); err != nil {
+		return 0, err
+	}
+
+	// long header Initial packet, padded to the 1200 bytes minimum datagram
+	// size servers require before answering
+	pkt := make([]byte, 0, 1200)
+	pkt = append(pkt, 0xc0)
+	pkt = append(pkt, quicProbeVersion...)
+	pkt = append(pkt, byte(len(dcid)))
+	pkt = append(pkt, dcid...)
+	pkt = append(pkt, byte(len(scid)))
+	pkt = append(pkt, scid...)
+	pkt = pkt[:cap(pkt)]
+
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write(pkt); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		if isVersionNegotiation(buf[:n], scid, dcid) {
+			return time.Since(start), nil
+		}
+	}
+}
+
+// isVersionNegotiation reports whether pkt is a Version Negotiation packet
+// answering the one we sent with the given connection IDs, which the server
+// echoes back swapped.
+func isVersionNegotiation(pkt, dcid, scid []byte) bool {
+	if len(pkt) < 7 || pkt[0]&0x80 == 0 || !bytes.Equal(pkt[1:5], []byte{0, 0, 0, 0}) {
+		return false
+	}
+	pkt = pkt[5:]
+	for _, want := range [][]byte{dcid, scid} {
+		if len(pkt) < 1 || int(pkt[0]) != len(want) || len(pkt) < 1+len(want) {
+			return false
+		}
+		if !bytes.Equal(pkt[1:1+len(want)], want) {
+			return false
+		}
+		pkt = pkt[1+len(want):]
+	}
+	return true
+}
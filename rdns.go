@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"strings"
+	"time"
+)
+
+var rdnsLookup = flag.Bool("rdns", true,
+	"show the host names of the addresses of targets and hops, from their PTR records")
+
+// rdnsTTL is how long host names are cached, as the resolver of the
+// standard library does not tell the TTL of records.
+const rdnsTTL = 10 * time.Minute
+
+var hostnames = newLookupCache(rdnsTTL, func(ctx context.Context, addr string) (string, error) {
+	names, err := net.DefaultResolver.LookupAddr(ctx, addr)
+	if err != nil || len(names) == 0 {
+		return "", err
+	}
+	return strings.TrimSuffix(names[0], "."), nil
+})
+
+// hostnameOf returns the host name of the address addr, if known.
+func hostnameOf(addr string) (string, bool) {
+	if !*rdnsLookup || net.ParseIP(addr) == nil {
+		return "", false
+	}
+	return hostnames.get(addr)
+}
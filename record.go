@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+var recordPath = flag.String("record", "",
+	"record every sample of the session to this file, e.g. session.nck, for -baseline and netcheck compare")
+
+// recordSink writes every sample to a recording, a file with a sample per
+// line as JSON, as published to MQTT.
+type recordSink struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+func newRecordSink(path string) (*recordSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &recordSink{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (k *recordSink) write(s sample) {
+	b, _ := json.Marshal(newSampleJSON(s))
+	k.w.Write(append(b, '\n'))
+}
+
+func (k *recordSink) close() error {
+	if err := k.w.Flush(); err != nil {
+		k.f.Close()
+		return err
+	}
+	return k.f.Close()
+}
+
+// recording is a recorded session, with the samples of every target in the
+// order they were recorded.
+type recording struct {
+	targets []string
+	samples map[string][]sampleJSON
+}
+
+// readRecording reads a recording written by -record.
+func readRecording(path string) (*recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := &recording{samples: map[string][]sampleJSON{}}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var s sampleJSON
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if _, ok := r.samples[s.Target]; !ok {
+			r.targets = append(r.targets, s.Target)
+		}
+		r.samples[s.Target] = append(r.samples[s.Target], s)
+	}
+	return r, scanner.Err()
+}
+
+// rtts returns the RTTs in ms of the replies to the probes of target.
+func (r *recording) rtts(target string) []float64 {
+	var rtts []float64
+	for _, s := range r.samples[target] {
+		if !s.Lost {
+			rtts = append(rtts, s.RTT)
+		}
+	}
+	return rtts
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Reflector requests are signed echo payloads, padded with zeros to the
+// length of the replies, so that the reflector never sends more than it
+// receives. Replies carry the request and the times the reflector received
+// and answered it, signed like echo payloads. Layout:
+//
+//	magic "NCR1" | seq uint32 | send time int64 | receive time int64 |
+//	reply time int64 | requests received uint32 | HMAC-SHA256
+//
+// Times are unix ns, and requests received counts the ones of the client
+// so far, telling upstream losses from downstream ones.
+const (
+	reflectMagic      = "NCR1"
+	reflectHeaderLen  = len(reflectMagic) + 4 + 3*8 + 4
+	reflectPayloadLen = reflectHeaderLen + sha256.Size
+)
+
+const (
+	// reflectMaxClients bounds the request counts the reflector keeps for
+	// UDP clients, which never say they are gone.
+	reflectMaxClients = 10000
+	// reflectMaxSkew is how far from the clock of the reflector the send
+	// time of requests may be, so that recorded requests cannot be replayed
+	// later.
+	reflectMaxSkew = 10 * time.Second
+	// reflectIdleTimeout closes TCP connections without requests.
+	reflectIdleTimeout = time.Minute
+)
+
+func init() {
+	registerSource("reflect", "REFLECT", "9999", reflectSource)
+	registerSource("reflect+tcp", "REFLECT/TCP", "9999", reflectSource)
+}
+
+// reflection is what a reflector reply tells about a request.
+type reflection struct {
+	rtt      time.Duration
+	up, down time.Duration // one way delays, skewed by the clock offset
+	received uint32        // requests of the client the reflector got
+}
+
+// reflectSource sends signed requests to a "netcheck reflect" over UDP, or
+// TCP for "reflect+tcp" targets. Besides the RTT, the times in the replies
+// give the one way delays in the up_ms and down_ms metadata, as accurate as
+// the clocks of both machines are in sync, and the request counts give the
+// requests lost upstream and downstream, in up_lost and down_lost.
+func reflectSource(ctx context.Context, t target, out chan<- sample) error {
+	network := "udp"
+	if t.scheme == "reflect+tcp" {
+		network = "tcp"
+	}
+
+	var conn net.Conn
+	var sent, replies uint32 // on conn, as the reflector counts per client
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	return probeEvery(ctx, t, out, func(seq int, meta map[string]string) (time.Duration, error) {
+		if conn == nil {
+			var d contextDialer = t.dialer(network, probeTimeout)
+			if network == "tcp" {
+				var err error
+				if d, err = t.streamDialer(probeTimeout); err != nil {
+					return 0, err
+				}
+			}
+			dialCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+			defer cancel()
+			var err error
+			if conn, err = d.DialContext(dialCtx, network, t.address); err != nil {
+				return 0, err
+			}
+			sent, replies = 0, 0
+		}
+		sent++
+		r, err := askReflector(conn, network == "tcp", uint32(seq), probeTimeout)
+		if err != nil {
+			// a stream may be left in the middle of a payload, start over
+			if network == "tcp" {
+				conn.Close()
+				conn = nil
+			}
+			return 0, err
+		}
+		replies++
+		meta["up_ms"] = strconv.FormatFloat(ms(r.up), 'f', 1, 64)
+		meta["down_ms"] = strconv.FormatFloat(ms(r.down), 'f', 1, 64)
+		// a reflector that restarted counts from zero again
+		if r.received <= sent {
+			meta["up_lost"] = strconv.Itoa(int(sent - r.received))
+			meta["down_lost"] = strconv.Itoa(int(r.received - replies))
+		}
+		return r.rtt, nil
+	})
+}
+
+// askReflector sends a request to a reflector and waits for its reply.
+func askReflector(conn net.Conn, stream bool, seq uint32, timeout time.Duration) (reflection, error) {
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return reflection{}, err
+	}
+	request := make([]byte, reflectPayloadLen)
+	copy(request, signPayload(probeKey, seq, start))
+	if _, err := conn.Write(request); err != nil {
+		return reflection{}, err
+	}
+
+	buf := make([]byte, reflectPayloadLen)
+	for {
+		var n int
+		var err error
+		if stream {
+			n, err = io.ReadFull(conn, buf)
+		} else {
+			n, err = conn.Read(buf)
+		}
+		if err != nil {
+			return reflection{}, err
+		}
+		end := time.Now()
+
+		got, received, replied, count, err := parseReflection(probeKey, buf[:n])
+		if err != nil && stream {
+			return reflection{}, err
+		}
+		// datagrams may be forged or late replies to earlier probes
+		if err == nil && got == seq {
+			return reflection{
+				rtt:      end.Sub(start),
+				up:       received.Sub(start),
+				down:     end.Sub(replied),
+				received: count,
+			}, nil
+		}
+	}
+}
+
+// signReflection builds the reply to a request received at the given time.
+func signReflection(key, request []byte, received time.Time, count uint32) []byte {
+	b := make([]byte, reflectHeaderLen, reflectPayloadLen)
+	copy(b, reflectMagic)
+	copy(b[4:16], request[4:authHeaderLen]) // seq and send time
+	binary.BigEndian.PutUint64(b[16:], uint64(received.UnixNano()))
+	binary.BigEndian.PutUint32(b[32:], count)
+	binary.BigEndian.PutUint64(b[24:], uint64(time.Now().UnixNano()))
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return mac.Sum(b)
+}
+
+// parseReflection checks the signature of a reply built by signReflection
+// and returns its sequence number, receive and reply times, and count.
+func parseReflection(key, b []byte) (seq uint32, received, replied time.Time, count uint32, err error) {
+	if len(b) != reflectPayloadLen || string(b[:len(reflectMagic)]) != reflectMagic {
+		return 0, time.Time{}, time.Time{}, 0, errBadSignature
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b[:reflectHeaderLen])
+	if !hmac.Equal(mac.Sum(nil), b[reflectHeaderLen:]) {
+		return 0, time.Time{}, time.Time{}, 0, errBadSignature
+	}
+	seq = binary.BigEndian.Uint32(b[4:])
+	received = time.Unix(0, int64(binary.BigEndian.Uint64(b[16:])))
+	replied = time.Unix(0, int64(binary.BigEndian.Uint64(b[24:])))
+	count = binary.BigEndian.Uint32(b[32:])
+	return seq, received, replied, count, nil
+}
+
+// checkRequest checks that b is a request signed with key, padded to the
+// length of the reply, and sent within reflectMaxSkew of now.
+func checkRequest(key, b []byte, now time.Time) error {
+	if len(b) != reflectPayloadLen {
+		return errBadSignature
+	}
+	_, sent, err := verifyPayload(key, b[:authPayloadLen])
+	if err != nil {
+		return err
+	}
+	if now.Sub(sent).Abs() > reflectMaxSkew {
+		return fmt.Errorf("request sent at %s, are the clocks in sync?", sent.Format(time.TimeOnly))
+	}
+	return nil
+}
+
+// replayFilter remembers the signatures of the requests answered within
+// reflectMaxSkew, so that each one is answered once, also when replayed
+// from another address.
+type replayFilter map[string]time.Time
+
+// seen reports whether the request b was answered already, and remembers it
+// otherwise.
+func (f replayFilter) seen(b []byte, now time.Time) bool {
+	sig := string(b[authHeaderLen:authPayloadLen])
+	if _, ok := f[sig]; ok {
+		return true
+	}
+	if len(f) >= reflectMaxClients {
+		for s, t := range f {
+			if now.Sub(t) > 2*reflectMaxSkew {
+				delete(f, s)
+			}
+		}
+	}
+	f[sig] = now
+	return false
+}
+
+// runReflect implements "netcheck reflect [-listen address]": it answers
+// the requests of reflect:// and reflect+tcp:// targets signed with the same
+// key, from NETCHECK_KEY or -key-file, over UDP and TCP.
+func runReflect(args []string) int {
+	fs := flag.NewFlagSet("reflect", flag.ContinueOnError)
+	listen := fs.String("listen", ":9999", "address to answer requests on, over UDP and TCP")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	pc, err := net.ListenPacket("udp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Reflecting on %s over UDP and TCP\n", *listen)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			go reflectStream(conn)
+		}
+	}()
+
+	counts := map[string]uint32{}
+	answered := replayFilter{}
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		received := time.Now()
+		if checkRequest(probeKey, buf[:n], received) != nil || answered.seen(buf[:n], received) {
+			continue
+		}
+		client := addr.String()
+		if _, ok := counts[client]; !ok {
+			if len(counts) >= reflectMaxClients {
+				clear(counts)
+			}
+			fmt.Printf("%s %s over UDP\n", received.Format("15:04:05"), client)
+		}
+		counts[client]++
+		pc.WriteTo(signReflection(probeKey, buf[:n], received, counts[client]), addr)
+	}
+}
+
+// reflectStream answers the requests of a TCP client until it disconnects,
+// sends something else or stays idle for reflectIdleTimeout.
+func reflectStream(conn net.Conn) {
+	defer resetOnPanic()
+	defer conn.Close()
+	fmt.Printf("%s %s over TCP\n", time.Now().Format("15:04:05"), conn.RemoteAddr())
+	r := bufio.NewReader(conn)
+	buf := make([]byte, reflectPayloadLen)
+	var count uint32
+	for {
+		conn.SetReadDeadline(time.Now().Add(reflectIdleTimeout))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return
+		}
+		received := time.Now()
+		if err := checkRequest(probeKey, buf, received); err != nil {
+			return
+		}
+		count++
+		if _, err := conn.Write(signReflection(probeKey, buf, received, count)); err != nil {
+			return
+		}
+	}
+}
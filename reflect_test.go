@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// reflectTestRequest returns a request as reflect:// targets send it.
+func reflectTestRequest(key []byte, seq uint32, sent time.Time) []byte {
+	b := make([]byte, reflectPayloadLen)
+	copy(b, signPayload(key, seq, sent))
+	return b
+}
+
+func TestCheckRequest(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1_800_000_000, 0)
+	tests := []struct {
+		name    string
+		request []byte
+		wantErr bool
+	}{
+		{"valid", reflectTestRequest(key, 1, now), false},
+		{"sent a bit earlier", reflectTestRequest(key, 1, now.Add(-reflectMaxSkew)), false},
+		{"sent a bit later", reflectTestRequest(key, 1, now.Add(reflectMaxSkew)), false},
+		{"sent too early", reflectTestRequest(key, 1, now.Add(-reflectMaxSkew-time.Second)), true},
+		{"sent too late", reflectTestRequest(key, 1, now.Add(reflectMaxSkew+time.Second)), true},
+		{"other key", reflectTestRequest([]byte("other"), 1, now), true},
+		{"not padded", signPayload(key, 1, now), true},
+		{"too long", append(reflectTestRequest(key, 1, now), 0), true},
+		{"empty", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkRequest(key, tt.request, now); (err != nil) != tt.wantErr {
+				t.Errorf("checkRequest() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReplayFilter(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1_800_000_000, 0)
+	f := replayFilter{}
+	first := reflectTestRequest(key, 1, now)
+	if f.seen(first, now) {
+		t.Error("seen() = true for a new request")
+	}
+	if !f.seen(first, now.Add(time.Second)) {
+		t.Error("seen() = false for a replayed request")
+	}
+	if f.seen(reflectTestRequest(key, 2, now), now) {
+		t.Error("seen() = true for the next request")
+	}
+	if f.seen(reflectTestRequest(key, 1, now.Add(time.Millisecond)), now) {
+		t.Error("seen() = true for a request sent at another time")
+	}
+}
+
+func TestReplayFilterForgets(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1_800_000_000, 0)
+	f := replayFilter{}
+	for i := range reflectMaxClients {
+		f.seen(reflectTestRequest(key, uint32(i), now), now)
+	}
+	later := now.Add(2*reflectMaxSkew + time.Second)
+	f.seen(reflectTestRequest(key, reflectMaxClients, later), later)
+	if len(f) != 1 {
+		t.Errorf("%d requests remembered, want only the latest one", len(f))
+	}
+}
+
+func TestReflection(t *testing.T) {
+	key := []byte("secret")
+	sent := time.Now().Add(-10 * time.Millisecond)
+	received := sent.Add(5 * time.Millisecond)
+	reply := signReflection(key, reflectTestRequest(key, 7, sent), received, 3)
+	if len(reply) != reflectPayloadLen {
+		t.Fatalf("reply of %d bytes, want %d as the request", len(reply), reflectPayloadLen)
+	}
+
+	seq, gotReceived, replied, count, err := parseReflection(key, reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 7 || gotReceived.UnixNano() != received.UnixNano() || count != 3 {
+		t.Errorf("parseReflection() = seq %d, received %v, count %d, want 7, %v, 3", seq, gotReceived, count, received)
+	}
+	if replied.Before(received) {
+		t.Errorf("replied at %v, before receiving at %v", replied, received)
+	}
+
+	tampered := append([]byte(nil), reply...)
+	tampered[reflectHeaderLen-1]++ // the count
+	tests := []struct {
+		name  string
+		key   []byte
+		reply []byte
+	}{
+		{"other key", []byte("other"), reply},
+		{"tampered", key, tampered},
+		{"truncated", key, reply[:reflectPayloadLen-1]},
+		{"echo payload", key, signPayload(key, 7, sent)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, _, err := parseReflection(tt.key, tt.reply); !errors.Is(err, errBadSignature) {
+				t.Errorf("parseReflection() = %v, want %v", err, errBadSignature)
+			}
+		})
+	}
+}
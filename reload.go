@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"time"
+)
+
+// configCheckInterval is how often the config is checked for changes.
+const configCheckInterval = 2 * time.Second
+
+// watchConfig sends to changed whenever the config file is written, until
+// ctx is done.
+func watchConfig(ctx context.Context, changed chan<- struct{}) {
+	defer resetOnPanic()
+	path := *configPath
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	modTime := func() time.Time {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+		return fi.ModTime()
+	}
+	last := modTime()
+
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if m := modTime(); !m.Equal(last) {
+				last = m
+				select {
+				case changed <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// reloadConfig applies the config again after it changed: its flags, e.g.
+// thresholds, the theme, the SLOs and, when they were not given as
+// arguments, the targets, expanded as on start, starting the new ones and
+// stopping the ones not in it anymore. A bad config is logged and the
+// current one kept.
+func (sc *screen) reloadConfig(ownTargets bool) {
+	now := time.Now()
+	old := settings
+	if err := loadConfig(true); err != nil {
+		events.add(now, "config not reloaded: "+err.Error())
+		return
+	}
+	n := maxLen
+	applyFlags()
+	if maxLen != n {
+		for _, s := range sc.all {
+			s.resized()
+		}
+	}
+	if err := setupTheme(); err != nil {
+		events.add(now, "config: "+err.Error())
+	}
+	sc.clear = true
+
+	if !slices.Equal(old.SLOs, settings.SLOs) {
+		for _, s := range sc.all {
+			s.slos = newSLOTrackers(s.target)
+		}
+	}
+
+	if ownTargets && len(settings.Targets) > 0 && !slices.Equal(old.Targets, settings.Targets) {
+		var wanted []target
+		for _, arg := range settings.Targets {
+			targets, err := parseTargets(arg)
+			if err != nil {
+				events.add(now, "config: "+err.Error())
+				return
+			}
+			wanted = append(wanted, targets...)
+		}
+		if err := checkConfigExec(wanted); err != nil {
+			events.add(now, "config: "+err.Error())
+			return
+		}
+		wanted, sg, err := expandTargets(wanted)
+		if err != nil {
+			events.add(now, "config: "+err.Error())
+			return
+		}
+		kept := sc.all[:0]
+		for _, s := range sc.all {
+			if s.target.scheme == "delta" || slices.Contains(wanted, s.target) {
+				kept = append(kept, s)
+				continue
+			}
+			s.stop()
+			removeFromSinks(s.target)
+			events.add(now, "removed "+s.target.String())
+		}
+		sc.all = kept
+		for _, t := range wanted {
+			if !slices.ContainsFunc(sc.all, func(s *series) bool { return s.target == t }) {
+				sc.all = append(sc.all, sc.start(t))
+				events.add(now, "added "+t.String())
+			}
+		}
+		sc.selected = min(sc.selected, len(sc.all)-1)
+		if sg != nil {
+			sg.find(sc.all)
+		}
+		sc.segments = sg
+	}
+	events.add(now, "reloaded the config")
+}
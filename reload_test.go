@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReloadConfigTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	savedPath, savedSettings, savedCmdline := *configPath, settings, cmdlineFlags
+	t.Cleanup(func() { *configPath, settings, cmdlineFlags = savedPath, savedSettings, savedCmdline })
+	*configPath, settings, cmdlineFlags = path, config{Targets: []string{"127.0.0.1"}}, map[string]bool{}
+
+	start := func(t target) *series {
+		s := newSeries(t)
+		s.stop = func() {}
+		return s
+	}
+	targets, err := parseTargets("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := start(targets[0])
+	delta := start(target{scheme: "delta", address: "127.0.0.1 - 127.0.0.2"})
+	sc := &screen{all: []*series{first, delta}, start: start, zoom: 1}
+
+	// every step reloads the config after the previous one, and checks the
+	// targets probed then
+	steps := []struct {
+		name       string
+		targets    []string
+		ownTargets bool
+		want       []string
+	}{
+		{"added", []string{"127.0.0.1", "tcp://127.0.0.2:80"}, true,
+			[]string{"127.0.0.1", "127.0.0.1 - 127.0.0.2", "tcp://127.0.0.2:80"}},
+		{"removed", []string{"tcp://127.0.0.2:80"}, true,
+			[]string{"127.0.0.1 - 127.0.0.2", "tcp://127.0.0.2:80"}},
+		{"given as arguments", []string{"127.0.0.3"}, false,
+			[]string{"127.0.0.1 - 127.0.0.2", "tcp://127.0.0.2:80"}},
+		{"bad target", []string{"127.0.0.3", "nosuchprobe://x"}, true,
+			[]string{"127.0.0.1 - 127.0.0.2", "tcp://127.0.0.2:80"}},
+		{"replaced", []string{"127.0.0.3"}, true,
+			[]string{"127.0.0.1 - 127.0.0.2", "127.0.0.3"}},
+	}
+	for _, step := range steps {
+		b, _ := json.Marshal(config{Targets: step.targets})
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		sc.reloadConfig(step.ownTargets)
+		var got []string
+		for _, s := range sc.all {
+			got = append(got, s.target.String())
+		}
+		if !slices.Equal(got, step.want) {
+			t.Errorf("%s: probing %q, want %q", step.name, got, step.want)
+		}
+		if slices.Contains(step.want, "127.0.0.1") && !slices.Contains(sc.all, first) {
+			t.Errorf("%s: 127.0.0.1 was started again", step.name)
+		}
+	}
+	if !slices.Contains(sc.all, delta) {
+		t.Error("the -delta series was replaced")
+	}
+
+	// the targets the flags add are kept as on start
+	iface, ok := routeInterface("127.0.0.3")
+	if !ok {
+		t.Skip("no route to 127.0.0.3")
+	}
+	defer func(v bool) { *nicStats = v }(*nicStats)
+	*nicStats = true
+	b, _ := json.Marshal(config{Targets: []string{"127.0.0.3", "tcp://127.0.0.3:80"}})
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sc.reloadConfig(true)
+	var got []string
+	for _, s := range sc.all {
+		got = append(got, s.target.String())
+	}
+	if want := []string{"127.0.0.1 - 127.0.0.2", "127.0.0.3", "tcp://127.0.0.3:80", "nic://" + iface}; !slices.Equal(got, want) {
+		t.Errorf("with -nic: probing %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"time"
+)
+
+var exportPath = flag.String("export", "",
+	"on exit, write a standalone HTML report with the graphs of the whole session to this file")
+
+// reportColors are the colors of the series in the report, as in the terminal.
+var reportColors = []string{"#0aa", "#a0a", "#aa0", "#0a0", "#00c", "#c00"}
+
+type reportData struct {
+	Generated time.Time
+	Targets   []reportTarget
+	Events    []reportEvent
+	Markers   []reportMarker
+	Summary   string
+}
+
+type reportTarget struct {
+	Name   string       `json:"name"`
+	Color  string       `json:"color"`
+	Points [][2]float64 `json:"points"` // unix ms, RTT in ms
+	Lost   []int64      `json:"lost"`   // unix ms
+}
+
+type reportEvent struct {
+	Time string
+	Text string
+}
+
+type reportMarker struct {
+	Time  int64  `json:"time"` // unix ms
+	Label string `json:"label"`
+}
+
+// writeReport writes a single file HTML report of the session, with the data
+// embedded and no external assets, e.g. to attach to an ISP support ticket.
+func writeReport(path string, all []*series) error {
+	data := reportData{Generated: time.Now()}
+	for i, s := range all {
+		t := reportTarget{
+			Name:   fmt.Sprintf("%s %s", probeNames[s.target.scheme], s.target),
+			Color:  reportColors[i%len(reportColors)],
+			Points: make([][2]float64, len(s.history)),
+		}
+		for j, p := range s.history {
+			t.Points[j] = [2]float64{float64(p.time.UnixMilli()), p.rtt}
+		}
+		for _, lost := range s.lost {
+			t.Lost = append(t.Lost, lost.time.UnixMilli())
+		}
+		data.Targets = append(data.Targets, t)
+	}
+	for _, e := range events.since(0) {
+		text := e.text
+		if e.marker {
+			text = "▲ " + text
+			data.Markers = append(data.Markers, reportMarker{e.time.UnixMilli(), e.text})
+		}
+		data.Events = append(data.Events, reportEvent{Time: e.time.Format("2006-01-02 15:04:05"), Text: text})
+	}
+	var summary bytes.Buffer
+	printSummary(&summary, all)
+	data.Summary = summary.String()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := reportTemplate.Execute(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>netcheck report {{.Generated.Format "2006-01-02 15:04"}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+canvas { width: 100%; height: 360px; border: 1px solid #ccc; }
+#legend span { margin-right: 1.5em; cursor: pointer; user-select: none; }
+#legend span.off { opacity: 0.3; }
+#tip { position: absolute; background: #fff; border: 1px solid #888; padding: 2px 6px; font-size: 12px; pointer-events: none; display: none; }
+pre { background: #f4f4f4; padding: 1em; }
+</style>
+</head>
+<body>
+<h1>netcheck report</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}. Click a target to hide it, drag to zoom in, double click to zoom out. Lost probes are marked in red at the bottom, markers with dashed lines.</p>
+<div id="legend"></div>
+<canvas id="chart"></canvas>
+<div id="tip"></div>
+<h2>Summary</h2>
+<pre>{{.Summary}}</pre>
+<h2>Events</h2>
+<ul>{{range .Events}}<li>{{.Time}} {{.Text}}</li>{{else}}<li>None</li>{{end}}</ul>
+<script>
+const targets = {{.Targets}} || [];
+const markers = {{.Markers}} || [];
+const canvas = document.getElementById("chart"), ctx = canvas.getContext("2d");
+const tip = document.getElementById("tip");
+let hidden = new Set(), view = null, drag = null;
+
+const all = targets.flatMap(t => t.points.map(p => p[0]).concat(t.lost || []));
+const full = [Math.min(...all), Math.max(...all)];
+
+targets.forEach((t, i) => {
+  const span = document.createElement("span");
+  span.textContent = "■ " + t.name;
+  span.style.color = t.color;
+  span.onclick = () => { hidden.has(i) ? hidden.delete(i) : hidden.add(i); span.classList.toggle("off"); draw(); };
+  document.getElementById("legend").appendChild(span);
+});
+
+function scales() {
+  const [from, to] = view || full;
+  let max = 1;
+  targets.forEach((t, i) => { if (!hidden.has(i)) t.points.forEach(p => { if (p[0] >= from && p[0] <= to) max = Math.max(max, p[1]); }); });
+  const w = canvas.width, h = canvas.height, pad = 40;
+  return {from, to, max,
+    x: v => pad + (v - from) / Math.max(to - from, 1) * (w - pad - 10),
+    y: v => h - 20 - v / max * (h - 40),
+    t: px => from + (px - pad) / (w - pad - 10) * (to - from)};
+}
+
+function draw() {
+  canvas.width = canvas.clientWidth * devicePixelRatio;
+  canvas.height = canvas.clientHeight * devicePixelRatio;
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  if (!all.length) return;
+  const s = scales();
+  ctx.fillStyle = "#888";
+  ctx.font = 11 * devicePixelRatio + "px sans-serif";
+  for (let i = 0; i <= 4; i++) {
+    const v = s.max * i / 4;
+    ctx.fillText(v.toFixed(v < 10 ? 1 : 0), 2, s.y(v));
+  }
+  ctx.fillText(new Date(s.from).toLocaleTimeString(), s.x(s.from), canvas.height - 4);
+  ctx.fillText(new Date(s.to).toLocaleTimeString(), s.x(s.to) - 60, canvas.height - 4);
+  targets.forEach((t, i) => {
+    if (hidden.has(i)) return;
+    ctx.strokeStyle = t.color;
+    ctx.beginPath();
+    t.points.forEach((p, j) => j ? ctx.lineTo(s.x(p[0]), s.y(p[1])) : ctx.moveTo(s.x(p[0]), s.y(p[1])));
+    ctx.stroke();
+    ctx.fillStyle = "#c00";
+    (t.lost || []).forEach(l => { if (l >= s.from && l <= s.to) ctx.fillRect(s.x(l), canvas.height - 18, 2, 6); });
+  });
+  ctx.strokeStyle = ctx.fillStyle = "#555";
+  ctx.setLineDash([4, 4]);
+  markers.forEach(m => {
+    if (m.time < s.from || m.time > s.to) return;
+    ctx.beginPath();
+    ctx.moveTo(s.x(m.time), 10);
+    ctx.lineTo(s.x(m.time), canvas.height - 20);
+    ctx.stroke();
+    ctx.fillText(m.label, s.x(m.time) + 3, 20);
+  });
+  ctx.setLineDash([]);
+}
+
+function nearest(px) {
+  const s = scales(), at = s.t(px);
+  let best = null;
+  targets.forEach((t, i) => {
+    if (hidden.has(i)) return;
+    t.points.forEach(p => { if (!best || Math.abs(p[0] - at) < Math.abs(best.p[0] - at)) best = {t, p}; });
+  });
+  return best;
+}
+
+canvas.onmousemove = e => {
+  const px = e.offsetX * devicePixelRatio, b = nearest(px);
+  if (!b) return;
+  tip.style.display = "block";
+  tip.style.left = e.pageX + 12 + "px";
+  tip.style.top = e.pageY + 12 + "px";
+  tip.textContent = b.t.name + " " + new Date(b.p[0]).toLocaleTimeString() + " " + b.p[1].toFixed(1) + " ms";
+};
+canvas.onmouseleave = () => tip.style.display = "none";
+canvas.onmousedown = e => drag = scales().t(e.offsetX * devicePixelRatio);
+canvas.onmouseup = e => {
+  const to = scales().t(e.offsetX * devicePixelRatio);
+  if (drag !== null && Math.abs(to - drag) > 1000) view = [Math.min(drag, to), Math.max(drag, to)];
+  drag = null;
+  draw();
+};
+canvas.ondblclick = () => { view = null; draw(); };
+window.onresize = draw;
+draw();
+</script>
+</body>
+</html>
+`))
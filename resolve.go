@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var followDNS = flag.Bool("follow-dns", true,
+	"resolve host name targets again when their DNS records expire, and ping the new address when it changes")
+
+// Bounds of how often host names are resolved again, whatever the TTL of
+// their records, e.g. 0 for some CDNs.
+const (
+	resolveMinTTL     = 30 * time.Second
+	resolveMaxTTL     = time.Hour
+	resolveDefaultTTL = 5 * time.Minute // when the TTL is unknown
+)
+
+// resolution follows the addresses a host name target resolves to, which
+// change over time for CDN and anycast hosts.
+type resolution struct {
+	target  target
+	host    string
+	addrs   []string
+	expires time.Time
+}
+
+// resolvedAddrs are the latest addresses of every host name followed, for
+// ICMP to ping the current one.
+var resolvedAddrs sync.Map
+
+// newResolution returns the resolution of the host of t, nil if it is an
+// address.
+func newResolution(t target) *resolution {
+	if pt, ok := probeTypes[t.scheme]; !ok || pt.opaque {
+		return nil
+	}
+	host := hostOf(t)
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	return &resolution{target: t, host: host}
+}
+
+// refresh resolves the host again when its records expired, logging an
+// event when its addresses changed.
+func (r *resolution) refresh(now time.Time) {
+	if r == nil || now.Before(r.expires) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, r.host)
+	if err != nil {
+		r.expires = now.Add(resolveMinTTL)
+		return
+	}
+	slices.Sort(addrs)
+	r.expires = now.Add(min(max(hostTTL(r.host), resolveMinTTL), resolveMaxTTL))
+	if r.addrs != nil && !slices.Equal(addrs, r.addrs) {
+		events.add(now, fmt.Sprintf("%s now resolves to %s, was %s", r.target, strings.Join(addrs, ", "), strings.Join(r.addrs, ", ")))
+	}
+	r.addrs = addrs
+	resolvedAddrs.Store(r.host, addrs)
+}
+
+// hostTTL returns the TTL of the A records of host, asking the first DNS
+// server of the system, as the resolver of the standard library does not
+// tell it.
+func hostTTL(host string) time.Duration {
+	servers := readDNSConfig().servers
+	if len(servers) == 0 {
+		return resolveDefaultTTL
+	}
+	_, resp, err := dnsQuery(&net.Dialer{Timeout: probeTimeout}, net.JoinHostPort(servers[0], "53"), host, dnsTypeA, dnsClassIN, probeTimeout)
+	if err != nil {
+		return resolveDefaultTTL
+	}
+	records, err := dnsAnswers(resp)
+	if err != nil {
+		return resolveDefaultTTL
+	}
+	ttl := time.Duration(-1)
+	for _, r := range records {
+		if r.qtype == dnsTypeA && (ttl < 0 || r.ttl < ttl) {
+			ttl = r.ttl
+		}
+	}
+	if ttl < 0 {
+		return resolveDefaultTTL
+	}
+	return ttl
+}
+
+// moved reports whether the host of t resolves to addresses that do not
+// include addr anymore.
+func moved(t target, addr string) bool {
+	addrs, ok := resolvedAddrs.Load(hostOf(t))
+	return ok && !slices.Contains(addrs.([]string), addr)
+}
@@ -0,0 +1,44 @@
+package main
+
+// ring keeps the latest values pushed to it, up to its capacity, dropping
+// the oldest one when full without allocating.
+type ring struct {
+	buf   []float64
+	start int // index in buf of the oldest value
+	n     int
+}
+
+func newRing(capacity int) ring {
+	return ring{buf: make([]float64, max(capacity, 1))}
+}
+
+// push adds v as the latest value.
+func (r *ring) push(v float64) {
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = v
+		r.n++
+		return
+	}
+	r.buf[r.start] = v
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+func (r *ring) len() int {
+	return r.n
+}
+
+// at returns the i-th value, the oldest being at 0.
+func (r *ring) at(i int) float64 {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+// window appends the values to dst, oldest first, for renderers wanting a
+// slice; reusing dst between frames avoids allocating.
+func (r *ring) window(dst []float64) []float64 {
+	end := r.start + r.n
+	if end <= len(r.buf) {
+		return append(dst, r.buf[r.start:end]...)
+	}
+	dst = append(dst, r.buf[r.start:]...)
+	return append(dst, r.buf[:end-len(r.buf)]...)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRingWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		pushed []float64
+		want   []float64
+	}{
+		{"empty", nil, nil},
+		{"partial", []float64{1, 2}, []float64{1, 2}},
+		{"full", []float64{1, 2, 3}, []float64{1, 2, 3}},
+		{"wrapped", []float64{1, 2, 3, 4}, []float64{2, 3, 4}},
+		{"wrapped twice", []float64{1, 2, 3, 4, 5, 6, 7}, []float64{5, 6, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRing(3)
+			for _, v := range tt.pushed {
+				r.push(v)
+			}
+			if got := r.window(nil); !slices.Equal(got, tt.want) {
+				t.Errorf("window() = %v, want %v", got, tt.want)
+			}
+			if r.len() != len(tt.want) {
+				t.Errorf("len() = %d, want %d", r.len(), len(tt.want))
+			}
+			for i, v := range tt.want {
+				if got := r.at(i); got != v {
+					t.Errorf("at(%d) = %v, want %v", i, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestRingWindowAppends(t *testing.T) {
+	r := newRing(2)
+	r.push(1)
+	r.push(2)
+	r.push(3)
+	buf := make([]float64, 0, 8)
+	got := r.window(append(buf, 0))
+	if want := []float64{0, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("window() = %v, want %v", got, want)
+	}
+	if &got[0] != &buf[:1][0] {
+		t.Error("window() allocated despite the capacity of dst")
+	}
+}
+
+func TestRingDropsOneValue(t *testing.T) {
+	r := newRing(40)
+	for i := 0; i < 41; i++ {
+		r.push(float64(i))
+	}
+	if r.len() != 40 || r.at(0) != 1 || r.at(39) != 40 {
+		t.Errorf("after 41 pushes: len %d, oldest %v, latest %v", r.len(), r.at(0), r.at(39))
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var riseThreshold = flag.Duration("rise", 0,
+	"alert when the RTT of a target rises by more than this within -rise-within, to catch congestion building up before -rtt-threshold, 0 disables it")
+var riseWithin = flag.Duration("rise-within", 10*time.Second,
+	"the period of -rise")
+
+// checkRise raises an alert when the RTT of smp is more than -rise above the
+// lowest of the replies of the last -rise-within, and resolves it when it is
+// not anymore, i.e. when the RTT went back down or stopped rising.
+func (s *series) checkRise(smp sample) {
+	if *riseThreshold <= 0 {
+		return
+	}
+	lowest := ms(smp.rtt)
+	for i := len(s.history) - 1; i >= 0 && smp.time.Sub(s.history[i].time) <= *riseWithin; i-- {
+		lowest = min(lowest, s.history[i].rtt)
+	}
+	rise := ms(smp.rtt) - lowest
+	if (rise > ms(*riseThreshold)) == s.rising {
+		return
+	}
+	s.rising = !s.rising
+	a := alert{time: smp.time, target: s.target, name: "rise", resolved: !s.rising}
+	if s.rising {
+		a.text = fmt.Sprintf("%s RTT rose by %s within %s to %s", s.target, formatMs(rise), *riseWithin, formatRTT(smp.rtt))
+	} else {
+		a.text = fmt.Sprintf("%s RTT steady again at %s", s.target, formatRTT(smp.rtt))
+	}
+	raiseAlert(a)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+var errTimeout = errors.New("timeout")
+
+const probeTimeout = time.Second
+
+// probeInterval is how often targets are probed, shorter with some -preset.
+var probeInterval = time.Second
+
+// sample is the outcome of one probe sent to a target. The probe type is the
+// scheme of the target.
+type sample struct {
+	target target
+	seq    int
+	time   time.Time         // when the probe was sent
+	rtt    time.Duration     // only meaningful when err is nil
+	err    error             // why no valid reply arrived, e.g. a timeout
+	meta   map[string]string // probe specific details, e.g. cert_days for TLS
+	mos    float64           // of the recent samples of the target, for sinks
+}
+
+func (s sample) lost() bool {
+	return s.err != nil
+}
+
+// pingSource probes t until ctx is done, sending a sample to out for every
+// probe, including the ones that got no reply.
+type pingSource func(ctx context.Context, t target, out chan<- sample) error
+
+// probeEvery calls probe once per probeInterval, or as often as the schedule
+// in the config and -adaptive say, until ctx is done, and sends a sample with
+// its result to out. Host names are resolved again as their records expire.
+// It suits probes that wait for their reply before sending the next one. The
+// first -warmup probes are sent in a quick burst, then the target waits for
+// its turn in the scheduler, and every probe for a slot in it. Probes may add
+// metadata to the sample.
+func probeEvery(ctx context.Context, t target, out chan<- sample, probe func(seq int, meta map[string]string) (time.Duration, error)) error {
+	var rate adaptiveRate
+	res := newResolution(t)
+	for seq := 0; ; seq++ {
+		if seq == max(currentSettings().warmup, 0) && probes.stagger(ctx) != nil {
+			return nil
+		}
+		res.refresh(time.Now())
+		if err := probes.acquire(ctx); err != nil {
+			return nil
+		}
+		start := time.Now()
+		meta := map[string]string{}
+		rtt, err := probe(seq, meta)
+		probes.release()
+		if len(meta) == 0 {
+			meta = nil
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			err = errTimeout
+		}
+		select {
+		case out <- sample{target: t, seq: seq, time: start, rtt: rtt, err: err, meta: meta}:
+		case <-ctx.Done():
+			return nil
+		}
+
+		interval := scheduledInterval(start)
+		if currentSettings().adaptive {
+			interval = rate.next(interval, rtt, err)
+		}
+		interval = warmingUp(seq, interval)
+		next := time.NewTimer(time.Until(start.Add(interval)))
+		select {
+		case <-ctx.Done():
+			next.Stop()
+			return nil
+		case <-next.C:
+		}
+	}
+}
+
+// rtts adapts a sample channel to the plain RTTs in milliseconds of the
+// probes that got a reply, for consumers that do not care about losses.
+func rtts(in <-chan sample) <-chan int64 {
+	out := make(chan int64)
+	go func() {
+		defer close(out)
+		for s := range in {
+			if !s.lost() {
+				out <- s.rtt.Milliseconds()
+			}
+		}
+	}()
+	return out
+}
+
+// sampleJSON is how samples are encoded by the exporters.
+type sampleJSON struct {
+	Target string            `json:"target"`
+	Probe  string            `json:"probe"`
+	Seq    int               `json:"seq"`
+	Time   time.Time         `json:"time"`
+	RTT    float64           `json:"rtt_ms,omitempty"`
+	Lost   bool              `json:"lost"`
+	Error  string            `json:"error,omitempty"`
+	Meta   map[string]string `json:"meta,omitempty"`
+	MOS    float64           `json:"mos,omitempty"`
+}
+
+func newSampleJSON(s sample) sampleJSON {
+	j := sampleJSON{
+		Target: s.target.String(),
+		Probe:  s.target.scheme,
+		Seq:    s.seq,
+		Time:   s.time,
+		Lost:   s.lost(),
+		Meta:   s.meta,
+		MOS:    s.mos,
+	}
+	if s.lost() {
+		j.Error = s.err.Error()
+	} else {
+		j.RTT = ms(s.rtt)
+	}
+	return j
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scheduleRule probes every Every during the hours and days it covers, e.g.
+//
+//	{"days": "mon-fri", "hours": "09:00-18:00", "every": "5m"}
+//
+// Days are a range or a comma separated list of the first three letters of
+// their names, and hours a range that may wrap past midnight. Both default
+// to all of them. The first rule covering a time sets the probe interval,
+// which is the default one outside of every rule.
+type scheduleRule struct {
+	timeWindow
+	Every string `json:"every"`
+
+	every time.Duration
+}
+
+// timeWindow is the days and hours a rule of the config covers.
+type timeWindow struct {
+	Days  string `json:"days,omitempty"`
+	Hours string `json:"hours,omitempty"`
+
+	days     [7]bool
+	from, to time.Duration // since midnight
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func weekday(name string) (int, error) {
+	for i, d := range weekdays {
+		if strings.EqualFold(name, d) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q", name)
+}
+
+func (r *scheduleRule) parse() error {
+	var err error
+	if r.every, err = time.ParseDuration(r.Every); err != nil || r.every <= 0 {
+		return fmt.Errorf("bad every %q", r.Every)
+	}
+	return r.timeWindow.parse()
+}
+
+func (r *timeWindow) parse() error {
+	var err error
+	if r.Days == "" {
+		r.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, part := range strings.Split(r.Days, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		from, err := weekday(first)
+		if err != nil {
+			return err
+		}
+		to := from
+		if isRange {
+			if to, err = weekday(last); err != nil {
+				return err
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			r.days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+
+	r.from, r.to = 0, 24*time.Hour
+	if r.Hours != "" {
+		from, to, ok := strings.Cut(r.Hours, "-")
+		if !ok {
+			return fmt.Errorf("bad hours %q, e.g. 09:00-18:00", r.Hours)
+		}
+		if r.from, err = clockTime(from); err != nil {
+			return err
+		}
+		if r.to, err = clockTime(to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clockTime parses "15:04" into the time since midnight.
+func clockTime(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q, e.g. 09:00", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// covers reports whether the rule applies at t, in the local time zone.
+func (r *timeWindow) covers(t time.Time) bool {
+	day := int(t.Weekday())
+	since := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if r.from <= r.to {
+		return r.days[day] && since >= r.from && since < r.to
+	}
+	// past midnight, the hours after it belong to the day before
+	if since >= r.from {
+		return r.days[day]
+	}
+	return since < r.to && r.days[(day+6)%7]
+}
+
+// scheduledInterval returns how often to probe at t.
+func scheduledInterval(t time.Time) time.Duration {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	for i := range settings.Schedule {
+		if settings.Schedule[i].covers(t) {
+			return settings.Schedule[i].every
+		}
+	}
+	return probeInterval
+}
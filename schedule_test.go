@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeWindowCovers(t *testing.T) {
+	// 2026-10-16 is a Friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name  string
+		days  string
+		hours string
+		time  time.Time
+		want  bool
+	}{
+		{"daytime start", "mon-fri", "09:00-18:00", at(16, 9, 0), true},
+		{"daytime end", "mon-fri", "09:00-18:00", at(16, 18, 0), false},
+		{"daytime other day", "mon-fri", "09:00-18:00", at(17, 10, 0), false},
+		{"night before midnight", "fri", "22:00-06:00", at(16, 23, 0), true},
+		{"night after midnight", "fri", "22:00-06:00", at(17, 5, 59), true},
+		{"night end", "fri", "22:00-06:00", at(17, 6, 0), false},
+		{"night of the day before", "fri", "22:00-06:00", at(16, 2, 0), false},
+		{"night of the next day", "fri", "22:00-06:00", at(17, 23, 0), false},
+		{"night into monday", "sun", "23:00-01:00", at(19, 0, 30), true},
+		{"days wrapping the week", "sat-mon", "", at(18, 12, 0), true},
+		{"day outside the wrapped range", "sat-mon", "", at(20, 12, 0), false},
+		{"list", "tue, fri", "", at(16, 0, 0), true},
+		{"every day", "", "", at(14, 12, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := timeWindow{Days: tt.days, Hours: tt.hours}
+			if err := w.parse(); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.covers(tt.time); got != tt.want {
+				t.Errorf("covers(%s) = %v, want %v", tt.time.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeWindowParseErrors(t *testing.T) {
+	for _, w := range []timeWindow{
+		{Days: "someday"},
+		{Days: "mon-xyz"},
+		{Hours: "09:00"},
+		{Hours: "9am-5pm"},
+	} {
+		if err := w.parse(); err == nil {
+			t.Errorf("parse(%+v) succeeded", w)
+		}
+	}
+}
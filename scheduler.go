@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math"
+	"sync"
+	"time"
+)
+
+var maxInFlight = flag.Int("max-inflight", 32,
+	"maximum number of probes waiting for a reply at once, to avoid exhausting sockets with many targets")
+
+// scheduler bounds how many probes are in flight at once, and spreads the
+// start of the targets over a probe interval, so that many targets do not
+// probe in synchronized bursts that trigger ICMP rate limits.
+type scheduler struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	freed    chan struct{} // closed when a slot is freed, or the limit raised
+	started  int
+}
+
+var probes *scheduler
+
+func newScheduler(maxInFlight int) *scheduler {
+	return &scheduler{limit: maxInFlight, freed: make(chan struct{})}
+}
+
+// resize changes how many probes may be in flight at once. The ones in
+// flight beyond it finish first.
+func (sc *scheduler) resize(maxInFlight int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.limit = maxInFlight
+	sc.wake()
+}
+
+// wake lets the probes waiting for a slot try again.
+func (sc *scheduler) wake() {
+	close(sc.freed)
+	sc.freed = make(chan struct{})
+}
+
+// stagger waits before a new target starts probing at its interval, after its
+// warm-up burst. The offsets follow the
+// golden ratio sequence, which spreads any number of targets evenly even
+// when they are added at runtime.
+func (sc *scheduler) stagger(ctx context.Context) error {
+	sc.mu.Lock()
+	n := sc.started
+	sc.started++
+	sc.mu.Unlock()
+
+	_, frac := math.Modf(float64(n) * (math.Sqrt(5) - 1) / 2)
+	select {
+	case <-time.After(time.Duration(frac * float64(probeInterval))):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// acquire waits for a free slot to send a probe, to be given back with
+// release once its reply arrived or timed out.
+func (sc *scheduler) acquire(ctx context.Context) error {
+	for {
+		sc.mu.Lock()
+		if sc.inFlight < sc.limit {
+			sc.inFlight++
+			sc.mu.Unlock()
+			return nil
+		}
+		freed := sc.freed
+		sc.mu.Unlock()
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (sc *scheduler) release() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.inFlight--
+	sc.wake()
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackpal/gateway"
+)
+
+var segmentsFlag = flag.Bool("segments", false,
+	"also probe the gateway and the first hop of the ISP, found by tracing the first target beyond them, and show how its RTT splits into the home, last mile and backbone segments")
+
+// segmentNames are the parts of the path the breakdown shows: up to the
+// gateway, from it to the first router of the ISP, and beyond.
+var segmentNames = []string{"home", "last mile", "backbone"}
+
+// segments are the series at the end of every segment of the path to a
+// target.
+type segments struct {
+	ends [3]target // the gateway, the first hop of the ISP and the target
+	all  [3]*series
+}
+
+// withSegments adds the gateway and the first hop of the ISP to targets, if
+// not there yet, tracing the path to the first target beyond the local
+// network to find it.
+func withSegments(targets []target) ([]target, *segments, error) {
+	gatewayIP, err := gateway.DiscoverGateway()
+	if err != nil {
+		return nil, nil, err
+	}
+	far := -1
+	for i, t := range targets {
+		if !local(t) && !probeTypes[t.scheme].opaque {
+			far = i
+			break
+		}
+	}
+	if far < 0 {
+		return nil, nil, errors.New("-segments needs a target beyond the local network")
+	}
+
+	fmt.Fprintf(os.Stderr, "Tracing the path to %s...\n", targets[far])
+	tr := &trace{target: targets[far], until: func(h traceHop) bool {
+		ip := net.ParseIP(h.addr)
+		return ip != nil && !ip.IsPrivate() && !ip.IsLoopback()
+	}}
+	if err := tr.run(context.Background()); err != nil {
+		return nil, nil, err
+	}
+	last := tr.hops[len(tr.hops)-1]
+	if last.addr == "" || last.addr == hostOf(targets[far]) {
+		return nil, nil, fmt.Errorf("found no router of the ISP on the path to %s", targets[far])
+	}
+
+	sg := &segments{ends: [3]target{
+		{scheme: "icmp", address: gatewayIP.String(), label: "gateway", group: gatewayIP.String()},
+		{scheme: "icmp", address: last.addr, label: "ISP first hop", group: last.addr},
+		targets[far],
+	}}
+	var with []target
+	probed := false
+	for _, t := range targets {
+		if t.scheme == "icmp" && t.address == sg.ends[0].address {
+			sg.ends[0], probed = t, true
+		}
+	}
+	if !probed {
+		with = append(with, sg.ends[0])
+	}
+	for i, t := range targets {
+		if i == far {
+			with = append(with, sg.ends[1])
+		}
+		with = append(with, t)
+	}
+	return with, sg, nil
+}
+
+// hostOf returns the host t probes, without a port.
+func hostOf(t target) string {
+	if h, _, err := net.SplitHostPort(t.address); err == nil {
+		return h
+	}
+	return t.address
+}
+
+// find looks for the series of the ends of the segments in all.
+func (sg *segments) find(all []*series) {
+	for _, s := range all {
+		for i, t := range sg.ends {
+			if s.target == t {
+				sg.all[i] = s
+			}
+		}
+	}
+}
+
+// latencies returns the average RTT of every segment over the latest
+// diagnoseWindow, as the difference between the RTTs of its ends, floored
+// at zero as the jitter of the nearer end can exceed it.
+func (sg *segments) latencies(now time.Time) ([3]float64, bool) {
+	var l [3]float64
+	prev := 0.0
+	for i, s := range sg.all {
+		if s == nil {
+			return l, false
+		}
+		avg, sent, lost := s.period(now.Add(-diagnoseWindow), now)
+		if sent == lost {
+			return l, false
+		}
+		l[i] = max(avg-prev, 0)
+		prev = avg
+	}
+	return l, true
+}
+
+// segmentBarWidth is the width of the stacked bar of the breakdown.
+const segmentBarWidth = 40
+
+// draw shows the breakdown in a line, with a bar stacking the segments.
+func (sg *segments) draw(width int) {
+	l, ok := sg.latencies(time.Now())
+	color.Set(activeTheme.text...)
+	if !ok {
+		fmt.Fprintf(&frame, "%s\033[K\n\n", truncate("Segments: waiting for replies of the gateway, the ISP and "+sg.ends[2].String(), width))
+		return
+	}
+	total := l[0] + l[1] + l[2]
+	var labels []string
+	fmt.Fprint(&frame, "Segments: ")
+	for i, v := range l {
+		n := 0
+		if total > 0 {
+			n = int(v/total*segmentBarWidth + 0.5)
+		}
+		color.Set(activeTheme.series[i%len(activeTheme.series)]...)
+		fmt.Fprint(&frame, strings.Repeat("█", n))
+		labels = append(labels, fmt.Sprintf("%s %s", segmentNames[i], formatMs(v)))
+	}
+	color.Set(activeTheme.text...)
+	fmt.Fprintf(&frame, " %s\033[K\n\n", truncate(strings.Join(labels, " + "), max(width-segmentBarWidth-12, 0)))
+}
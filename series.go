@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const maxHeight = 10
+
+// graphColumns is how many columns wide the graphs are.
+const graphColumns = 40
+
+// maxLen is how many samples the graphs show, more than graphColumns with
+// renderers drawing several per column.
+var maxLen = graphColumns
+
+// anomalyMinSamples is how many replies are needed before looking for
+// anomalies, and anomalyMinDelta avoids flagging 1 ms jitter on quiet links.
+const (
+	anomalyMinSamples = 10
+	anomalyMinDelta   = 5
+)
+
+// lossBurst is how many probes in a row must be lost to log a loss burst.
+const lossBurst = 3
+
+// series is the history of a target, as shown in its graph.
+type series struct {
+	target    target
+	recent    ring      // the latest replies, as shown in the graph
+	frame     []float64 // recent with the zero anchoring the Y axis, reused every frame
+	history   []point   // every reply of the session, for scrolling back
+	lost      []point   // when the lost probes of the session were sent
+	last      sample
+	meta      map[string]string // of the latest sample that had metadata
+	stats     stats
+	minutes   []bucket // per minute aggregates for the heatmap
+	anomalies []int    // indexes in history of the anomalous replies
+	lostInRow int
+	lostSince time.Time // when the first of lostInRow probes was sent
+	down      bool      // no replies for -down-after
+	outages   []outage
+	breached  bool // the last RTT was above -rtt-threshold
+	rising    bool // the last RTT rose by more than -rise
+	slos      []*sloTracker
+	hops      int    // plus one, inferred from the TTL of the last reply, 0 when unknown
+	node      string // anycast node answering the last reply, for DNS servers
+	// the history is downsampled every minute, tierEnds being the index
+	// where the points merged by each of historyTiers end
+	downsampled time.Time
+	tierEnds    []int
+	stop        func() // stops probing the target
+}
+
+// outage is a period without replies from a target longer than -down-after.
+type outage struct {
+	start, end time.Time // end is zero while the outage lasts
+}
+
+// downtime returns the total duration of the outages of s until now.
+func (s *series) downtime(now time.Time) time.Duration {
+	var total time.Duration
+	for _, o := range s.outages {
+		end := o.end
+		if end.IsZero() {
+			end = now
+		}
+		total += end.Sub(o.start)
+	}
+	return total
+}
+
+// point is a reply in the history of a series, or a lost probe.
+type point struct {
+	time time.Time
+	rtt  float64 // ms
+	n    int     // how many replies or lost probes were merged into the point
+}
+
+// waitingText replaces the RTT of targets that did not reply yet.
+const waitingText = "waiting for first reply…"
+
+// waiting reports whether no reply arrived yet, so there is nothing to plot.
+func (s *series) waiting() bool {
+	return len(s.history) == 0
+}
+
+// result describes the last sample of s for captions.
+func (s *series) result() string {
+	if s.last.time.IsZero() {
+		return waitingText
+	}
+	return formatResult(s.last)
+}
+
+// resized keeps the latest replies of s again after maxLen changed.
+func (s *series) resized() {
+	s.recent = newRing(maxLen - 1)
+	for _, p := range s.history[max(len(s.history)-(maxLen-1), 0):] {
+		s.recent.push(p.rtt)
+	}
+}
+
+func newSeries(t target) *series {
+	return &series{target: t, recent: newRing(maxLen - 1), slos: newSLOTrackers(t)}
+}
+
+// add records a new sample. It logs loss bursts and anomalous RTTs, i.e.
+// above the mean plus three standard deviations of the samples in the graph,
+// and raises alerts for threshold breaches and targets that went down.
+func (s *series) add(smp sample) {
+	s.last = smp
+	if smp.meta != nil {
+		s.meta = smp.meta
+	}
+	s.stats.add(smp)
+	defer s.downsample(smp.time)
+	for _, o := range s.slos {
+		o.add(s.target, smp)
+	}
+	minute := smp.time.Truncate(time.Minute)
+	if len(s.minutes) == 0 || s.minutes[len(s.minutes)-1].start.Before(minute) {
+		s.minutes = append(s.minutes, bucket{start: minute})
+	}
+	s.minutes[len(s.minutes)-1].add(smp)
+	if smp.lost() {
+		s.lost = append(s.lost, point{time: smp.time, n: 1})
+		if s.lostInRow == 0 {
+			s.lostSince = smp.time
+		}
+		s.lostInRow++
+		if s.lostInRow == lossBurst {
+			events.add(smp.time, fmt.Sprintf("loss burst %s: %d probes lost in a row", s.target, lossBurst))
+		}
+		if !s.down && smp.time.Sub(s.lostSince) >= *downAfter {
+			s.down = true
+			s.outages = append(s.outages, outage{start: s.lostSince})
+			raiseAlert(alert{time: smp.time, target: s.target, name: "down",
+				text: fmt.Sprintf("%s down, no replies for %s", s.target, *downAfter)})
+		}
+		return
+	}
+	if s.down {
+		s.down = false
+		s.outages[len(s.outages)-1].end = smp.time
+		raiseAlert(alert{time: smp.time, target: s.target, name: "down", resolved: true,
+			text: fmt.Sprintf("%s up again after %s", s.target, smp.time.Sub(s.lostSince).Round(time.Second))})
+	} else if s.lostInRow >= lossBurst {
+		events.add(smp.time, fmt.Sprintf("%s resumed after %d lost probes", s.target, s.lostInRow))
+	}
+	s.lostInRow = 0
+	s.checkHops(smp)
+	s.checkNode(smp)
+	if s.target.unit() == "" {
+		s.checkRTT(smp)
+	}
+
+	rtt := ms(smp.rtt)
+	s.recent.push(rtt)
+	s.history = append(s.history, point{time: smp.time, rtt: rtt, n: 1})
+}
+
+// checkRTT raises the alerts on the RTT of smp and records it as a spike if
+// it is far above the latest ones.
+func (s *series) checkRTT(smp sample) {
+	if *rttThreshold > 0 && (smp.rtt > *rttThreshold) != s.breached {
+		s.breached = !s.breached
+		a := alert{time: smp.time, target: s.target, name: "rtt", resolved: !s.breached}
+		if s.breached {
+			a.text = fmt.Sprintf("%s above %s: %s", s.target, *rttThreshold, formatRTT(smp.rtt))
+		} else {
+			a.text = fmt.Sprintf("%s back below %s: %s", s.target, *rttThreshold, formatRTT(smp.rtt))
+		}
+		raiseAlert(a)
+	}
+	s.checkRise(smp)
+
+	rtt := ms(smp.rtt)
+	latest, _ := s.window(0, 1)
+	if mean, stddev, n := meanStdDev(latest[1:]); n >= anomalyMinSamples && rtt > mean+3*stddev && rtt-mean >= anomalyMinDelta {
+		s.anomalies = append(s.anomalies, len(s.history))
+		events.add(smp.time, fmt.Sprintf("spike %s %s (mean %s, σ %s)", s.target, formatRTT(smp.rtt), formatMs(mean), formatMs(stddev)))
+		startCapture(s.target, "spike")
+	}
+}
+
+// window returns the graph data scrolled back by scroll replies, with zoom
+// replies per point, and the index in history of data[1], data[0] being the
+// zero that anchors the Y axis of the graphs.
+func (s *series) window(scroll, zoom int) ([]float64, int) {
+	if scroll == 0 && zoom == 1 {
+		s.frame = s.recent.window(append(s.frame[:0], 0))
+		return s.frame, len(s.history) - s.recent.len()
+	}
+
+	end := len(s.history) - scroll
+	if end < 0 {
+		end = 0
+	}
+	start := end - (maxLen-1)*zoom
+	if start < 0 {
+		start = 0
+	}
+	data := make([]float64, 1, (end-start)/zoom+2)
+	// zoomed out, a point is the highest of zoom replies, so that spikes
+	// are not averaged away
+	for i := start; i < end; i += zoom {
+		highest := 0.0
+		for _, p := range s.history[i:min(i+zoom, end)] {
+			highest = max(highest, p.rtt)
+		}
+		data = append(data, highest)
+	}
+	return data, start
+}
+
+func meanStdDev(data []float64) (mean, stddev float64, n int) {
+	if len(data) == 0 {
+		return 0, 0, 0
+	}
+	for _, v := range data {
+		mean += v
+	}
+	mean /= float64(len(data))
+	for _, v := range data {
+		stddev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(data))), len(data)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackpal/gateway"
+)
+
+// setupTargets are the targets offered by the setup, besides the gateway.
+var setupTargets = []struct{ address, label string }{
+	{cloudFlareIP, "CloudFlare's DNS"},
+	{"8.8.8.8", "Google's DNS"},
+	{"dns://1.1.1.1", "DNS resolution time at CloudFlare"},
+	{"tls://www.google.com", "TLS handshake with a web site"},
+	{"tcp://github.com:443", "TCP connect to a web site"},
+}
+
+// firstRun reports whether netcheck runs for the first time, without
+// config, interactively, to offer the setup.
+func firstRun(command string, args []string) bool {
+	if command != "run" || len(args) > 0 || *configPath != "" || *duration > 0 || *count > 0 || *dumb || *plain {
+		return false
+	}
+	path := defaultConfigPath()
+	if _, err := os.Stat(path); path == "" || !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	for _, f := range []*os.File{os.Stdin, os.Stdout} {
+		if fi, err := f.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// runSetup implements "netcheck setup", also offered on the first run: it
+// asks which targets to probe, how often, and the thresholds to alert at,
+// and writes the config. Declined on the first run, it writes an empty
+// config, not to ask again.
+func runSetup(in io.Reader, out io.Writer, offered bool) error {
+	r := bufio.NewReader(in)
+	ask := func(question, def string) string {
+		fmt.Fprintf(out, "%s [%s]: ", question, def)
+		line, _ := r.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+		return def
+	}
+	path := defaultConfigPath()
+	var c config
+
+	if offered && !strings.HasPrefix(strings.ToLower(ask("No config found. Set up netcheck now?", "Y/n")), "y") {
+		fmt.Fprintf(out, "Run netcheck setup to do it later.\n")
+		return writeConfig(path, c)
+	}
+
+	var choices []string
+	if ip, err := gateway.DiscoverGateway(); err == nil {
+		choices = append(choices, ip.String())
+		fmt.Fprintf(out, "  1. %s, your gateway\n", ip)
+	}
+	for _, t := range setupTargets {
+		choices = append(choices, t.address)
+		fmt.Fprintf(out, "  %d. %s, %s\n", len(choices), t.address, t.label)
+	}
+	for {
+		picked := ask("Targets to probe, by number, or other addresses", "1 2")
+		c.Targets = nil
+		for _, field := range strings.Fields(strings.ReplaceAll(picked, ",", " ")) {
+			if n, err := strconv.Atoi(field); err == nil && n >= 1 && n <= len(choices) {
+				c.Targets = append(c.Targets, choices[n-1])
+			} else if _, err := parseTargets(field); err == nil {
+				c.Targets = append(c.Targets, field)
+			} else {
+				fmt.Fprintf(out, "  %s: %v\n", field, err)
+				c.Targets = nil
+				break
+			}
+		}
+		if len(c.Targets) > 0 {
+			break
+		}
+	}
+
+	c.Flags = map[string]string{}
+	for {
+		interval, err := time.ParseDuration(ask("Probe every", probeInterval.String()))
+		if err == nil && interval > 0 {
+			if interval != probeInterval {
+				c.Schedule = []scheduleRule{{Every: interval.String()}}
+			}
+			break
+		}
+		fmt.Fprintf(out, "  a duration, e.g. 500ms or 5s\n")
+	}
+	for {
+		answer := ask("Alert when the RTT goes above, 0 for never", "150ms")
+		if d, err := time.ParseDuration(answer); err == nil && d >= 0 {
+			if d > 0 {
+				c.Flags["rtt-threshold"] = d.String()
+			}
+			break
+		}
+		fmt.Fprintf(out, "  a duration, e.g. 150ms\n")
+	}
+	for {
+		answer := ask("Fail -duration and -count runs when the loss goes above this percentage, 0 for never", "5")
+		if v, err := strconv.ParseFloat(answer, 64); err == nil && v >= 0 {
+			if v > 0 {
+				c.Flags["loss-threshold"] = answer
+			}
+			break
+		}
+		fmt.Fprintf(out, "  a percentage, e.g. 5\n")
+	}
+
+	if err := writeConfig(path, c); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote %s\n", path)
+	return nil
+}
+
+// writeConfig writes c to path, creating its directory.
+func writeConfig(path string, c config) error {
+	if path == "" {
+		return errors.New("no user config directory to write the config to")
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// sink receives every sample, e.g. to export it to another system. write must
+// not block the display for long.
+type sink interface {
+	write(s sample)
+	close() error
+}
+
+// eventSink is a sink that also receives the events of the session.
+type eventSink interface {
+	writeEvent(e event)
+}
+
+// removeSink is a sink that keeps state per target, to forget when the
+// target is removed.
+type removeSink interface {
+	remove(t target)
+}
+
+var sinks []sink
+
+func writeSinks(s sample) {
+	if s.target.unit() != "" {
+		return // the sinks export RTTs
+	}
+	for _, k := range sinks {
+		k.write(s)
+	}
+}
+
+func writeEventSinks(e event) {
+	for _, k := range sinks {
+		if k, ok := k.(eventSink); ok {
+			k.writeEvent(e)
+		}
+	}
+}
+
+func removeFromSinks(t target) {
+	for _, k := range sinks {
+		if k, ok := k.(removeSink); ok {
+			k.remove(t)
+		}
+	}
+}
+
+// closeSinks flushes whatever the sinks have buffered.
+func closeSinks() {
+	for _, k := range sinks {
+		if err := k.close(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// sloRule is a latency objective for the targets, e.g.
+//
+//	{"target": "1.1.1.1", "percent": 99, "below": "50ms", "over": "1h"}
+//
+// for 99% of the probes to get a reply faster than 50 ms over any hour. The
+// error budget is the 1% of the probes of the hour that may be lost or
+// slower. Without a target, the rule applies to every one.
+type sloRule struct {
+	Target  string  `json:"target"`
+	Percent float64 `json:"percent"`
+	Below   string  `json:"below"`
+	Over    string  `json:"over"`
+
+	below, over time.Duration
+}
+
+func (r *sloRule) parse() error {
+	var err error
+	if r.Percent <= 0 || r.Percent >= 100 {
+		return fmt.Errorf("bad percent %v", r.Percent)
+	}
+	if r.below, err = time.ParseDuration(r.Below); err != nil || r.below <= 0 {
+		return fmt.Errorf("bad below %q", r.Below)
+	}
+	if r.over, err = time.ParseDuration(r.Over); err != nil || r.over <= 0 {
+		return fmt.Errorf("bad over %q", r.Over)
+	}
+	return nil
+}
+
+func (r *sloRule) String() string {
+	return fmt.Sprintf("%g%% < %s over %s", r.Percent, r.below, r.over)
+}
+
+// applies reports whether the rule is for t, named by its address as given
+// or as shown.
+func (r *sloRule) applies(t target) bool {
+	return t.scheme != "delta" && (r.Target == "" || r.Target == t.group || r.Target == t.String())
+}
+
+// sloTracker follows the error budget of a rule for a target over the
+// period of the rule.
+type sloTracker struct {
+	rule     *sloRule
+	probes   []sloProbe // of the period, oldest first
+	failed   int        // in probes
+	breached bool       // the budget is spent
+}
+
+type sloProbe struct {
+	time   time.Time
+	failed bool // lost or too slow
+}
+
+// newSLOTrackers returns trackers of the rules of the config for t.
+func newSLOTrackers(t target) []*sloTracker {
+	if t.unit() != "" {
+		return nil // the rules are on RTTs and loss
+	}
+	var trackers []*sloTracker
+	for i := range settings.SLOs {
+		if r := &settings.SLOs[i]; r.applies(t) {
+			trackers = append(trackers, &sloTracker{rule: r})
+		}
+	}
+	return trackers
+}
+
+// add counts a sample of t, raising an alert when the budget runs out and
+// when it is back.
+func (o *sloTracker) add(t target, smp sample) {
+	failed := smp.lost() || smp.rtt >= o.rule.below
+	o.probes = append(o.probes, sloProbe{smp.time, failed})
+	if failed {
+		o.failed++
+	}
+	drop := 0
+	for drop < len(o.probes) && smp.time.Sub(o.probes[drop].time) > o.rule.over {
+		if o.probes[drop].failed {
+			o.failed--
+		}
+		drop++
+	}
+	o.probes = o.probes[drop:]
+
+	if used := o.used(smp.time); (used >= 1) != o.breached {
+		o.breached = !o.breached
+		a := alert{time: smp.time, target: t, name: "slo", resolved: !o.breached}
+		if o.breached {
+			a.text = fmt.Sprintf("%s SLO %s breached: %d of %d probes failed", t, o.rule, o.failed, len(o.probes))
+		} else {
+			a.text = fmt.Sprintf("%s SLO %s met again", t, o.rule)
+		}
+		raiseAlert(a)
+	}
+}
+
+// used returns the share of the error budget of the period spent. The
+// budget is that of the probes of a whole period at the current interval,
+// so that a slow reply right after the start does not spend it all.
+func (o *sloTracker) used(now time.Time) float64 {
+	expected := max(float64(len(o.probes)), float64(o.rule.over/scheduledInterval(now)))
+	budget := expected * (100 - o.rule.Percent) / 100
+	return float64(o.failed) / budget
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var snapshotPath = flag.String("snapshot", "",
+	"save the graphs to this PNG or SVG file, by extension, when pressing s and on exit, a timestamped PNG by default")
+
+// Snapshot layout, in pixels.
+const (
+	snapshotWidth  = 800
+	snapshotGraph  = 140 // height of a graph
+	snapshotMargin = 40
+	snapshotScale  = 2 // of the bitmap font in PNG snapshots
+)
+
+// snapshotColors match the series colors of the dark theme.
+var snapshotColors = []color.RGBA{
+	{0, 170, 170, 255}, {170, 0, 170, 255}, {170, 170, 0, 255},
+	{0, 170, 0, 255}, {0, 0, 204, 255}, {204, 0, 0, 255},
+}
+
+// snapshotFile is where the s key saves the graphs.
+func snapshotFile() string {
+	if *snapshotPath != "" {
+		return *snapshotPath
+	}
+	return time.Now().Format("netcheck-20060102-150405.png")
+}
+
+// snapshotSeries is a graph as shown on the screen.
+type snapshotSeries struct {
+	caption string
+	data    []float64
+	color   color.RGBA
+}
+
+// writeSnapshot renders the graphs as shown on the screen, scrolled back by
+// scroll replies, to a PNG or SVG file.
+func writeSnapshot(path string, all []*series, scroll int, maxValue float64) error {
+	var graphs []snapshotSeries
+	group := -1
+	for i, s := range all {
+		if i == 0 || s.target.group != all[i-1].target.group {
+			group++
+		}
+		data, _ := s.window(scroll, 1)
+		graphs = append(graphs, snapshotSeries{
+			caption: fmt.Sprintf("%s %s: %s", probeNames[s.target.scheme], s.target, s.result()),
+			data:    data[1:], // without the zero anchoring the axis
+			color:   snapshotColors[group%len(snapshotColors)],
+		})
+	}
+	if maxValue <= 0 {
+		maxValue = 1
+	}
+
+	var b []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svg":
+		b = snapshotSVG(graphs, maxValue)
+	case ".png":
+		var err error
+		if b, err = snapshotPNG(graphs, maxValue); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("snapshot %s: use a .png or .svg file", path)
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// snapshotPoints returns the pixel coordinates of the data of the i-th graph.
+func snapshotPoints(i int, data []float64, maxValue float64) []image.Point {
+	top := snapshotMargin + i*(snapshotGraph+snapshotMargin)
+	var points []image.Point
+	for j, v := range data {
+		x := snapshotMargin + j*(snapshotWidth-2*snapshotMargin)/max(maxLen-2, 1)
+		y := top + snapshotGraph - int(min(v, maxValue)/maxValue*snapshotGraph)
+		points = append(points, image.Point{x, y})
+	}
+	return points
+}
+
+func snapshotSVG(graphs []snapshotSeries, maxValue float64) []byte {
+	var b bytes.Buffer
+	height := len(graphs)*(snapshotGraph+snapshotMargin) + snapshotMargin
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="monospace" font-size="12">`+"\n", snapshotWidth, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	for i, g := range graphs {
+		top := snapshotMargin + i*(snapshotGraph+snapshotMargin)
+		c := fmt.Sprintf("#%02x%02x%02x", g.color.R, g.color.G, g.color.B)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", snapshotMargin, top-8, c, html.EscapeString(g.caption))
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999"/>`+"\n",
+			snapshotMargin, top, snapshotMargin, top+snapshotGraph)
+		fmt.Fprintf(&b, `<text x="2" y="%d" fill="#666">%.0f</text>`+"\n", top+10, maxValue)
+		fmt.Fprintf(&b, `<text x="2" y="%d" fill="#666">0</text>`+"\n", top+snapshotGraph)
+		var points []string
+		for _, p := range snapshotPoints(i, g.data, maxValue) {
+			points = append(points, fmt.Sprintf("%d,%d", p.X, p.Y))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`+"\n", c, strings.Join(points, " "))
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+func snapshotPNG(graphs []snapshotSeries, maxValue float64) ([]byte, error) {
+	height := len(graphs)*(snapshotGraph+snapshotMargin) + snapshotMargin
+	img := image.NewRGBA(image.Rect(0, 0, snapshotWidth, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	gray := color.RGBA{153, 153, 153, 255}
+	for i, g := range graphs {
+		top := snapshotMargin + i*(snapshotGraph+snapshotMargin)
+		drawText(img, snapshotMargin, top-8-5*snapshotScale, g.caption, g.color)
+		drawLine(img, image.Point{snapshotMargin, top}, image.Point{snapshotMargin, top + snapshotGraph}, gray)
+		drawText(img, 2, top, fmt.Sprintf("%.0f", maxValue), gray)
+		drawText(img, 2, top+snapshotGraph-5*snapshotScale, "0", gray)
+		points := snapshotPoints(i, g.data, maxValue)
+		for j := 1; j < len(points); j++ {
+			drawLine(img, points[j-1], points[j], g.color)
+		}
+	}
+	var b bytes.Buffer
+	err := png.Encode(&b, img)
+	return b.Bytes(), err
+}
+
+// drawLine draws a line with Bresenham's algorithm.
+func drawLine(img *image.RGBA, from, to image.Point, c color.RGBA) {
+	dx, dy := abs(to.X-from.X), -abs(to.Y-from.Y)
+	sx, sy := 1, 1
+	if from.X > to.X {
+		sx = -1
+	}
+	if from.Y > to.Y {
+		sy = -1
+	}
+	err := dx + dy
+	for p := from; ; {
+		img.SetRGBA(p.X, p.Y, c)
+		if p == to {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			p.X += sx
+		} else {
+			err += dx
+			p.Y += sy
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// drawText writes s with a 3x5 pixel font, as the standard library has no
+// font rendering. Characters without a glyph are left blank.
+func drawText(img *image.RGBA, x, y int, s string, c color.RGBA) {
+	for _, r := range strings.ToUpper(s) {
+		glyph := glyphs[r]
+		for row := 0; row < 5 && glyph != ""; row++ {
+			for col := 0; col < 3; col++ {
+				if glyph[row*3+col] != '#' {
+					continue
+				}
+				for i := 0; i < snapshotScale*snapshotScale; i++ {
+					img.SetRGBA(x+col*snapshotScale+i%snapshotScale, y+row*snapshotScale+i/snapshotScale, c)
+				}
+			}
+		}
+		x += 4 * snapshotScale
+	}
+}
+
+// glyphs are the rows of a 3x5 pixel font.
+var glyphs = map[rune]string{
+	'0': "####.##.##.####", '1': ".#.##..#..#.###", '2': "###..#####..###",
+	'3': "###..####..####", '4': "#.##.####..#..#", '5': "####..###..####",
+	'6': "####..####.####", '7': "###..#..#..#..#", '8': "####.#####.####",
+	'9': "####.####..####", 'A': ".#.#.#####.##.#", 'B': "##.#.###.#.###.",
+	'C': "####..#..#..###", 'D': "##.#.##.##.###.", 'E': "####..##.#..###",
+	'F': "####..##.#..#..", 'G': "####..#.##.####", 'H': "#.##.#####.##.#",
+	'I': "###.#..#..#.###", 'J': "..#..#..##.####", 'K': "#.##.###.#.##.#",
+	'L': "#..#..#..#..###", 'M': "#.########.##.#", 'N': "##.#.##.##.##.#",
+	'O': "####.##.##.####", 'P': "####.#####..#..", 'Q': "####.##.####..#",
+	'R': "##.#.###.#.##.#", 'S': "####..###..####", 'T': "###.#..#..#..#.",
+	'U': "#.##.##.##.####", 'V': "#.##.##.##.#.#.", 'W': "#.##.########.#",
+	'X': "#.##.#.#.#.##.#", 'Y': "#.##.#.#..#..#.", 'Z': "###..#.#.#..###",
+	'.': ".............#.", ':': "....#.....#....", '/': "..#..#.#.#..#..",
+	'-': "......###......", '%': "#.#..#.#.#..#.#",
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackpal/gateway"
+)
+
+var snmpGateway = flag.Bool("snmp", false, "also poll the gateway with SNMP, as an snmp:// target")
+var snmpCommunity = flag.String("snmp-community", "public", "SNMP community of snmp:// targets")
+var snmpIfIndex = flag.Int("snmp-if", 0, "index of the interface snmp:// targets graph, 0 for the busiest one")
+
+func init() {
+	registerProbe("snmp", probeType{name: "SNMP", port: "161", unit: "Mbit/s", newProbe: func(t target) probe {
+		return &sourceProbe{name: "SNMP", target: t, source: snmpSource}
+	}})
+}
+
+// SNMP objects polled, from IF-MIB, HOST-RESOURCES-MIB and UCD-SNMP-MIB.
+const (
+	oidIfInOctets       = "1.3.6.1.2.1.2.2.1.10"
+	oidIfInErrors       = "1.3.6.1.2.1.2.2.1.14"
+	oidIfOutOctets      = "1.3.6.1.2.1.2.2.1.16"
+	oidIfOutErrors      = "1.3.6.1.2.1.2.2.1.20"
+	oidIfHCInOctets     = "1.3.6.1.2.1.31.1.1.1.6"
+	oidIfHCOutOctets    = "1.3.6.1.2.1.31.1.1.1.10"
+	oidHrProcessorLoad  = "1.3.6.1.2.1.25.3.3.1.2"
+	oidSsCPUIdle        = "1.3.6.1.4.1.2021.11.11.0"
+	snmpMaxWalk         = 256 // rows read by a walk, to bound it on odd agents
+	snmpCounter32Modulo = 1 << 32
+)
+
+// BER tags of SNMP messages.
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+	berCounter32   = 0x41
+	berGauge32     = 0x42
+	berTimeTicks   = 0x43
+	berCounter64   = 0x46
+	snmpGetPDU     = 0xa0
+	snmpNextPDU    = 0xa1
+	snmpRespPDU    = 0xa2
+)
+
+// snmpVar is a variable binding of a response, with numeric values only.
+type snmpVar struct {
+	oid   string
+	tag   byte // of the value, e.g. noSuchObject (0x80) when missing
+	value uint64
+}
+
+// snmpClient sends SNMPv2c requests to an agent.
+type snmpClient struct {
+	d         *net.Dialer
+	addr      string
+	community string
+}
+
+// snmpSource polls the interface counters and the CPU load of the SNMP agent
+// of t, usually the router, graphing the download throughput in Mbit/s so
+// that saturation of the WAN shows next to the latency. The upload, the
+// interface errors and the CPU load go in the note of the target.
+func snmpSource(ctx context.Context, t target, out chan<- sample) error {
+	c := &snmpClient{d: t.dialer("udp", probeTimeout), addr: t.address, community: *snmpCommunity}
+	index := *snmpIfIndex
+	if index <= 0 {
+		var err error
+		if index, err = c.busiestInterface(); err != nil {
+			return err
+		}
+	}
+	last, err := c.interfaceCounters(index)
+	if err != nil {
+		return err
+	}
+	return probeEvery(ctx, t, out, func(_ int, meta map[string]string) (time.Duration, error) {
+		now, err := c.interfaceCounters(index)
+		if err != nil {
+			return 0, err
+		}
+		secs := now.time.Sub(last.time).Seconds()
+		down := float64(counterDelta(now.in, last.in, now.wide)) * 8 / secs / 1e6
+		up := float64(counterDelta(now.out, last.out, now.wide)) * 8 / secs / 1e6
+		meta["if"] = strconv.Itoa(index)
+		meta["down_mbps"] = strconv.FormatFloat(down, 'f', 1, 64)
+		meta["up_mbps"] = strconv.FormatFloat(up, 'f', 1, 64)
+		meta["if_errors"] = strconv.FormatUint(counterDelta(now.errors, last.errors, false), 10)
+		if cpu, ok := c.cpuLoad(); ok {
+			meta["cpu_pct"] = strconv.Itoa(cpu)
+		}
+		last = now
+		// the throughput is stored as ms, and shown in Mbit/s
+		return time.Duration(down * float64(time.Millisecond)), nil
+	})
+}
+
+// snmpNote describes the metadata of an snmp:// sample.
+func snmpNote(meta map[string]string) note {
+	text := fmt.Sprintf("if %s ↓ %s ↑ %s Mbit/s, errors %s", meta["if"], meta["down_mbps"], meta["up_mbps"], meta["if_errors"])
+	cpu, err := strconv.Atoi(meta["cpu_pct"])
+	if err == nil {
+		text += fmt.Sprintf(", CPU %d%%", cpu)
+	}
+	return note{text: text, warn: meta["if_errors"] != "0" || cpu >= 90}
+}
+
+// withSNMPGateway adds an snmp:// target for the gateway.
+func withSNMPGateway(targets []target) ([]target, error) {
+	ip, err := gateway.DiscoverGateway()
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseTarget("snmp://" + ip.String())
+	if err != nil {
+		return nil, err
+	}
+	t.label = "gateway"
+	return append(targets, t), nil
+}
+
+// ifCounters are the counters of an interface at a point in time.
+type ifCounters struct {
+	time            time.Time
+	in, out, errors uint64
+	wide            bool // 64 bit octet counters
+}
+
+func counterDelta(now, last uint64, wide bool) uint64 {
+	if now >= last {
+		return now - last
+	}
+	if wide {
+		return 0 // reset
+	}
+	return now + snmpCounter32Modulo - last
+}
+
+// interfaceCounters reads the counters of the interface index, with the 64
+// bit octet counters when the agent has them, as the 32 bit ones wrap in
+// seconds on fast links.
+func (c *snmpClient) interfaceCounters(index int) (ifCounters, error) {
+	suffix := "." + strconv.Itoa(index)
+	vars, err := c.request(snmpGetPDU, []string{
+		oidIfHCInOctets + suffix, oidIfHCOutOctets + suffix,
+		oidIfInOctets + suffix, oidIfOutOctets + suffix,
+		oidIfInErrors + suffix, oidIfOutErrors + suffix,
+	})
+	if err != nil {
+		return ifCounters{}, err
+	}
+	if len(vars) != 6 {
+		return ifCounters{}, errors.New("bad SNMP response")
+	}
+	ic := ifCounters{time: time.Now(), errors: vars[4].value + vars[5].value}
+	if vars[0].tag == berCounter64 && vars[1].tag == berCounter64 {
+		ic.in, ic.out, ic.wide = vars[0].value, vars[1].value, true
+	} else if vars[2].tag == berCounter32 {
+		ic.in, ic.out = vars[2].value, vars[3].value
+	} else {
+		return ifCounters{}, fmt.Errorf("no interface %d on %s", index, c.addr)
+	}
+	return ic, nil
+}
+
+// busiestInterface returns the index of the interface that received the
+// most octets, usually the WAN one.
+func (c *snmpClient) busiestInterface() (int, error) {
+	vars, err := c.walk(oidIfInOctets)
+	if err != nil {
+		return 0, err
+	}
+	index, most := 0, uint64(0)
+	for _, v := range vars {
+		if i, err := strconv.Atoi(v.oid[len(oidIfInOctets)+1:]); err == nil && v.value >= most {
+			index, most = i, v.value
+		}
+	}
+	if index == 0 {
+		return 0, fmt.Errorf("no interfaces on %s", c.addr)
+	}
+	return index, nil
+}
+
+// cpuLoad returns the average load of the processors of the agent, or 100
+// minus the idle time.
+func (c *snmpClient) cpuLoad() (int, bool) {
+	if vars, err := c.walk(oidHrProcessorLoad); err == nil && len(vars) > 0 {
+		var sum uint64
+		for _, v := range vars {
+			sum += v.value
+		}
+		return int(sum / uint64(len(vars))), true
+	}
+	if vars, err := c.request(snmpGetPDU, []string{oidSsCPUIdle}); err == nil && len(vars) == 1 && vars[0].tag == berInteger {
+		return 100 - int(vars[0].value), true
+	}
+	return 0, false
+}
+
+// walk returns the variables under prefix, with GETNEXT requests.
+func (c *snmpClient) walk(prefix string) ([]snmpVar, error) {
+	var vars []snmpVar
+	oid := prefix
+	for len(vars) < snmpMaxWalk {
+		next, err := c.request(snmpNextPDU, []string{oid})
+		if err != nil {
+			return vars, err
+		}
+		if len(next) != 1 || !strings.HasPrefix(next[0].oid, prefix+".") || next[0].tag >= 0x80 {
+			break
+		}
+		vars = append(vars, next[0])
+		oid = next[0].oid
+	}
+	return vars, nil
+}
+
+// request sends a request of type pdu for oids and returns the variables of
+// the response.
+func (c *snmpClient) request(pdu byte, oids []string) ([]snmpVar, error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	requestID := int64(binary.BigEndian.Uint32(id) >> 1)
+	var bindings []byte
+	for _, oid := range oids {
+		encoded, err := berEncodeOID(oid)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, berTLV(berSequence, append(encoded, berNull, 0))...)
+	}
+	body := berInt(requestID)
+	body = append(body, berInt(0)...) // error status
+	body = append(body, berInt(0)...) // error index
+	body = append(body, berTLV(berSequence, bindings)...)
+	msg := berInt(1) // version 2c
+	msg = append(msg, berTLV(berOctetString, []byte(c.community))...)
+	msg = append(msg, berTLV(pdu, body)...)
+	msg = berTLV(berSequence, msg)
+
+	conn, err := c.d.Dial("udp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(probeTimeout))
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		vars, id, err := parseSNMPResponse(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		if id == requestID {
+			return vars, nil
+		}
+	}
+}
+
+// parseSNMPResponse parses a response, returning its variables and request
+// ID.
+func parseSNMPResponse(b []byte) ([]snmpVar, int64, error) {
+	bad := errors.New("bad SNMP response")
+	msg, _, ok := berRead(b, berSequence)
+	if !ok {
+		return nil, 0, bad
+	}
+	_, msg, ok = berRead(msg, berInteger) // version
+	if !ok {
+		return nil, 0, bad
+	}
+	_, msg, ok = berRead(msg, berOctetString) // community
+	if !ok {
+		return nil, 0, bad
+	}
+	pdu, _, ok := berRead(msg, snmpRespPDU)
+	if !ok {
+		return nil, 0, bad
+	}
+	var fields [3][]byte
+	for i := range fields {
+		if fields[i], pdu, ok = berRead(pdu, berInteger); !ok {
+			return nil, 0, bad
+		}
+	}
+	id := berUint(fields[0])
+	if status := berUint(fields[1]); status != 0 {
+		return nil, int64(id), fmt.Errorf("SNMP error status %d", status)
+	}
+	list, _, ok := berRead(pdu, berSequence)
+	if !ok {
+		return nil, 0, bad
+	}
+	var vars []snmpVar
+	for len(list) > 0 {
+		var binding []byte
+		if binding, list, ok = berRead(list, berSequence); !ok {
+			return nil, 0, bad
+		}
+		name, rest, ok := berRead(binding, berOID)
+		if !ok || len(rest) < 2 {
+			return nil, 0, bad
+		}
+		value, _, ok := berRead(rest, rest[0])
+		if !ok {
+			return nil, 0, bad
+		}
+		vars = append(vars, snmpVar{oid: berDecodeOID(name), tag: rest[0], value: berUint(value)})
+	}
+	return vars, int64(id), nil
+}
+
+// berTLV encodes a value with its tag and length.
+func berTLV(tag byte, content []byte) []byte {
+	b := []byte{tag}
+	switch n := len(content); {
+	case n < 0x80:
+		b = append(b, byte(n))
+	case n < 0x100:
+		b = append(b, 0x81, byte(n))
+	default:
+		b = append(b, 0x82, byte(n>>8), byte(n))
+	}
+	return append(b, content...)
+}
+
+// berInt encodes a non-negative integer.
+func berInt(v int64) []byte {
+	b := binary.BigEndian.AppendUint64(nil, uint64(v))
+	for len(b) > 1 && b[0] == 0 && b[1]&0x80 == 0 {
+		b = b[1:]
+	}
+	return berTLV(berInteger, b)
+}
+
+// berRead reads a value with the given tag from b, returning its content and
+// what follows it.
+func berRead(b []byte, tag byte) (content, rest []byte, ok bool) {
+	if len(b) < 2 || b[0] != tag {
+		return nil, nil, false
+	}
+	n, off := int(b[1]), 2
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 3 || len(b) < 2+size {
+			return nil, nil, false
+		}
+		n = 0
+		for _, c := range b[2 : 2+size] {
+			n = n<<8 | int(c)
+		}
+		off += size
+	}
+	if off+n > len(b) {
+		return nil, nil, false
+	}
+	return b[off : off+n], b[off+n:], true
+}
+
+// berUint decodes the content of an integer or a counter as unsigned.
+func berUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func berEncodeOID(oid string) ([]byte, error) {
+	parts := strings.Split(oid, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("bad OID %q", oid)
+	}
+	nums := make([]uint64, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad OID %q", oid)
+		}
+		nums[i] = n
+	}
+	content := appendBase128(nil, nums[0]*40+nums[1])
+	for _, n := range nums[2:] {
+		content = appendBase128(content, n)
+	}
+	return berTLV(berOID, content), nil
+}
+
+func appendBase128(b []byte, n uint64) []byte {
+	var digits []byte
+	for {
+		digits = append([]byte{byte(n & 0x7f)}, digits...)
+		if n >>= 7; n == 0 {
+			break
+		}
+	}
+	for i := range digits[:len(digits)-1] {
+		digits[i] |= 0x80
+	}
+	return append(b, digits...)
+}
+
+func berDecodeOID(b []byte) string {
+	var nums []string
+	var n uint64
+	for _, c := range b {
+		n = n<<7 | uint64(c&0x7f)
+		if c&0x80 != 0 {
+			continue
+		}
+		if nums == nil {
+			nums = append(nums, strconv.FormatUint(min(n/40, 2), 10), strconv.FormatUint(n-min(n/40, 2)*40, 10))
+		} else {
+			nums = append(nums, strconv.FormatUint(n, 10))
+		}
+		n = 0
+	}
+	return strings.Join(nums, ".")
+}
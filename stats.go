@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// stats summarizes all the samples of a target in the session.
+type stats struct {
+	sent, lost    int
+	min, max, sum time.Duration
+	// runs of lost probes: isolated losses hurt calls much less than bursts,
+	// which codecs cannot conceal
+	run                 int // probes lost in a row so far
+	isolated, bursts    int
+	burstLost, maxBurst int
+	late                int // replies that arrived after their probe was given up
+	dups                int // replies that arrived twice
+	// the differences between consecutive RTTs, for the jitter of the
+	// whole session once its history is downsampled
+	last      time.Duration // RTT of the latest reply
+	jitterSum time.Duration
+}
+
+func (st *stats) add(s sample) {
+	st.sent++
+	if n, err := strconv.Atoi(s.meta["late"]); err == nil {
+		st.late += n
+	}
+	if n, err := strconv.Atoi(s.meta["dup"]); err == nil {
+		st.dups += n
+	}
+	if s.lost() {
+		st.lost++
+		st.run++
+		return
+	}
+	st.endRun()
+	if st.received() > 1 {
+		st.jitterSum += (s.rtt - st.last).Abs()
+	}
+	st.last = s.rtt
+	if st.received() == 1 || s.rtt < st.min {
+		st.min = s.rtt
+	}
+	if s.rtt > st.max {
+		st.max = s.rtt
+	}
+	st.sum += s.rtt
+}
+
+// endRun counts the run of lost probes that just ended, if any.
+func (st *stats) endRun() {
+	switch {
+	case st.run == 1:
+		st.isolated++
+	case st.run > 1:
+		st.bursts++
+		st.burstLost += st.run
+		st.maxBurst = max(st.maxBurst, st.run)
+	}
+	st.run = 0
+}
+
+// burstText describes the bursts of lost probes.
+func (st stats) burstText() string {
+	if st.bursts == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d, avg %.1f, max %d", st.bursts, float64(st.burstLost)/float64(st.bursts), st.maxBurst)
+}
+
+func (st stats) received() int {
+	return st.sent - st.lost
+}
+
+// loss returns the percentage of lost probes.
+func (st stats) loss() float64 {
+	if st.sent == 0 {
+		return 0
+	}
+	return 100 * float64(st.lost) / float64(st.sent)
+}
+
+func (st stats) avg() time.Duration {
+	if st.received() == 0 {
+		return 0
+	}
+	return st.sum / time.Duration(st.received())
+}
+
+// jitter returns the mean difference between consecutive RTTs, in ms.
+func (st stats) jitter() float64 {
+	if st.received() < 2 {
+		return 0
+	}
+	return ms(st.jitterSum) / float64(st.received()-1)
+}
+
+// failed reports whether the loss or the average RTT are above the
+// thresholds given by the flags.
+func (st stats) failed() bool {
+	c := currentSettings()
+	return (c.lossThreshold > 0 && st.loss() > c.lossThreshold) ||
+		(c.rttThreshold > 0 && st.avg() > c.rttThreshold)
+}
+
+// printSummary writes a table with the stats of every target, and reports
+// whether all of them are within the thresholds.
+func printSummary(w io.Writer, all []*series) bool {
+	ok := true
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	now := time.Now()
+	fmt.Fprintln(tw, "target\tsent\tlost\tloss\tisolated\tbursts\tlate\tdup\tmin\tavg\tmax\tmos\toutages\tdowntime\t")
+	for _, s := range all {
+		st := s.stats
+		st.endRun() // the probes lost so far
+		status := ""
+		if s.target.unit() == "" && st.failed() {
+			status = "FAIL"
+			ok = false
+		}
+		mos := "-"
+		if v, ok := s.sessionMOS(mosPreset()); ok {
+			mos = fmt.Sprintf("%.1f", v)
+		}
+		// no replies have no RTT, rather than 0 ms
+		minRTT, avgRTT, maxRTT := "-", "-", "-"
+		if st.received() > 0 {
+			minRTT, avgRTT, maxRTT = formatValue(s.target, ms(st.min)), formatValue(s.target, ms(st.avg())), formatValue(s.target, ms(st.max))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%d\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", s.target, st.sent, st.lost, st.loss(),
+			st.isolated, st.burstText(), st.late, st.dups, minRTT, avgRTT, maxRTT, mos,
+			len(s.outages), s.downtime(now).Round(time.Second), status)
+	}
+	tw.Flush()
+
+	for _, s := range all {
+		for _, o := range s.outages {
+			end := "ongoing"
+			if !o.end.IsZero() {
+				end = o.end.Format("15:04:05")
+			} else {
+				o.end = now
+			}
+			fmt.Fprintf(w, "outage %s from %s to %s (%s)\n", s.target, o.start.Format("2006-01-02 15:04:05"), end,
+				o.end.Sub(o.start).Round(time.Second))
+		}
+	}
+	return ok
+}
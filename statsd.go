@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var statsdAddr = flag.String("statsd", "",
+	"send samples as metrics to this StatsD or DogStatsD server, e.g. localhost:8125")
+var statsdPrefix = flag.String("statsd-prefix", "netcheck",
+	"prefix of the names of the metrics sent to -statsd")
+
+// statsdSink sends every sample to a StatsD server over UDP: the RTT as a
+// timing, a sent and, when it got no reply, a lost counter, and the MOS as
+// a gauge, tagged with the target and probe type the way DogStatsD and
+// Telegraf read tags.
+type statsdSink struct {
+	conn   net.Conn
+	prefix string
+}
+
+func newStatsdSink(addr, prefix string) (*statsdSink, error) {
+	conn, err := net.Dial("udp", withDefaultPort(addr, "8125"))
+	if err != nil {
+		return nil, err
+	}
+	return &statsdSink{conn: conn, prefix: strings.TrimSuffix(prefix, ".")}, nil
+}
+
+func (k *statsdSink) write(s sample) {
+	// the metrics go in a packet, a line each
+	var b bytes.Buffer
+	tags := fmt.Sprintf("|#target:%s,probe:%s", statsdEscaper.Replace(s.target.String()), s.target.scheme)
+	fmt.Fprintf(&b, "%s.sent:1|c%s\n", k.prefix, tags)
+	if s.lost() {
+		fmt.Fprintf(&b, "%s.lost:1|c%s\n", k.prefix, tags)
+	} else {
+		fmt.Fprintf(&b, "%s.rtt:%g|ms%s\n", k.prefix, ms(s.rtt), tags)
+	}
+	if s.mos > 0 {
+		fmt.Fprintf(&b, "%s.mos:%.2f|g%s\n", k.prefix, s.mos, tags)
+	}
+	// UDP does not block, and a lost packet is only a lost sample
+	k.conn.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+}
+
+func (k *statsdSink) close() error {
+	return k.conn.Close()
+}
+
+// statsdEscaper replaces the characters that delimit metrics and tags.
+var statsdEscaper = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
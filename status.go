@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// statusProbes is how many probes "netcheck status" sends every target.
+	statusProbes   = 3
+	statusInterval = 200 * time.Millisecond
+	// statusSlow is the RTT above which the status is a warning, unless
+	// -rtt-threshold is set.
+	statusSlow = 100 * time.Millisecond
+)
+
+// statusLevels are the classes of a status, as waybar calls them, and their
+// colors in i3blocks and tmux.
+var statusLevels = map[string]struct{ hex, tmux string }{
+	"good":     {"#50fa7b", "green"},
+	"warning":  {"#f1fa8c", "yellow"},
+	"critical": {"#ff5555", "red"},
+}
+
+// runStatus implements "netcheck status [-format f] [target...]": it sends a
+// few probes to every target and prints a line with the worst RTT and loss,
+// for status bars like waybar, i3blocks or tmux that run it periodically.
+func runStatus(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	format := fs.String("format", "text", "output format: text, waybar, i3blocks or tmux")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	switch *format {
+	case "text", "waybar", "i3blocks", "tmux":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown -format %q\n", *format)
+		return 2
+	}
+	targets, err := parseArgs(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	probeInterval = statusInterval
+	results, _ := probeTargets(targets, statusProbes, len(targets), 0)
+
+	// the line shows the slowest target and the highest loss
+	var rtt time.Duration
+	var loss float64
+	down := false
+	var tooltip []string
+	for i, st := range results {
+		if targets[i].unit() == "" {
+			rtt = max(rtt, st.avg())
+		}
+		loss = max(loss, st.loss())
+		down = down || st.received() == 0
+		if st.received() == 0 {
+			tooltip = append(tooltip, fmt.Sprintf("%s: down", targets[i]))
+			continue
+		}
+		tooltip = append(tooltip, fmt.Sprintf("%s: %s %.0f%%", targets[i], formatValue(targets[i], ms(st.avg())), st.loss()))
+	}
+
+	slow, lossy := statusSlow, 0.0
+	if *rttThreshold > 0 {
+		slow = *rttThreshold
+	}
+	if *lossThreshold > 0 {
+		lossy = *lossThreshold
+	}
+	level := "good"
+	switch {
+	case down:
+		level = "critical"
+	case loss > lossy || rtt > slow:
+		level = "warning"
+	}
+	text := fmt.Sprintf("%s %.0f%%", formatRTT(rtt), loss)
+	if down {
+		text = "offline"
+	}
+
+	switch *format {
+	case "text":
+		fmt.Println(text)
+	case "waybar":
+		b, _ := json.Marshal(map[string]string{"text": text, "tooltip": strings.Join(tooltip, "\n"), "class": level})
+		fmt.Println(string(b))
+	case "i3blocks":
+		// full text, short text and color lines
+		fmt.Printf("%s\n%s\n%s\n", text, text, statusLevels[level].hex)
+	case "tmux":
+		fmt.Printf("#[fg=%s]%s#[default]\n", statusLevels[level].tmux, text)
+	}
+	return 0
+}
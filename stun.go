@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"net"
+	"time"
+)
+
+var stunServer = flag.String("stun", "stun.l.google.com:19302",
+	"STUN server telling the public address of this host")
+
+// STUN (RFC 5389) message types and attributes used, with the ones of RFC
+// 5780 to classify NATs.
+const (
+	stunMagicCookie      = 0x2112a442
+	stunBindingRequest   = 0x0001
+	stunBindingResponse  = 0x0101
+	stunMappedAddress    = 0x0001
+	stunChangeRequest    = 0x0003
+	stunXorMappedAddress = 0x0020
+	stunOtherAddress     = 0x802c
+	stunChangeIP         = 0x04
+	stunChangePort       = 0x02
+)
+
+// stunResult is the response of a STUN server to a binding request.
+type stunResult struct {
+	mapped *net.UDPAddr // the address the server saw the request from
+	other  *net.UDPAddr // the alternate address of the server, if it has one
+	rtt    time.Duration
+}
+
+// stunBinding sends a binding request to server from conn, with the change
+// flags of a CHANGE-REQUEST if any, and waits for the response until
+// timeout.
+func stunBinding(conn net.PacketConn, server *net.UDPAddr, change byte, timeout time.Duration) (stunResult, error) {
+	var attrs []byte
+	if change != 0 {
+		attrs = binary.BigEndian.AppendUint16(attrs, stunChangeRequest)
+		attrs = binary.BigEndian.AppendUint16(attrs, 4)
+		attrs = binary.BigEndian.AppendUint32(attrs, uint32(change))
+	}
+	resp, rtt, err := stunRequest(conn, server, stunBindingRequest, attrs, timeout)
+	if err != nil {
+		return stunResult{}, err
+	}
+	r, err := parseSTUN(resp)
+	r.rtt = rtt
+	return r, err
+}
+
+// stunRequest sends a request of type msgType with attrs to server from
+// conn, and returns the response to it and how long it took.
+func stunRequest(conn net.PacketConn, server *net.UDPAddr, msgType uint16, attrs []byte, timeout time.Duration) ([]byte, time.Duration, error) {
+	req := make([]byte, 20, 20+len(attrs))
+	binary.BigEndian.PutUint16(req, msgType)
+	binary.BigEndian.PutUint16(req[2:], uint16(len(attrs)))
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	if _, err := rand.Read(req[8:20]); err != nil {
+		return nil, 0, err
+	}
+	req = append(req, attrs...)
+
+	start := time.Now()
+	if _, err := conn.WriteTo(req, server); err != nil {
+		return nil, 0, err
+	}
+	conn.SetReadDeadline(start.Add(timeout))
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, 0, err
+		}
+		resp := buf[:n]
+		// ignore datagrams that do not answer our transaction
+		if len(resp) < 20 || !bytes.Equal(resp[8:20], req[8:20]) {
+			continue
+		}
+		return resp, time.Since(start), nil
+	}
+}
+
+// parseSTUN parses a binding response.
+func parseSTUN(resp []byte) (stunResult, error) {
+	var r stunResult
+	if binary.BigEndian.Uint16(resp) != stunBindingResponse {
+		return r, errors.New("STUN binding failed")
+	}
+	attrs := resp[20:]
+	if n := int(binary.BigEndian.Uint16(resp[2:])); n <= len(attrs) {
+		attrs = attrs[:n]
+	}
+	for len(attrs) >= 4 {
+		typ, n := binary.BigEndian.Uint16(attrs), int(binary.BigEndian.Uint16(attrs[2:]))
+		if 4+n > len(attrs) {
+			break
+		}
+		value := attrs[4 : 4+n]
+		switch typ {
+		case stunXorMappedAddress:
+			r.mapped = stunAddress(value, resp[4:20])
+		case stunMappedAddress:
+			if r.mapped == nil {
+				r.mapped = stunAddress(value, nil)
+			}
+		case stunOtherAddress:
+			r.other = stunAddress(value, nil)
+		}
+		attrs = attrs[min(4+(n+3)&^3, len(attrs)):] // padded to 4 bytes
+	}
+	if r.mapped == nil {
+		return r, errors.New("STUN response without an address")
+	}
+	return r, nil
+}
+
+// stunAddress decodes an address attribute, XORed with the magic cookie and
+// the transaction ID, in xor, for XOR-MAPPED-ADDRESS.
+func stunAddress(value, xor []byte) *net.UDPAddr {
+	if len(value) < 8 {
+		return nil
+	}
+	port := binary.BigEndian.Uint16(value[2:])
+	ip := net.IP(bytes.Clone(value[4:]))
+	switch {
+	case value[1] == 1 && len(ip) == net.IPv4len:
+	case value[1] == 2 && len(ip) == net.IPv6len:
+	default:
+		return nil
+	}
+	if xor != nil {
+		port ^= stunMagicCookie >> 16
+		for i := range ip {
+			ip[i] ^= xor[i]
+		}
+	}
+	return &net.UDPAddr{IP: ip, Port: int(port)}
+}
+
+// resolveSTUN resolves the address of a STUN server, port 3478 by default.
+func resolveSTUN(server string) (*net.UDPAddr, error) {
+	return net.ResolveUDPAddr("udp4", withDefaultPort(server, "3478"))
+}
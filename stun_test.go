@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// stunTestID is the transaction ID of the responses of the tests.
+var stunTestID = []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+
+func stunMessage(msgType uint16, attrs ...[]byte) []byte {
+	var body []byte
+	for _, a := range attrs {
+		body = append(body, a...)
+	}
+	b := binary.BigEndian.AppendUint16(nil, msgType)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(body)))
+	b = binary.BigEndian.AppendUint32(b, stunMagicCookie)
+	b = append(b, stunTestID...)
+	return append(b, body...)
+}
+
+func stunAttr(typ uint16, value ...byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestParseSTUN(t *testing.T) {
+	// 203.0.113.7:51234, XORed with the magic cookie
+	xorMapped := stunAttr(stunXorMappedAddress, 0, 1, 0xe9, 0x30, 0xea, 0x12, 0xd5, 0x45)
+	mapped := stunAttr(stunMappedAddress, 0, 1, 0x0d, 0x96, 192, 0, 2, 1)
+	tests := []struct {
+		name   string
+		resp   []byte
+		mapped string
+		other  string
+	}{
+		{"xor mapped", stunMessage(stunBindingResponse, xorMapped), "203.0.113.7:51234", "<nil>"},
+		{"mapped", stunMessage(stunBindingResponse, mapped), "192.0.2.1:3478", "<nil>"},
+		{"xor mapped preferred", stunMessage(stunBindingResponse, mapped, xorMapped), "203.0.113.7:51234", "<nil>"},
+		{"after padding", stunMessage(stunBindingResponse, stunAttr(0x8022, 'a', 'b', 'c'), xorMapped), "203.0.113.7:51234", "<nil>"},
+		{"other address", stunMessage(stunBindingResponse, xorMapped, stunAttr(stunOtherAddress, 0, 1, 0x0d, 0x97, 192, 0, 2, 2)),
+			"203.0.113.7:51234", "192.0.2.2:3479"},
+		// 2001:db8::1, XORed with the magic cookie and the transaction ID
+		{"ipv6", stunMessage(stunBindingResponse, stunAttr(stunXorMappedAddress, 0, 2, 0xe9, 0x30,
+			0x01, 0x13, 0xa9, 0xfa, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 13)), "[2001:db8::1]:51234", "<nil>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := parseSTUN(tt.resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.mapped.String(); got != tt.mapped {
+				t.Errorf("mapped = %s, want %s", got, tt.mapped)
+			}
+			if got := r.other.String(); got != tt.other {
+				t.Errorf("other = %s, want %s", got, tt.other)
+			}
+		})
+	}
+}
+
+func TestParseSTUNErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		resp []byte
+	}{
+		{"error response", stunMessage(0x0111, stunAttr(0x0009, 0, 0, 4, 1))},
+		{"no address", stunMessage(stunBindingResponse)},
+		{"bad family", stunMessage(stunBindingResponse, stunAttr(stunXorMappedAddress, 0, 3, 0xe9, 0x30, 1, 2, 3, 4))},
+		{"truncated", stunMessage(stunBindingResponse, stunAttr(stunXorMappedAddress, 0, 1, 0xe9, 0x30, 0xea, 0x12, 0xd5, 0x45)[:8])},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if r, err := parseSTUN(tt.resp); err == nil {
+				t.Errorf("parseSTUN() = %+v, want an error", r)
+			}
+		})
+	}
+}
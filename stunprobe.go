@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// TURN (RFC 8656) allocations, asked for to time TURN servers.
+const (
+	turnAllocateRequest = 0x0003
+	turnTransport       = 0x0019 // REQUESTED-TRANSPORT
+	protocolUDP         = 17
+)
+
+func init() {
+	registerSource("stun", "STUN", "3478", stunSource)
+	registerSource("turn", "TURN", "3478", stunSource)
+}
+
+// stunSource measures the round trip of a STUN binding to t, the exchange
+// WebRTC calls start with, over the path their media then takes, showing
+// the public address the server saw. TURN servers, with turn://, are asked
+// for an allocation instead, which without credentials they refuse after a
+// round trip all the same.
+func stunSource(ctx context.Context, t target, out chan<- sample) error {
+	server, err := net.ResolveUDPAddr("udp", t.address)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(t.source, "0"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var allocate []byte
+	allocate = binary.BigEndian.AppendUint16(allocate, turnTransport)
+	allocate = binary.BigEndian.AppendUint16(allocate, 4)
+	allocate = binary.BigEndian.AppendUint32(allocate, protocolUDP<<24)
+	return probeEvery(ctx, t, out, func(_ int, meta map[string]string) (time.Duration, error) {
+		if t.scheme == "turn" {
+			_, rtt, err := stunRequest(conn, server, turnAllocateRequest, allocate, probeTimeout)
+			return rtt, err
+		}
+		r, err := stunBinding(conn, server, 0, probeTimeout)
+		if err != nil {
+			return 0, err
+		}
+		meta["mapped"] = r.mapped.String()
+		return r.rtt, nil
+	})
+}
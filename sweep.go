@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// sweepInterval separates the probes "netcheck sweep" sends a target.
+const sweepInterval = 200 * time.Millisecond
+
+// runSweep implements "netcheck sweep targets.txt": it sends a few probes to
+// every target of the files, many at once, and prints them sorted by loss
+// and RTT, to find the best server of a list quickly.
+func runSweep(args []string) int {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	count := fs.Int("count", 3, "probes sent to every target")
+	parallel := fs.Int("parallel", 64, "targets probed at once")
+	rate := fs.Float64("rate", 50, "targets started per second, 0 for no limit")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 || *count < 1 || *parallel < 1 || *rate < 0 {
+		fmt.Fprintln(os.Stderr, "usage: netcheck sweep [-count n] [-parallel n] [-rate n] targets.txt...")
+		return 2
+	}
+	var targets []target
+	for _, path := range fs.Args() {
+		t, err := readTargetsFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		targets = append(targets, t...)
+	}
+
+	probeInterval = sweepInterval
+	// every target has a probe in flight at a time, so -parallel rather than
+	// -max-inflight bounds them
+	probes = newScheduler(*parallel)
+	results, errs := probeTargets(targets, *count, *parallel, *rate)
+	printSweep(os.Stdout, targets, results, errs)
+	return 0
+}
+
+// readTargetsFile reads a target per line from path, or from the standard
+// input for "-", skipping blank lines and comments starting with #.
+func readTargetsFile(path string) ([]target, error) {
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var targets []target
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		t, err := parseTargets(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		targets = append(targets, t...)
+	}
+	return targets, scanner.Err()
+}
+
+// probeTargets probes every target count times, at most parallel of them at
+// once and starting rate of them per second, and returns their stats and why
+// the targets without replies failed: the error of their source, or of
+// their last probe.
+func probeTargets(targets []target, count, parallel int, rate float64) ([]stats, []error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var pace <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+	slots := make(chan struct{}, parallel)
+	results := make([]stats, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		if pace != nil && i > 0 {
+			<-pace
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer resetOnPanic()
+			defer func() { <-slots }()
+			defer wg.Done()
+			p := probeTypes[t.scheme].newProbe(t)
+			defer p.Stop()
+			st := &results[i]
+			for smp := range p.Start(ctx) {
+				if smp.err != nil {
+					errs[i] = smp.err
+				}
+				if st.add(smp); st.sent == count {
+					break
+				}
+			}
+			if st.sent < count {
+				// the samples were closed, by a source that failed
+				if f, ok := p.(interface{ Err() error }); ok && f.Err() != nil {
+					errs[i] = f.Err()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return results, errs
+}
+
+// printSweep writes a table of the targets and their results, the ones
+// losing the fewest probes and then the fastest first, and the ones that
+// could not be probed last, with the reason.
+func printSweep(w io.Writer, targets []target, results []stats, errs []error) {
+	order := make([]int, len(targets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, rb := results[order[a]], results[order[b]]
+		if (ra.received() == 0) != (rb.received() == 0) {
+			return rb.received() == 0
+		}
+		if ra.loss() != rb.loss() {
+			return ra.loss() < rb.loss()
+		}
+		return ra.avg() < rb.avg()
+	})
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "target\tsent\tloss\tmin\tavg\tmax\t")
+	for _, i := range order {
+		st := results[i]
+		switch {
+		case st.sent == 0:
+			reason := "cannot probe"
+			if errs[i] != nil {
+				reason += ": " + errs[i].Error()
+			}
+			fmt.Fprintf(tw, "%s\t0\t-\t-\t-\t-\t%s\n", targets[i], reason)
+		case st.received() == 0:
+			reason := ""
+			if errs[i] != nil {
+				reason = errs[i].Error()
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%.0f%%\t-\t-\t-\t%s\n", targets[i], st.sent, st.loss(), reason)
+		default:
+			fmt.Fprintf(tw, "%s\t%d\t%.0f%%\t%s\t%s\t%s\t\n", targets[i], st.sent, st.loss(),
+				formatValue(targets[i], ms(st.min)), formatValue(targets[i], ms(st.avg())), formatValue(targets[i], ms(st.max)))
+		}
+	}
+	tw.Flush()
+}
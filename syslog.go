@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var syslogAddr = flag.String("syslog", "",
+	"log alerts and periodic summaries to syslog: local for the local daemon, udp://host:514 or tcp://host:514 for a remote one, or journald")
+var syslogSummary = flag.Duration("syslog-summary", 5*time.Minute,
+	"how often -syslog gets a summary of every target, 0 disables it")
+
+// Severities of the records, as in syslog.
+const (
+	logWarning = 4
+	logNotice  = 5
+	logInfo    = 6
+)
+
+// logField is a structured field of a record, e.g. target=1.1.1.1.
+type logField struct {
+	key, value string
+}
+
+// logWriter writes records to syslog or journald.
+type logWriter interface {
+	log(severity int, message string, fields []logField) error
+	close() error
+}
+
+// syslogSink logs the alerts, i.e. threshold breaches and outages, and a
+// summary of every target every -syslog-summary, with their details as
+// structured fields, for log pipelines to alert on.
+type syslogSink struct {
+	w     logWriter
+	since time.Time // start of the current summary
+	sums  map[target]*stats
+	order []target
+}
+
+func newSyslogSink(addr string) (*syslogSink, error) {
+	var w logWriter
+	var err error
+	if addr == "journald" {
+		w, err = dialJournal()
+	} else {
+		w, err = dialSyslog(addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w, sums: map[target]*stats{}}, nil
+}
+
+func (k *syslogSink) write(s sample) {
+	if *syslogSummary <= 0 || s.target.scheme == "delta" {
+		return
+	}
+	if k.since.IsZero() {
+		k.since = s.time
+	}
+	if s.time.Sub(k.since) >= *syslogSummary {
+		k.summarize()
+		k.since = s.time
+	}
+	st, ok := k.sums[s.target]
+	if !ok {
+		st = &stats{}
+		k.sums[s.target] = st
+		k.order = append(k.order, s.target)
+	}
+	st.add(s)
+}
+
+// summarize logs the stats of every target since the last summary.
+func (k *syslogSink) summarize() {
+	for _, t := range k.order {
+		st := k.sums[t]
+		if st.sent == 0 {
+			continue
+		}
+		message := fmt.Sprintf("summary %s: %d of %d probes lost (%.1f%%), avg %s, max %s", t, st.lost, st.sent,
+			st.loss(), formatRTT(st.avg()), formatRTT(st.max))
+		k.w.log(logInfo, message, []logField{
+			{"target", t.String()}, {"probe", t.scheme}, {"sent", strconv.Itoa(st.sent)},
+			{"lost", strconv.Itoa(st.lost)}, {"loss", strconv.FormatFloat(st.loss(), 'f', 1, 64)},
+			{"avg_ms", strconv.FormatFloat(ms(st.avg()), 'f', 1, 64)},
+			{"max_ms", strconv.FormatFloat(ms(st.max), 'f', 1, 64)},
+		})
+		*st = stats{}
+	}
+}
+
+func (k *syslogSink) writeAlert(a alert) {
+	severity, state := logWarning, "firing"
+	if a.resolved {
+		severity, state = logNotice, "resolved"
+	}
+	k.w.log(severity, a.text, []logField{
+		{"alert", a.name}, {"state", state}, {"target", a.target.String()}, {"probe", a.target.scheme},
+	})
+}
+
+func (k *syslogSink) close() error {
+	if *syslogSummary > 0 {
+		k.summarize()
+	}
+	return k.w.close()
+}
+
+// journalSocket is where journald receives records with its native
+// protocol, which keeps their fields.
+const journalSocket = "/run/systemd/journal/socket"
+
+type journalWriter struct {
+	conn net.Conn
+}
+
+func dialJournal() (*journalWriter, error) {
+	conn, err := net.Dial("unixgram", journalSocket)
+	if err != nil {
+		return nil, err
+	}
+	return &journalWriter{conn}, nil
+}
+
+// log sends a record as KEY=value lines, with the field names in upper case
+// as journald wants them, e.g. TARGET=1.1.1.1.
+func (w *journalWriter) log(severity int, message string, fields []logField) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "MESSAGE=%s\nPRIORITY=%d\nSYSLOG_IDENTIFIER=netcheck\n", strings.ReplaceAll(message, "\n", " "), severity)
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%s=%s\n", strings.ToUpper(f.key), strings.ReplaceAll(f.value, "\n", " "))
+	}
+	_, err := w.conn.Write([]byte(b.String()))
+	return err
+}
+
+func (w *journalWriter) close() error {
+	return w.conn.Close()
+}
+
+// logfmt appends the fields to a syslog message as key=value pairs, which
+// log pipelines parse.
+func logfmt(message string, fields []logField) string {
+	var b strings.Builder
+	b.WriteString(message)
+	for _, f := range fields {
+		value := f.value
+		if value == "" || strings.ContainsAny(value, " \"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %s=%s", f.key, value)
+	}
+	return b.String()
+}
@@ -0,0 +1,46 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+)
+
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+// dialSyslog connects to the local syslog daemon, or to a remote one at a
+// udp:// or tcp:// address.
+func dialSyslog(addr string) (*syslogWriter, error) {
+	network, raddr := "", ""
+	if addr != "local" {
+		u, err := url.Parse(addr)
+		if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") {
+			return nil, fmt.Errorf("syslog address %q must be local, journald, udp://host:port or tcp://host:port", addr)
+		}
+		network, raddr = u.Scheme, withDefaultPort(u.Host, "514")
+	}
+	w, err := syslog.Dial(network, raddr, syslog.LOG_DAEMON|syslog.LOG_INFO, "netcheck")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogWriter{w}, nil
+}
+
+func (w *syslogWriter) log(severity int, message string, fields []logField) error {
+	message = logfmt(message, fields)
+	switch severity {
+	case logWarning:
+		return w.w.Warning(message)
+	case logNotice:
+		return w.w.Notice(message)
+	}
+	return w.w.Info(message)
+}
+
+func (w *syslogWriter) close() error {
+	return w.w.Close()
+}
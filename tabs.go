@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buger/goterm"
+	"github.com/fatih/color"
+)
+
+// tab is a view of the display, chosen with the number keys or Tab.
+type tab int
+
+const (
+	tabGraphs tab = iota
+	tabStats
+	tabEvents
+	tabHops
+	tabConfig
+)
+
+var tabNames = []string{"Graphs", "Stats", "Events", "Hops", "Config"}
+
+// traceMaxHops is the highest TTL the Hops view tries.
+const traceMaxHops = 30
+
+// switchTab shows tb, starting a trace of the selected target when it is the
+// hops view.
+func (sc *screen) switchTab(tb tab) {
+	sc.tab = tb
+	sc.eventsScroll = 0
+	sc.clear = true
+	if tb == tabHops && (sc.trace == nil || sc.trace.target != sc.all[sc.selected].target) {
+		sc.trace = startTrace(sc.all[sc.selected].target)
+	}
+}
+
+// tabBar renders the names of the views, the current one highlighted.
+func (sc *screen) tabBar() string {
+	var b strings.Builder
+	for i, name := range tabNames {
+		label := fmt.Sprintf(" %d %s ", i+1, name)
+		if tab(i) == sc.tab {
+			label = color.New(color.ReverseVideo).Sprint(label)
+		}
+		b.WriteString(label)
+	}
+	return b.String()
+}
+
+// eventsPage is how many events the current view shows.
+func (sc *screen) eventsPage() int {
+	if sc.tab == tabEvents {
+		return max(goterm.Height()-8, eventsShown)
+	}
+	return eventsShown
+}
+
+// drawEvents shows a page of the event log, ending eventsScroll events
+// before the latest one.
+func (sc *screen) drawEvents() {
+	n := events.len()
+	if n == 0 {
+		fmt.Fprintln(&frame, "No events yet\033[K")
+		return
+	}
+	fmt.Fprintf(&frame, "Events (%d of %d, ↑/↓ to scroll):\033[K\n", n-sc.eventsScroll, n)
+	for i, e := range events.window(sc.eventsScroll, sc.eventsPage()) {
+		fmt.Fprintf(&frame, "%s\033[K\n", e)
+		sc.eventRows = append(sc.eventRows, eventRow{sc.top + 1 + i, e.time})
+	}
+}
+
+// drawStats shows the summary printed on exit, so far.
+func (sc *screen) drawStats() {
+	var b bytes.Buffer
+	printSummary(&b, sc.all)
+	if hints := diagnose(sc.all, time.Now()); len(hints) > 0 {
+		fmt.Fprintln(&b, "\nDiagnosis:")
+		for _, h := range hints {
+			fmt.Fprintf(&b, "  %s\n", h)
+		}
+	}
+	printLines(b.String())
+}
+
+// drawConfig shows the targets and the value of every flag, the ones given
+// on the command line first.
+func (sc *screen) drawConfig() {
+	fmt.Fprintln(&frame, "Targets:\033[K")
+	for _, s := range sc.all {
+		fmt.Fprintf(&frame, "  %s %s\033[K\n", probeNames[s.target.scheme], s.target)
+	}
+	fmt.Fprintln(&frame, "\033[K")
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "Options given:")
+	flag.Visit(func(f *flag.Flag) { fmt.Fprintf(&b, "  -%s %s\n", f.Name, f.Value) })
+	fmt.Fprintln(&b, "\nDefaults:")
+	flag.VisitAll(func(f *flag.Flag) {
+		if !set[f.Name] {
+			fmt.Fprintf(&b, "  -%s %s\n", f.Name, f.Value)
+		}
+	})
+	printLines(b.String())
+}
+
+// printLines prints text clearing the rest of every line, as what was drawn
+// before may have been wider.
+func printLines(text string) {
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		fmt.Fprintf(&frame, "%s\033[K\n", line)
+	}
+}
+
+// trace is a traceroute to the host of a target, filled in as it runs.
+type trace struct {
+	target target
+	until  func(traceHop) bool // stops the trace early when it returns true
+	mu     sync.Mutex
+	hops   []traceHop
+	done   bool
+	err    error
+}
+
+// traceHop is the router, or the host itself, replying at a TTL.
+type traceHop struct {
+	ttl  int
+	addr string // empty when nothing replied
+	rtt  time.Duration
+}
+
+// startTrace traces the path to the host of t with ICMP echo requests of
+// increasing TTL, from the same interface as its probes.
+func startTrace(t target) *trace {
+	tr := &trace{target: t}
+	go func() {
+		defer resetOnPanic()
+		err := tr.run(context.Background())
+		tr.mu.Lock()
+		tr.done, tr.err = true, err
+		tr.mu.Unlock()
+	}()
+	return tr
+}
+
+func (tr *trace) run(ctx context.Context) error {
+	if probeTypes[tr.target.scheme].opaque {
+		return fmt.Errorf("%s targets have no host to trace", probeNames[tr.target.scheme])
+	}
+	host := hostOf(tr.target)
+	for ttl := 1; ttl <= traceMaxHops && ctx.Err() == nil; ttl++ {
+		p, err := newPinger(target{scheme: "icmp", address: host, source: tr.target.source, ttl: ttl})
+		if err != nil {
+			return err
+		}
+		hop := traceHop{ttl: ttl}
+		rtt, err := p.ping(ttl, probeTimeout)
+		p.conn.Close()
+		if err == nil {
+			hop.rtt = rtt
+			hop.addr = host
+			if p.hop != nil {
+				hop.addr = addrIP(p.hop)
+			}
+		}
+		tr.mu.Lock()
+		tr.hops = append(tr.hops, hop)
+		tr.mu.Unlock()
+		if err == nil && p.hop == nil {
+			return nil // the host itself replied
+		}
+		if tr.until != nil && tr.until(hop) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// drawHops shows the trace to the selected target.
+func (sc *screen) drawHops() {
+	tr := sc.trace
+	if tr == nil {
+		return
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	status := "tracing..."
+	if tr.done {
+		status = "done, r to trace again"
+	}
+	fmt.Fprintf(&frame, "Path to %s (%s):\033[K\n", tr.target, status)
+	for _, h := range tr.hops {
+		if h.addr == "" {
+			fmt.Fprintf(&frame, "%3d  *\033[K\n", h.ttl)
+			continue
+		}
+		addr := h.addr
+		if name, ok := hostnameOf(h.addr); ok {
+			addr = fmt.Sprintf("%s (%s)", name, h.addr)
+		}
+		asn, _ := asnOf(h.addr)
+		fmt.Fprintf(&frame, "%3d  %-60s %-10s %s\033[K\n", h.ttl, addr, formatRTT(h.rtt), asn)
+	}
+	if tr.err != nil {
+		fmt.Fprintf(&frame, "%s\033[K\n", tr.err)
+	}
+}
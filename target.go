@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// target is a host to probe, given on the command line as "address" for ICMP
+// or "scheme://address" for other probe types. Several comma separated
+// schemes, as in "icmp,tcp,dns://1.1.1.1", probe the same host in several
+// ways, making one target per scheme in the same group. An "@iface" suffix,
+// as in "1.1.1.1@en0", sends the probes from the given interface, and ICMP
+// options follow as a query, as in "1.1.1.1?size=1400&ttl=10".
+type target struct {
+	scheme  string
+	address string
+	label   string // optional description shown next to the address
+	group   string // the address as given, shared by targets probing the same host
+	iface   string // interface the probes go out from, if not the default route
+	source  string // local address of iface
+	size    int    // ICMP payload size, overriding -size
+	ttl     int    // ICMP TTL, overriding -ttl
+}
+
+// probeNames are the caption prefixes of every registered probe type, and of
+// derived series.
+var probeNames = map[string]string{
+	"delta": "UPSTREAM",
+}
+
+// parseTargets parses a command line argument into the targets it names.
+func parseTargets(s string) ([]target, error) {
+	schemes, address := "icmp", s
+	if i := strings.Index(s, "://"); i >= 0 {
+		schemes, address = s[:i], s[i+3:]
+	}
+	if pt, ok := probeTypes[schemes]; ok && pt.opaque {
+		t, err := parseTarget(s)
+		return []target{t}, err
+	}
+
+	group := address
+	var options url.Values
+	if i := strings.Index(address, "?"); i >= 0 {
+		var err error
+		if options, err = url.ParseQuery(address[i+1:]); err != nil {
+			return nil, fmt.Errorf("bad options in target %q: %v", s, err)
+		}
+		address = address[:i]
+	}
+	var iface string
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		address, iface = address[:i], address[i+1:]
+	}
+
+	var targets []target
+	for _, scheme := range strings.Split(schemes, ",") {
+		t, err := parseTarget(scheme + "://" + address)
+		if err != nil {
+			return nil, err
+		}
+		if iface != "" {
+			if t, err = bindInterface(t, iface); err != nil {
+				return nil, err
+			}
+		}
+		for name, dst := range map[string]*int{"size": &t.size, "ttl": &t.ttl} {
+			if v := options.Get(name); v != "" {
+				if *dst, err = strconv.Atoi(v); err != nil || *dst <= 0 {
+					return nil, fmt.Errorf("bad %s in target %q", name, s)
+				}
+			}
+		}
+		t.group = group
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func parseTarget(s string) (target, error) {
+	t := target{scheme: "icmp", address: s}
+	if i := strings.Index(s, "://"); i >= 0 {
+		t.scheme, t.address = s[:i], s[i+3:]
+	}
+	if t.address == "" {
+		return target{}, fmt.Errorf("empty address in target %q", s)
+	}
+	t.group = t.address
+
+	pt, ok := probeTypes[t.scheme]
+	if !ok {
+		return target{}, fmt.Errorf("unknown probe type %q in target %q", t.scheme, s)
+	}
+	if pt.opaque {
+		return t, nil
+	}
+	if pt.port != "" {
+		t.address = withDefaultPort(t.address, pt.port)
+	} else if host, _, err := net.SplitHostPort(t.address); err == nil {
+		// a port is meaningless, but comes along when grouped with other probes
+		t.address = host
+	}
+	return t, nil
+}
+
+func (t target) String() string {
+	s := t.address
+	if t.scheme != "icmp" && t.scheme != "delta" {
+		s = t.scheme + "://" + s
+	}
+	if t.iface != "" {
+		s += "@" + t.iface
+	}
+	if t.scheme == "icmp" {
+		var options []string
+		if t.size > 0 {
+			options = append(options, fmt.Sprintf("size=%d", t.size))
+		}
+		if t.ttl > 0 {
+			options = append(options, fmt.Sprintf("ttl=%d", t.ttl))
+		}
+		if options != nil {
+			s += "?" + strings.Join(options, "&")
+		}
+	}
+	return s
+}
+
+func withDefaultPort(address, port string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), port)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+func init() {
+	registerSource("tcp", "TCP", "443", tcpSource)
+}
+
+// tcpSource measures how long it takes to open a TCP connection to t, i.e.
+// the SYN, SYN-ACK round trip plus the time the server takes to accept.
+func tcpSource(ctx context.Context, t target, out chan<- sample) error {
+	return probeEvery(ctx, t, out, func(int, map[string]string) (time.Duration, error) {
+		d, err := t.streamDialer(probeTimeout)
+		if err != nil {
+			return 0, err
+		}
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		start := time.Now()
+		conn, err := d.DialContext(ctx, "tcp", t.address)
+		if err != nil {
+			return 0, err
+		}
+		rtt := time.Since(start)
+		conn.Close()
+		return rtt, nil
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// fullScreen is set while the graphs are drawn on the alternate screen.
+var fullScreen bool
+
+// enterFullScreen switches to the alternate screen and hides the cursor, so
+// the graphs do not flicker and the terminal is left as it was on exit.
+func enterFullScreen() {
+	fmt.Print("\033[?1049h\033[?25l")
+	fullScreen = true
+}
+
+// mouseReporting is set while the terminal reports mouse events.
+var mouseReporting bool
+
+// enableMouse asks the terminal to report clicks and the wheel, in the SGR
+// format that readKeys parses.
+func enableMouse() {
+	fmt.Print("\033[?1000h\033[?1006h")
+	mouseReporting = true
+}
+
+// resetTerminal resets colors, shows the cursor, leaves the alternate screen
+// and undoes enableCbreak and enableMouse. It is safe to call more than
+// once.
+func resetTerminal() {
+	if mouseReporting {
+		fmt.Print("\033[?1006l\033[?1000l")
+		mouseReporting = false
+	}
+	if fullScreen {
+		fmt.Print("\033[0m\033[?25h\033[?1049l")
+		fullScreen = false
+	}
+	restoreTerminal()
+}
+
+// resetOnPanic resets the terminal before a panic ends the program. As a
+// panic cannot be recovered from another goroutine, it is deferred first by
+// every goroutine that runs while the graphs are shown.
+func resetOnPanic() {
+	if r := recover(); r != nil {
+		resetTerminal()
+		panic(r)
+	}
+}
+
+// exitHooks run on exit, after the terminal is reset, e.g. to write reports.
+var exitHooks []func()
+
+// exit ends the program after resetting the terminal, flushing the sinks and
+// running the exit hooks.
+func exit(code int) {
+	resetTerminal()
+	closeSinks()
+	for _, hook := range exitHooks {
+		hook()
+	}
+	os.Exit(code)
+}
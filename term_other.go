@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package main
+
+func enableCbreak() bool { return false }
+
+func restoreTerminal() {}
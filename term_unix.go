@@ -0,0 +1,45 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var savedTermios *syscall.Termios
+
+// enableCbreak makes stdin deliver key presses as they happen without
+// echoing them. Signals like Ctrl-C keep working.
+func enableCbreak() bool {
+	fd := os.Stdin.Fd()
+	var t syscall.Termios
+	if err := ioctlTermios(fd, ioctlGetTermios, &t); err != nil {
+		return false // not a terminal
+	}
+	saved := t
+	t.Lflag &^= syscall.ICANON | syscall.ECHO
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	if err := ioctlTermios(fd, ioctlSetTermios, &t); err != nil {
+		return false
+	}
+	savedTermios = &saved
+	return true
+}
+
+// restoreTerminal undoes enableCbreak.
+func restoreTerminal() {
+	if savedTermios != nil {
+		ioctlTermios(os.Stdin.Fd(), ioctlSetTermios, savedTermios)
+	}
+}
+
+func ioctlTermios(fd uintptr, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
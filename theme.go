@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var themeName = flag.String("theme", "dark",
+	`colors for the terminal background: dark, light, solarized, or custom for the "theme" of the config`)
+var noColor = flag.Bool("no-color", false,
+	"do not use colors, also disabled by the NO_COLOR environment variable")
+
+// colorSpec is a color as escape sequence attributes, e.g. 38, 5, 136 for a
+// color of the 256 color palette.
+type colorSpec []color.Attribute
+
+// theme is the set of colors of the display.
+type theme struct {
+	text   colorSpec
+	warn   colorSpec   // targets needing attention, and lost probes
+	series []colorSpec // used in turn for the graph of every target
+	scale  []colorSpec // from good to bad, e.g. for the heatmap
+}
+
+// palette256 is a color of the 256 color palette.
+func palette256(n int) colorSpec {
+	return colorSpec{38, 5, color.Attribute(n)}
+}
+
+var themes = map[string]theme{
+	"dark": {
+		text:   colorSpec{color.FgWhite},
+		warn:   colorSpec{color.FgRed},
+		series: []colorSpec{{color.FgCyan}, {color.FgMagenta}, {color.FgYellow}, {color.FgGreen}, {color.FgBlue}, {color.FgRed}},
+		scale:  []colorSpec{{color.FgGreen}, {color.FgCyan}, {color.FgYellow}, {color.FgMagenta}, {color.FgRed}},
+	},
+	"light": {
+		text:   colorSpec{color.FgBlack},
+		warn:   colorSpec{color.FgRed},
+		series: []colorSpec{{color.FgBlue}, {color.FgMagenta}, {color.FgGreen}, {color.FgHiBlack}, {color.FgCyan}, {color.FgRed}},
+		scale:  []colorSpec{{color.FgGreen}, {color.FgCyan}, {color.FgBlue}, {color.FgMagenta}, {color.FgRed}},
+	},
+	"solarized": {
+		text:   palette256(244),
+		warn:   palette256(160),
+		series: []colorSpec{palette256(33), palette256(125), palette256(136), palette256(64), palette256(61), palette256(37)},
+		scale:  []colorSpec{palette256(64), palette256(37), palette256(136), palette256(166), palette256(160)},
+	},
+}
+
+// activeTheme is the theme given by -theme.
+var activeTheme = themes["dark"]
+
+// themeConfig is a custom theme in the config, with colors named like
+// "cyan" or "hi-blue", or given as numbers of the 256 color palette.
+type themeConfig struct {
+	Text   string   `json:"text"`
+	Warn   string   `json:"warn"`
+	Series []string `json:"series"`
+	Scale  []string `json:"scale"`
+}
+
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+func parseColor(s string) (colorSpec, error) {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < 256 {
+		return palette256(n), nil
+	}
+	base := color.FgBlack
+	name := strings.ToLower(s)
+	if after, ok := strings.CutPrefix(name, "hi-"); ok {
+		base, name = color.FgHiBlack, after
+	}
+	for i, n := range colorNames {
+		if n == name {
+			return colorSpec{base + color.Attribute(i)}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown color %q", s)
+}
+
+func parseColors(names []string) ([]colorSpec, error) {
+	var specs []colorSpec
+	for _, name := range names {
+		c, err := parseColor(name)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, c)
+	}
+	return specs, nil
+}
+
+// custom returns a theme with the colors of tc, the dark theme filling in
+// the missing ones.
+func (tc themeConfig) custom() (theme, error) {
+	th := themes["dark"]
+	var err error
+	if tc.Text != "" {
+		if th.text, err = parseColor(tc.Text); err != nil {
+			return th, err
+		}
+	}
+	if tc.Warn != "" {
+		if th.warn, err = parseColor(tc.Warn); err != nil {
+			return th, err
+		}
+	}
+	if len(tc.Series) > 0 {
+		if th.series, err = parseColors(tc.Series); err != nil {
+			return th, err
+		}
+	}
+	if len(tc.Scale) > 0 {
+		if len(tc.Scale) != len(th.scale) {
+			return th, fmt.Errorf("the scale needs %d colors", len(th.scale))
+		}
+		if th.scale, err = parseColors(tc.Scale); err != nil {
+			return th, err
+		}
+	}
+	return th, nil
+}
+
+// setupTheme applies -theme and -no-color. The color package already
+// honors NO_COLOR.
+func setupTheme() error {
+	if *noColor {
+		color.NoColor = true
+	}
+	if *themeName == "custom" {
+		if settings.Theme == nil {
+			return fmt.Errorf(`-theme custom needs a "theme" in the config`)
+		}
+		th, err := settings.Theme.custom()
+		if err != nil {
+			return fmt.Errorf("theme: %v", err)
+		}
+		activeTheme = th
+		return nil
+	}
+	th, ok := themes[*themeName]
+	if !ok {
+		return fmt.Errorf("unknown -theme %q", *themeName)
+	}
+	activeTheme = th
+	return nil
+}
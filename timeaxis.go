@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var timeAxisMode = flag.String("time-axis", "relative",
+	"time labels under the graphs: relative to the latest sample, clock for wall-clock time, or none")
+
+// timeAxis returns a row of time labels for the graph columns, where times[i]
+// is the time of the sample in column i, or zero if there is none. Relative
+// labels count back from now.
+func timeAxis(times []time.Time, now time.Time, clock bool) []rune {
+	row := make([]rune, len(times))
+	for i := range row {
+		row[i] = ' '
+	}
+
+	for col := 0; col < len(times); col++ {
+		if times[col].IsZero() {
+			continue
+		}
+		label := fmt.Sprintf("└%s", times[col].Format("15:04:05"))
+		if !clock {
+			label = fmt.Sprintf("└-%s", now.Sub(times[col]).Round(time.Second))
+		}
+		runes := []rune(label)
+		if col+len(runes) > len(row) {
+			break
+		}
+		copy(row[col:], runes)
+		// leave room between labels
+		col += len(runes) + 2
+	}
+	return row
+}
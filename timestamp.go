@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func init() {
+	registerSource("icmp-ts", "TIMESTAMP", "", timestampSource)
+}
+
+// errNonStandardTime is returned for hosts that answer timestamp requests
+// with a time that is not milliseconds since midnight UTC (RFC 792).
+var errNonStandardTime = errors.New("non-standard timestamp")
+
+// timestampSource sends ICMP timestamp requests to t. Besides the RTT, the
+// receive and transmit times of the host split it into the upstream and
+// downstream delays, reported in the up_ms and down_ms metadata. They are only
+// as accurate as the clocks of both ends, so this is meant to spot asymmetry
+// against NTP synchronized hosts. It needs a raw socket, i.e. root or
+// CAP_NET_RAW, as unprivileged ICMP sockets only allow echo requests.
+func timestampSource(ctx context.Context, t target, out chan<- sample) error {
+	ip, err := net.ResolveIPAddr("ip4", t.address)
+	if err != nil {
+		return err
+	}
+	conn, err := icmp.ListenPacket("ip4:icmp", t.source)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	id := rand.Intn(1 << 16)
+	return probeEvery(ctx, t, out, func(seq int, meta map[string]string) (time.Duration, error) {
+		rtt, up, down, err := icmpTimestamp(conn, ip, id, seq&0xffff, probeTimeout)
+		if err == nil {
+			meta["up_ms"] = strconv.FormatInt(up.Milliseconds(), 10)
+			meta["down_ms"] = strconv.FormatInt(down.Milliseconds(), 10)
+		}
+		if err == errNonStandardTime {
+			err = nil
+		}
+		return rtt, err
+	})
+}
+
+// icmpTimestamp sends a timestamp request and returns the RTT, and the one
+// way delays unless the host does not use standard times.
+func icmpTimestamp(conn *icmp.PacketConn, dst net.Addr, id, seq int, timeout time.Duration) (rtt, up, down time.Duration, err error) {
+	body := make([]byte, 16)
+	binary.BigEndian.PutUint16(body[0:], uint16(id))
+	binary.BigEndian.PutUint16(body[2:], uint16(seq))
+	start := time.Now()
+	binary.BigEndian.PutUint32(body[4:], sinceMidnight(start))
+	msg := icmp.Message{Type: ipv4.ICMPTypeTimestamp, Body: &icmp.RawBody{Data: body}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	conn.SetReadDeadline(start.Add(timeout))
+	if _, err := conn.WriteTo(b, dst); err != nil {
+		return 0, 0, 0, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		now := time.Now()
+		reply, err := icmp.ParseMessage(1, buf[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeTimestampReply {
+			continue
+		}
+		raw, ok := reply.Body.(*icmp.RawBody)
+		if !ok || len(raw.Data) < 16 ||
+			binary.BigEndian.Uint16(raw.Data[0:]) != uint16(id) || binary.BigEndian.Uint16(raw.Data[2:]) != uint16(seq) {
+			continue
+		}
+
+		rtt = now.Sub(start)
+		received := binary.BigEndian.Uint32(raw.Data[8:])
+		transmitted := binary.BigEndian.Uint32(raw.Data[12:])
+		if received&(1<<31) != 0 || transmitted&(1<<31) != 0 {
+			return rtt, 0, 0, errNonStandardTime
+		}
+		up = time.Duration(int32(received-sinceMidnight(start))) * time.Millisecond
+		down = time.Duration(int32(sinceMidnight(now)-transmitted)) * time.Millisecond
+		return rtt, up, down, nil
+	}
+}
+
+// sinceMidnight is the ICMP timestamp of t: milliseconds since midnight UTC.
+func sinceMidnight(t time.Time) uint32 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return uint32(t.Sub(midnight).Milliseconds())
+}
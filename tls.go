@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"net"
+	"strconv"
+	"time"
+)
+
+var certWarnDays = flag.Int("cert-warn", 14,
+	"warn when the certificate of a tls:// target expires in fewer days than this")
+
+func init() {
+	registerSource("tls", "TLS", "443", tlsSource)
+}
+
+// tlsSource measures the TLS handshake time with t, excluding the TCP
+// connection setup, and reports how many days are left before the server
+// certificate expires in the cert_days metadata.
+func tlsSource(ctx context.Context, t target, out chan<- sample) error {
+	host, _, err := net.SplitHostPort(t.address)
+	if err != nil {
+		return err
+	}
+
+	return probeEvery(ctx, t, out, func(_ int, meta map[string]string) (time.Duration, error) {
+		d, err := t.streamDialer(0)
+		if err != nil {
+			return 0, err
+		}
+		rtt, notAfter, err := tlsHandshake(ctx, d, t.address, host, probeTimeout)
+		if err != nil {
+			return 0, err
+		}
+		meta["cert_days"] = strconv.Itoa(int(time.Until(notAfter).Hours() / 24))
+		return rtt, nil
+	})
+}
+
+func tlsHandshake(ctx context.Context, d contextDialer, address, serverName string, timeout time.Duration) (time.Duration, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return 0, time.Time{}, err
+	}
+	rtt := time.Since(start)
+
+	return rtt, tlsConn.ConnectionState().PeerCertificates[0].NotAfter, nil
+}
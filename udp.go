@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+)
+
+func init() {
+	registerSource("udp", "UDP", "33434", udpSource)
+}
+
+// udpSource sends a datagram to t and measures how long the first answer
+// takes: any datagram back, as from an echo server that does not check
+// signatures, or the ICMP port unreachable of a closed port, which the
+// default traceroute port is likely to be. Unlike echo://, it works against
+// any host, though hosts rate limit their port unreachable messages.
+func udpSource(ctx context.Context, t target, out chan<- sample) error {
+	conn, err := t.dialer("udp", 0).Dial("udp", t.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	payload := []byte("netcheck")
+	buf := make([]byte, 1500)
+	return probeEvery(ctx, t, out, func(_ int, meta map[string]string) (time.Duration, error) {
+		start := time.Now()
+		conn.SetDeadline(start.Add(probeTimeout))
+		if _, err := conn.Write(payload); err != nil {
+			return 0, err
+		}
+		_, err := conn.Read(buf)
+		rtt := time.Since(start)
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			meta["reply"] = "port unreachable"
+			return rtt, nil
+		}
+		return rtt, err
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ms converts d to fractional milliseconds, the unit of the graphs.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// formatRTT picks the unit of d so that LAN latencies below a millisecond
+// keep their precision: µs below 1 ms, ms with a decimal below 10 ms.
+func formatRTT(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return fmt.Sprintf("%d µs", d.Microseconds())
+	case d < 10*time.Millisecond:
+		return fmt.Sprintf("%.1f ms", ms(d))
+	default:
+		return fmt.Sprintf("%d ms", d.Milliseconds())
+	}
+}
+
+// formatValue formats a value of t in ms, with the unit of t when it is not
+// an RTT.
+func formatValue(t target, v float64) string {
+	if t.unit() == "" {
+		return formatMs(v)
+	}
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64) + " " + t.unit()
+}
+
+// formatMs is formatRTT for values in milliseconds.
+func formatMs(v float64) string {
+	return formatRTT(time.Duration(v * float64(time.Millisecond)))
+}
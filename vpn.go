@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"strings"
+)
+
+var splitVPN = flag.Bool("vpn", true,
+	"when a VPN is up and no targets are given, compare pinging CloudFlare through the VPN and directly")
+
+// vpnPrefixes are the usual names of the tunnel interfaces of VPN clients.
+var vpnPrefixes = []string{"utun", "tun", "tap", "wg", "ppp", "ipsec", "tailscale", "nordlynx", "zt"}
+
+// detectVPN returns the name and IPv4 address of the first VPN interface
+// that is up.
+func detectVPN() (string, net.IP, bool) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", nil, false
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || !isVPNInterface(iface.Name) {
+			continue
+		}
+		if ip := interfaceIPv4(iface); ip != nil {
+			return iface.Name, ip, true
+		}
+	}
+	return "", nil, false
+}
+
+func isVPNInterface(name string) bool {
+	for _, prefix := range vpnPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// localInterface returns the name and IPv4 address of the interface on the
+// same subnet as ip, e.g. the physical interface reaching the gateway.
+func localInterface(ip net.IP) (string, net.IP, bool) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", nil, false
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if n, ok := addr.(*net.IPNet); ok && n.IP.To4() != nil && n.Contains(ip) {
+				return iface.Name, n.IP, true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// splitPathTargets returns two targets pinging address, one from the VPN
+// interface and one from the physical interface reaching the gateway, or
+// nothing if there is no VPN.
+func splitPathTargets(address string, gatewayIP net.IP) []target {
+	vpnName, vpnIP, ok := detectVPN()
+	if !ok {
+		return nil
+	}
+	physName, physIP, ok := localInterface(gatewayIP)
+	if !ok {
+		return nil
+	}
+
+	vpn := target{scheme: "icmp", address: address, iface: vpnName, source: vpnIP.String(), label: "via VPN"}
+	direct := target{scheme: "icmp", address: address, iface: physName, source: physIP.String(), label: "direct"}
+	vpn.group, direct.group = vpn.String(), direct.String()
+	return []target{vpn, direct}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+var warmup = flag.Int("warmup", 5,
+	"number of probes sent in a quick burst when a target starts, so its graph and stats fill in at once")
+
+// warmupInterval separates the probes of the warm-up burst.
+const warmupInterval = 100 * time.Millisecond
+
+// warmingUp returns the interval after probe seq, shortened to
+// warmupInterval during the warm-up burst.
+func warmingUp(seq int, interval time.Duration) time.Duration {
+	if seq < currentSettings().warmup {
+		return min(interval, warmupInterval)
+	}
+	return interval
+}
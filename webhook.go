@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// webhookSink POSTs every alert as JSON to a URL. Slack and Discord incoming
+// webhooks get the message format they expect, any other URL gets the alert
+// fields.
+type webhookSink struct {
+	url    string
+	format string
+	alerts chan alert
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newWebhookSink(rawURL, format string) (*webhookSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		switch {
+		case u.Host == "hooks.slack.com":
+			format = "slack"
+		case strings.HasSuffix(u.Host, "discord.com") || strings.HasSuffix(u.Host, "discordapp.com"):
+			format = "discord"
+		default:
+			format = "generic"
+		}
+	}
+	if format != "slack" && format != "discord" && format != "generic" {
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+
+	k := &webhookSink{
+		url:    rawURL,
+		format: format,
+		alerts: make(chan alert, 100),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go k.run()
+	return k, nil
+}
+
+func (k *webhookSink) write(sample) {}
+
+func (k *webhookSink) writeAlert(a alert) {
+	select {
+	case k.alerts <- a:
+	case <-k.stop:
+	default:
+		fmt.Fprintln(os.Stderr, "webhook: too many pending alerts, dropping:", a.text)
+	}
+}
+
+func (k *webhookSink) close() error {
+	close(k.stop)
+	<-k.done
+	return nil
+}
+
+func (k *webhookSink) run() {
+	defer resetOnPanic()
+	defer close(k.done)
+	for {
+		select {
+		case a := <-k.alerts:
+			k.post(a)
+		case <-k.stop:
+			for len(k.alerts) > 0 {
+				k.post(<-k.alerts)
+			}
+			return
+		}
+	}
+}
+
+func (k *webhookSink) post(a alert) {
+	text := a.text
+	if !a.resolved {
+		text = "⚠ " + text
+	}
+
+	var payload interface{}
+	switch k.format {
+	case "slack":
+		payload = map[string]string{"text": text}
+	case "discord":
+		payload = map[string]string{"content": text}
+	default:
+		payload = struct {
+			Time     time.Time `json:"time"`
+			Target   string    `json:"target"`
+			Alert    string    `json:"alert"`
+			Text     string    `json:"text"`
+			Resolved bool      `json:"resolved"`
+		}{a.time, a.target.String(), a.name, a.text, a.resolved}
+	}
+	body, _ := json.Marshal(payload)
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(k.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		events.add(time.Now(), "webhook: "+err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		events.add(time.Now(), "webhook: "+resp.Status)
+	}
+}